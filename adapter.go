@@ -0,0 +1,62 @@
+package mixpanel
+
+import "sync"
+
+// A Tracker adapts a client to the provider-agnostic shape most analytics
+// abstractions use:
+//
+//	type Tracker interface {
+//		Track(event string, props map[string]interface{}) error
+//		Identify(id string, traits map[string]interface{}) error
+//	}
+//
+// A Tracker is bound to one user at a time. Track sends an event for the
+// current user with props as its properties. Identify sets traits on the
+// profile of id with $set, so traits overwrite existing values, and makes id
+// the user of later Track calls. It does not alias or merge the previous
+// user into id; use Alias or the Identify method of the client for that.
+type Tracker struct {
+	client Mixpanel
+
+	mu         sync.Mutex
+	distinctId string
+}
+
+// NewTracker returns a Tracker sending through client on behalf of
+// distinctId, which may be an anonymous id until Identify is called.
+func NewTracker(client Mixpanel, distinctId string) *Tracker {
+	return &Tracker{client: client, distinctId: distinctId}
+}
+
+// Track sends event for the current user.
+func (t *Tracker) Track(event string, props map[string]interface{}) error {
+	return t.client.Track(t.DistinctId(), event, &Event{Properties: props})
+}
+
+// Identify sets traits on the profile of id and makes id the current user.
+// The current user is left unchanged if the update fails.
+func (t *Tracker) Identify(id string, traits map[string]interface{}) error {
+	if len(traits) > 0 {
+		err := t.client.Update(id, &Update{
+			Operation:  "$set",
+			Properties: traits,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	t.mu.Lock()
+	t.distinctId = id
+	t.mu.Unlock()
+
+	return nil
+}
+
+// DistinctId returns the current user.
+func (t *Tracker) DistinctId() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.distinctId
+}
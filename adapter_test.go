@@ -0,0 +1,42 @@
+package mixpanel
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTracker(t *testing.T) {
+	mock := NewMock()
+	tracker := NewTracker(mock, "anon-1")
+
+	if err := tracker.Track("Viewed Pricing", map[string]interface{}{"plan": "pro"}); err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+
+	if err := tracker.Identify("13793", map[string]interface{}{"$email": "user@email.com"}); err != nil {
+		t.Fatalf("Identify returned %v", err)
+	}
+
+	if err := tracker.Track("Signed Up", nil); err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+
+	anon := mock.People["anon-1"].Events
+	if len(anon) != 1 || anon[0].Name != "Viewed Pricing" || anon[0].Properties["plan"] != "pro" {
+		t.Errorf("Track before Identify tracked %+v", anon)
+	}
+
+	p := mock.People["13793"]
+	if len(p.Events) != 1 || p.Events[0].Name != "Signed Up" {
+		t.Errorf("Track after Identify tracked %+v", p.Events)
+	}
+
+	want := map[string]interface{}{"$email": "user@email.com"}
+	if !reflect.DeepEqual(p.Properties, want) {
+		t.Errorf("Identify set %+v, want %+v", p.Properties, want)
+	}
+
+	if id := tracker.DistinctId(); id != "13793" {
+		t.Errorf("DistinctId returned %q, want %q", id, "13793")
+	}
+}
@@ -0,0 +1,80 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// annotationTimeLayout is the layout of the dates of the Annotations API, in
+// the timezone of the project.
+const annotationTimeLayout = "2006-01-02 15:04:05"
+
+// An Annotation is a note on the charts of a project at a date, such as a
+// deployment or a campaign launch.
+type Annotation struct {
+	// Id is set by Mixpanel.
+	Id int
+
+	// Date is sent without a timezone: Mixpanel reads it in the timezone of
+	// the project, and Date is returned in UTC.
+	Date        time.Time
+	Description string
+}
+
+type annotationJSON struct {
+	Id          int    `json:"id,omitempty"`
+	Date        string `json:"date"`
+	Description string `json:"description"`
+}
+
+func (a Annotation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(annotationJSON{
+		Id:          a.Id,
+		Date:        a.Date.Format(annotationTimeLayout),
+		Description: a.Description,
+	})
+}
+
+func (a *Annotation) UnmarshalJSON(data []byte) error {
+	var v annotationJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	date, err := time.Parse(annotationTimeLayout, v.Date)
+	if err != nil {
+		return err
+	}
+	*a = Annotation{Id: v.Id, Date: date, Description: v.Description}
+	return nil
+}
+
+// CreateAnnotation adds a to the project of the service account given to
+// WithServiceAccount, and returns it as created, with its Id. It fails with
+// ErrNoServiceAccount without one.
+func (m *mixpanel) CreateAnnotation(ctx context.Context, a Annotation) (*Annotation, error) {
+	var created Annotation
+	if err := m.appRequest(ctx, http.MethodPost, "/annotations", nil, a, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// ListAnnotations returns the annotations of the project of the service
+// account given to WithServiceAccount dated from the day of from to the day
+// of to, both included.
+func (m *mixpanel) ListAnnotations(ctx context.Context, from, to time.Time) ([]Annotation, error) {
+	query := url.Values{
+		"fromDate": {from.Format("2006-01-02")},
+		"toDate":   {to.Format("2006-01-02")},
+	}
+
+	var annotations []Annotation
+	if err := m.appRequest(ctx, http.MethodGet, "/annotations", query, nil, &annotations); err != nil {
+		return nil, err
+	}
+	return annotations, nil
+}
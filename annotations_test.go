@@ -0,0 +1,65 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCreateAnnotation(t *testing.T) {
+	var body map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/app/projects/12345/annotations" {
+			t.Errorf("request returned %+v %+v", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"status":"ok","results":{"id":42,"date":"2016-03-03 15:17:53","description":"Release 1.2"}}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", "", WithQueryURL(ts.URL),
+		WithServiceAccount("ops.ab12cd.mp-service-account", "sa-secret", 12345))
+
+	date := time.Date(2016, 3, 3, 15, 17, 53, 0, time.UTC)
+	a, err := client.CreateAnnotation(context.Background(), Annotation{Date: date, Description: "Release 1.2"})
+	if err != nil {
+		t.Fatalf("CreateAnnotation returned %v", err)
+	}
+
+	wantBody := map[string]interface{}{"date": "2016-03-03 15:17:53", "description": "Release 1.2"}
+	if !reflect.DeepEqual(body, wantBody) {
+		t.Errorf("body returned %+v, want %+v", body, wantBody)
+	}
+	want := &Annotation{Id: 42, Date: date, Description: "Release 1.2"}
+	if !reflect.DeepEqual(a, want) {
+		t.Errorf("CreateAnnotation returned %+v, want %+v", a, want)
+	}
+}
+
+func TestListAnnotations(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.RawQuery, "fromDate=2016-03-01&toDate=2016-03-31"; got != want {
+			t.Errorf("query returned %+v, want %+v", got, want)
+		}
+		w.Write([]byte(`{"status":"ok","results":[{"id":42,"date":"2016-03-03 15:17:53","description":"Release 1.2"}]}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", "", WithQueryURL(ts.URL),
+		WithServiceAccount("ops.ab12cd.mp-service-account", "sa-secret", 12345))
+
+	from := time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)
+	annotations, err := client.ListAnnotations(context.Background(), from, from.AddDate(0, 0, 30))
+	if err != nil {
+		t.Fatalf("ListAnnotations returned %v", err)
+	}
+
+	want := []Annotation{{Id: 42, Date: time.Date(2016, 3, 3, 15, 17, 53, 0, time.UTC), Description: "Release 1.2"}}
+	if !reflect.DeepEqual(annotations, want) {
+		t.Errorf("ListAnnotations returned %+v, want %+v", annotations, want)
+	}
+}
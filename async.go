@@ -0,0 +1,274 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrAsyncClosed is returned by an Async client for calls made after Close.
+var ErrAsyncClosed = errors.New("mixpanel: async client is closed")
+
+// AsyncOptions configures an Async client.
+type AsyncOptions struct {
+	// Workers is the number of goroutines sending queued calls. The default
+	// is 4.
+	Workers int
+
+	// QueueSize is the number of calls queued before Policy applies. The
+	// default is 1000.
+	QueueSize int
+
+	// Policy decides what happens to calls made while the queue is full. The
+	// default, QueueBlock, blocks them until a worker takes a call.
+	Policy QueuePolicy
+
+	// OnError is called with the error of every queued call that fails, from
+	// the worker that sent it, so it must be safe for concurrent use. With no
+	// OnError, errors are dropped.
+	OnError func(error)
+}
+
+// A QueuePolicy decides what an Async client does with a call made while its
+// queue is full.
+type QueuePolicy int
+
+const (
+	// QueueBlock waits for room in the queue until the context of the call
+	// is done.
+	QueueBlock QueuePolicy = iota
+
+	// QueueDropNewest drops the call.
+	QueueDropNewest
+
+	// QueueDropOldest drops the oldest queued call to make room for the
+	// call.
+	QueueDropOldest
+)
+
+// AsyncStats describes the queue of an Async client, as returned by Stats.
+type AsyncStats struct {
+	// Queued is the number of calls waiting for a worker.
+	Queued int
+
+	// Dropped is the number of calls dropped by the QueueDropNewest and
+	// QueueDropOldest policies.
+	Dropped int64
+}
+
+// Async wraps a client and sends Track, Update and Alias calls, and their Ctx
+// variants, from a pool of worker goroutines, returning as soon as the call
+// is queued. Other methods are passed straight through. Calls are sent with
+// their call options but without the contexts given to the Ctx variants,
+// which only bound the wait for room in the queue. Calls may be sent out of
+// order when there are several workers.
+//
+// The wrapped client must be safe for concurrent use, as the clients returned
+// by New are.
+type Async struct {
+	Mixpanel
+
+	opts  AsyncOptions
+	queue chan func() error
+
+	// mu guards closed; enqueue holds it for reading until its call is
+	// queued so that Close cannot close the queue under it.
+	mu     sync.RWMutex
+	closed bool
+
+	// pendingMu guards pending, the number of calls queued or being sent,
+	// and idle, which is closed whenever pending drops to zero.
+	pendingMu sync.Mutex
+	pending   int
+	idle      chan struct{}
+
+	workers sync.WaitGroup
+
+	dropped int64
+}
+
+// NewAsync returns an Async client sending through client, and starts its
+// workers. Call Close to stop them.
+func NewAsync(client Mixpanel, opts AsyncOptions) *Async {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1000
+	}
+
+	a := &Async{
+		Mixpanel: client,
+		opts:     opts,
+		queue:    make(chan func() error, opts.QueueSize),
+	}
+
+	a.workers.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go a.work()
+	}
+
+	return a
+}
+
+func (a *Async) Track(distinctId, eventName string, e *Event, opts ...CallOption) error {
+	return a.TrackCtx(context.Background(), distinctId, eventName, e, opts...)
+}
+
+func (a *Async) TrackCtx(ctx context.Context, distinctId, eventName string, e *Event, opts ...CallOption) error {
+	return a.enqueue(ctx, func() error {
+		return a.Mixpanel.Track(distinctId, eventName, e, opts...)
+	})
+}
+
+func (a *Async) Update(distinctId string, u *Update, opts ...CallOption) error {
+	return a.UpdateCtx(context.Background(), distinctId, u, opts...)
+}
+
+func (a *Async) UpdateCtx(ctx context.Context, distinctId string, u *Update, opts ...CallOption) error {
+	return a.enqueue(ctx, func() error {
+		return a.Mixpanel.Update(distinctId, u, opts...)
+	})
+}
+
+func (a *Async) Alias(distinctId, newId string, opts ...CallOption) error {
+	return a.AliasCtx(context.Background(), distinctId, newId, opts...)
+}
+
+func (a *Async) AliasCtx(ctx context.Context, distinctId, newId string, opts ...CallOption) error {
+	return a.enqueue(ctx, func() error {
+		return a.Mixpanel.Alias(distinctId, newId, opts...)
+	})
+}
+
+// enqueue queues send. While the queue is full, it applies the policy of the
+// client, blocking until ctx is done with QueueBlock. A dropped call is not
+// an error.
+func (a *Async) enqueue(ctx context.Context, send func() error) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		return ErrAsyncClosed
+	}
+
+	a.addPending()
+
+	switch a.opts.Policy {
+	case QueueDropNewest:
+		select {
+		case a.queue <- send:
+		default:
+			a.drop()
+		}
+		return nil
+	case QueueDropOldest:
+		for {
+			select {
+			case a.queue <- send:
+				return nil
+			default:
+			}
+
+			select {
+			case <-a.queue:
+				a.drop()
+			default:
+				// A worker took a call in the meantime.
+			}
+		}
+	}
+
+	select {
+	case a.queue <- send:
+		return nil
+	case <-ctx.Done():
+		a.donePending()
+		return ctx.Err()
+	}
+}
+
+// addPending counts a queued call.
+func (a *Async) addPending() {
+	a.pendingMu.Lock()
+	defer a.pendingMu.Unlock()
+
+	if a.pending == 0 {
+		a.idle = make(chan struct{})
+	}
+	a.pending++
+}
+
+// donePending counts a call sent or dropped.
+func (a *Async) donePending() {
+	a.pendingMu.Lock()
+	defer a.pendingMu.Unlock()
+
+	a.pending--
+	if a.pending == 0 {
+		close(a.idle)
+	}
+}
+
+// drop counts a dropped call.
+func (a *Async) drop() {
+	atomic.AddInt64(&a.dropped, 1)
+	a.donePending()
+}
+
+// Stats returns the number of queued calls and of calls dropped so far.
+func (a *Async) Stats() AsyncStats {
+	return AsyncStats{
+		Queued:  len(a.queue),
+		Dropped: atomic.LoadInt64(&a.dropped),
+	}
+}
+
+func (a *Async) work() {
+	defer a.workers.Done()
+
+	for send := range a.queue {
+		if err := send(); err != nil && a.opts.OnError != nil {
+			a.opts.OnError(err)
+		}
+		a.donePending()
+	}
+}
+
+// Flush blocks until every call queued so far, and any queued while it waits,
+// has been sent, or until ctx is done, in which case it returns ctx.Err() and
+// the calls are still sent in the background.
+func (a *Async) Flush(ctx context.Context) error {
+	a.pendingMu.Lock()
+	if a.pending == 0 {
+		a.pendingMu.Unlock()
+		return nil
+	}
+	idle := a.idle
+	a.pendingMu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting calls, sends every queued call and stops the workers.
+// Calls blocked waiting for room in the queue are sent too.
+func (a *Async) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.queue)
+	a.mu.Unlock()
+
+	a.workers.Wait()
+
+	return nil
+}
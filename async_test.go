@@ -0,0 +1,220 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingClient counts the events tracked through it, waiting on release, if
+// set, before returning from Track.
+type countingClient struct {
+	Mixpanel
+
+	release chan struct{}
+	err     error
+
+	tracked, active, maxActive int32
+
+	mu    sync.Mutex
+	names []string
+}
+
+func (c *countingClient) Track(distinctId, eventName string, e *Event, opts ...CallOption) error {
+	active := atomic.AddInt32(&c.active, 1)
+	for {
+		max := atomic.LoadInt32(&c.maxActive)
+		if active <= max || atomic.CompareAndSwapInt32(&c.maxActive, max, active) {
+			break
+		}
+	}
+
+	if c.release != nil {
+		<-c.release
+	}
+
+	c.mu.Lock()
+	c.names = append(c.names, eventName)
+	c.mu.Unlock()
+
+	atomic.AddInt32(&c.active, -1)
+	atomic.AddInt32(&c.tracked, 1)
+	return c.err
+}
+
+func TestAsyncCloseDrains(t *testing.T) {
+	client := &countingClient{}
+	async := NewAsync(client, AsyncOptions{Workers: 2, QueueSize: 10})
+
+	for i := 0; i < 100; i++ {
+		if err := async.Track("13793", "Signed Up", &Event{}); err != nil {
+			t.Fatalf("Track returned %v", err)
+		}
+	}
+
+	if err := async.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+
+	if got := atomic.LoadInt32(&client.tracked); got != 100 {
+		t.Errorf("tracked returned %+v, want %+v", got, 100)
+	}
+	if err := async.Track("13793", "Signed Up", &Event{}); err != ErrAsyncClosed {
+		t.Errorf("Track after Close returned %v, want %v", err, ErrAsyncClosed)
+	}
+}
+
+func TestAsyncWorkers(t *testing.T) {
+	client := &countingClient{release: make(chan struct{})}
+	async := NewAsync(client, AsyncOptions{Workers: 3})
+	defer async.Close()
+
+	for i := 0; i < 6; i++ {
+		async.Track("13793", "Signed Up", &Event{})
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&client.active) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(client.release)
+
+	if err := async.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush returned %v", err)
+	}
+
+	if got := atomic.LoadInt32(&client.maxActive); got != 3 {
+		t.Errorf("concurrent sends returned %+v, want %+v", got, 3)
+	}
+	if got := atomic.LoadInt32(&client.tracked); got != 6 {
+		t.Errorf("tracked returned %+v, want %+v", got, 6)
+	}
+}
+
+func TestAsyncBackpressure(t *testing.T) {
+	client := &countingClient{release: make(chan struct{})}
+	async := NewAsync(client, AsyncOptions{Workers: 1, QueueSize: 1})
+	defer async.Close()
+	defer close(client.release)
+
+	// One call is taken by the worker and one fills the queue.
+	async.Track("13793", "Signed Up", &Event{})
+	async.Track("13793", "Signed Up", &Event{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := async.TrackCtx(ctx, "13793", "Signed Up", &Event{}); err != context.DeadlineExceeded {
+		t.Errorf("TrackCtx on a full queue returned %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestAsyncDropPolicies(t *testing.T) {
+	tests := []struct {
+		policy QueuePolicy
+		want   []string
+	}{
+		{QueueDropNewest, []string{"1", "2"}},
+		{QueueDropOldest, []string{"1", "3"}},
+	}
+
+	for _, tt := range tests {
+		client := &countingClient{release: make(chan struct{})}
+		async := NewAsync(client, AsyncOptions{Workers: 1, QueueSize: 1, Policy: tt.policy})
+
+		// The worker takes the first call and the second fills the queue.
+		async.Track("13793", "1", &Event{})
+		deadline := time.Now().Add(time.Second)
+		for atomic.LoadInt32(&client.active) < 1 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		async.Track("13793", "2", &Event{})
+
+		if err := async.Track("13793", "3", &Event{}); err != nil {
+			t.Errorf("Track on a full queue returned %v", err)
+		}
+		if got, want := async.Stats(), (AsyncStats{Queued: 1, Dropped: 1}); got != want {
+			t.Errorf("Stats returned %+v, want %+v", got, want)
+		}
+
+		close(client.release)
+		async.Close()
+
+		if !reflect.DeepEqual(client.names, tt.want) {
+			t.Errorf("policy %v tracked %+v, want %+v", tt.policy, client.names, tt.want)
+		}
+	}
+}
+
+func TestAsyncOnError(t *testing.T) {
+	sendErr := errors.New("send failed")
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	client := &countingClient{err: sendErr}
+	async := NewAsync(client, AsyncOptions{OnError: func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}})
+
+	async.Track("13793", "Signed Up", &Event{})
+	async.Track("13793", "Signed Up", &Event{})
+	async.Close()
+
+	if len(errs) != 2 || errs[0] != sendErr || errs[1] != sendErr {
+		t.Errorf("OnError got %+v, want %+v twice", errs, sendErr)
+	}
+}
+
+func TestAsyncFlushWhileTracking(t *testing.T) {
+	client := &countingClient{}
+	async := NewAsync(client, AsyncOptions{Workers: 2})
+	defer async.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				async.Track("13793", "Signed Up", &Event{})
+			}
+		}()
+	}
+	for i := 0; i < 200; i++ {
+		if err := async.Flush(context.Background()); err != nil {
+			t.Fatalf("Flush returned %v", err)
+		}
+	}
+	wg.Wait()
+
+	if err := async.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush returned %v", err)
+	}
+	if got := atomic.LoadInt32(&client.tracked); got != 2000 {
+		t.Errorf("tracked returned %+v after Flush, want %+v", got, 2000)
+	}
+}
+
+func TestAsyncFlushContext(t *testing.T) {
+	client := &countingClient{release: make(chan struct{})}
+	async := NewAsync(client, AsyncOptions{Workers: 1})
+
+	async.Track("13793", "Signed Up", &Event{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := async.Flush(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Flush returned %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(client.release)
+	async.Close()
+}
@@ -0,0 +1,45 @@
+package mixpanel
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff computes the delay to wait before retrying a failed request. The
+// delay doubles with every attempt, starting at Base and capped at Max.
+type Backoff struct {
+	// Base is the delay before the first retry.
+	Base time.Duration
+
+	// Max caps the delay. Zero means no cap.
+	Max time.Duration
+
+	// Jitter picks each delay uniformly between zero and the exponential
+	// delay ("full jitter"), so that many clients retrying after the same
+	// outage spread out instead of retrying in lockstep.
+	Jitter bool
+}
+
+// Delay returns the delay before retry number attempt, counting from zero.
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := b.Base
+	for i := 0; i < attempt; i++ {
+		if b.Max > 0 && d >= b.Max {
+			break
+		}
+		if d > d<<1 {
+			// Overflow; stop growing.
+			break
+		}
+		d <<= 1
+	}
+	if b.Max > 0 && d > b.Max {
+		d = b.Max
+	}
+
+	if b.Jitter && d > 0 {
+		d = time.Duration(rand.Int63n(int64(d) + 1))
+	}
+
+	return d
+}
@@ -0,0 +1,42 @@
+package mixpanel
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Base: 100 * time.Millisecond, Max: time.Second}
+
+	want := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for attempt, w := range want {
+		if got := b.Delay(attempt); got != w {
+			t.Errorf("Delay(%d) returned %v, want %v", attempt, got, w)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	b := Backoff{Base: 100 * time.Millisecond, Max: time.Second, Jitter: true}
+
+	for attempt, max := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		distinct := map[time.Duration]bool{}
+		for i := 0; i < 1000; i++ {
+			d := b.Delay(attempt)
+			if d < 0 || d > max {
+				t.Fatalf("Delay(%d) returned %v, want within [0, %v]", attempt, d, max)
+			}
+			distinct[d] = true
+		}
+		if len(distinct) < 100 {
+			t.Errorf("Delay(%d) returned only %d distinct values in 1000 samples", attempt, len(distinct))
+		}
+	}
+}
@@ -0,0 +1,550 @@
+package mixpanel
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// A BatchEvent is a single event sent as part of a batch request.
+type BatchEvent struct {
+	DistinctId string
+	EventName  string
+	Event
+
+	// Token is the token of the project the event goes to, for services
+	// fanning out to several projects. Empty means the client's token. The
+	// client's API secret must be accepted by every project involved. A client
+	// given WithServiceAccount sends every import to the project of the
+	// service account, so it cannot fan out.
+	Token string
+}
+
+// batchEventParamsFor builds the payload of e for eventType, "track" or
+// "import".
+func (m *mixpanel) batchEventParamsFor(ctx context.Context, eventType string, e *BatchEvent) (map[string]interface{}, error) {
+	params, err := m.eventParams(ctx, eventType, e.DistinctId, e.EventName, &e.Event)
+	if err != nil {
+		return nil, err
+	}
+	if e.Token != "" {
+		params["properties"].(map[string]interface{})["token"] = e.Token
+	}
+	return params, nil
+}
+
+// ImportNDJSON sends events to the /import endpoint as a gzip-compressed,
+// newline-delimited JSON request body. This is the format Mixpanel recommends
+// for high-volume imports: events are streamed into the request as they are
+// encoded and no base64 encoding is involved. The request is authenticated
+// with the API secret, or the service account given to WithServiceAccount.
+// An invalid event aborts the request, which fails with a *BatchItemError
+// identifying the event.
+//
+// Since the body is streamed, the request is sent once, over HTTP, outside
+// of the options wrapping the other ingestion calls: it is not retried, nor
+// bounded by WithEndpointTimeout, nor given to a Sink, a Middleware or the
+// error ring.
+func (m *mixpanel) ImportNDJSON(events []BatchEvent, opts ...CallOption) error {
+	return m.ImportNDJSONCtx(context.Background(), events, opts...)
+}
+
+// ImportNDJSONCtx is like ImportNDJSON, but builds the events like TrackCtx,
+// with the properties returned by the extractor given to
+// WithContextPropertyExtractor, and sends the request with ctx, which stops
+// the encoding of the body once ctx is done.
+func (m *mixpanel) ImportNDJSONCtx(ctx context.Context, events []BatchEvent, opts ...CallOption) error {
+	if m.disabled() {
+		return nil
+	}
+
+	pr, pw := io.Pipe()
+	encoded := make(chan error, 1)
+
+	go func() {
+		gz := gzip.NewWriter(pw)
+		enc := json.NewEncoder(gz)
+		for i := range events {
+			params, err := m.batchEventParamsFor(ctx, "import", &events[i])
+			if err != nil {
+				err = &BatchItemError{Index: i, DistinctId: events[i].DistinctId, Err: err}
+			} else {
+				err = enc.Encode(params)
+			}
+			if err != nil {
+				encoded <- err
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		encoded <- nil
+		pw.CloseWithError(gz.Close())
+	}()
+
+	reqUrl := m.ApiURL + "/import"
+	if query := m.importQuery(); query != "" {
+		reqUrl += "?" + strings.TrimSuffix(query, "&")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, endpointMethod("import"), reqUrl, pr)
+	if err != nil {
+		pr.Close()
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+	m.authenticate(req, "import")
+	newCallOptions(opts).applyHeader(req)
+
+	status, body, err := m.do(req)
+
+	// The transport has closed the request body by now, so the encoder
+	// has either finished or failed. When the request failed on its own,
+	// the encoder only saw the closed pipe, and the request error is the
+	// one to report.
+	encErr := <-encoded
+	if err != nil && (encErr == nil || errors.Is(encErr, io.ErrClosedPipe)) {
+		return err
+	}
+	if encErr != nil {
+		return encErr
+	}
+
+	if status < 200 || status > 299 {
+		return apiError(reqUrl, status, body)
+	}
+
+	return nil
+}
+
+// MaxTrackBatch is the number of events TrackBatch packs into a single
+// request, the most /track accepts.
+const MaxTrackBatch = 50
+
+// TrackBatch sends events to the /track endpoint as JSON arrays of up to
+// MaxTrackBatch events and MaxPayloadBytes each. Every event is built and
+// validated exactly like a single Track, and nothing is sent if any of them
+// is invalid or too large for a request on its own: the error is then a
+// *BatchItemError identifying the event, matching ErrEventTooLarge for one
+// too large. Unlike Track, events are never routed to /import: /track
+// rejects events older than five days, which ImportBatch sends instead. All
+// chunks are sent, in order; if any of them fails, a *BatchError describes
+// which.
+func (m *mixpanel) TrackBatch(events []BatchEvent, opts ...CallOption) error {
+	return m.TrackBatchCtx(context.Background(), events, opts...)
+}
+
+// TrackBatchCtx is like TrackBatch, but builds the events like TrackCtx and
+// sends the requests with ctx, stopping before the next chunk once ctx is
+// done.
+func (m *mixpanel) TrackBatchCtx(ctx context.Context, events []BatchEvent, opts ...CallOption) error {
+	call := newCallOptions(opts)
+
+	records := make([]map[string]interface{}, 0, len(events))
+	for i := range events {
+		params, err := m.batchEventParamsFor(ctx, "track", &events[i])
+		if err != nil {
+			return &BatchItemError{Index: i, DistinctId: events[i].DistinctId, Err: err}
+		}
+		keepUngeolocated(params["properties"].(map[string]interface{}), "ip", events[i].IP, events[i].Geolocate)
+		records = append(records, params)
+	}
+
+	chunks, err := chunkRecords(records, MaxTrackBatch, m.payloadLimit("track"))
+	if err != nil {
+		var itemErr *BatchItemError
+		if errors.As(err, &itemErr) {
+			itemErr.DistinctId = events[itemErr.Index].DistinctId
+		}
+		return err
+	}
+
+	batchErr := &BatchError{}
+
+	for chunk, bounds := range chunks {
+		start, end := bounds[0], bounds[1]
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// The chunk is geolocated if any of its events would be on its own;
+		// events with an explicit ip, including those given one by
+		// keepUngeolocated, are geolocated from it.
+		autoGeolocate := false
+		for i := start; i < end; i++ {
+			if geolocate(events[i].IP, events[i].Geolocate) {
+				autoGeolocate = true
+			}
+		}
+
+		if err := m.send(ctx, "track", records[start:end], autoGeolocate, call); err != nil {
+			batchErr.add(chunk, start, end, err)
+		}
+	}
+
+	return batchErr.orNil()
+}
+
+// MaxImportBatch is the number of events ImportBatch packs into a single
+// request, the most /import accepts.
+const MaxImportBatch = 2000
+
+// ImportBatch sends events to the /import endpoint as JSON arrays of up to
+// MaxImportBatch events and MaxImportPayloadBytes each, authenticated with the
+// API secret or the service account given to WithServiceAccount. Every event
+// is built and validated exactly like a single Track to /import, including
+// its Timestamp and IP, and nothing is sent if any of them is invalid or too
+// large, as with TrackBatch. Events without a Timestamp are stamped with the
+// current time, as by Import. All chunks are sent, in order; if any of them
+// fails, a *BatchError describes which.
+//
+// Events with different Tokens are grouped by token, in order of first
+// appearance, and each group is chunked and sent separately, since a request
+// goes to a single project. The Start and End of a ChunkError then count the
+// events of its Token only, and its Indexes give the position of each of its
+// events in events.
+func (m *mixpanel) ImportBatch(events []BatchEvent, opts ...CallOption) error {
+	return m.ImportBatchCtx(context.Background(), events, opts...)
+}
+
+// ImportBatchCtx is like ImportBatch, but builds the events like TrackCtx and
+// sends the requests with ctx, stopping before the next chunk once ctx is
+// done.
+func (m *mixpanel) ImportBatchCtx(ctx context.Context, events []BatchEvent, opts ...CallOption) error {
+	call := newCallOptions(opts)
+
+	type group struct {
+		token   string
+		indexes []int
+		events  []*BatchEvent
+		records []map[string]interface{}
+		chunks  [][2]int
+	}
+	var groups []*group
+	byToken := map[string]*group{}
+
+	for i := range events {
+		e := &events[i]
+		params, err := m.batchEventParamsFor(ctx, "import", e)
+		if err != nil {
+			return &BatchItemError{Index: i, DistinctId: e.DistinctId, Err: err}
+		}
+		keepUngeolocated(params["properties"].(map[string]interface{}), "ip", e.IP, e.Geolocate)
+
+		g := byToken[e.Token]
+		if g == nil {
+			g = &group{token: e.Token}
+			byToken[e.Token] = g
+			groups = append(groups, g)
+		}
+		g.indexes = append(g.indexes, i)
+		g.events = append(g.events, e)
+		g.records = append(g.records, params)
+	}
+
+	for _, g := range groups {
+		var err error
+		if g.chunks, err = chunkRecords(g.records, MaxImportBatch, MaxImportPayloadBytes); err != nil {
+			var itemErr *BatchItemError
+			if errors.As(err, &itemErr) {
+				itemErr.Index = g.indexes[itemErr.Index]
+				itemErr.DistinctId = events[itemErr.Index].DistinctId
+			}
+			return err
+		}
+	}
+
+	batchErr := &BatchError{}
+	chunk := 0
+
+	for _, g := range groups {
+		for _, bounds := range g.chunks {
+			start, end := bounds[0], bounds[1]
+
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			// The chunk is geolocated if any of its events would be on its
+			// own; events with an explicit ip, including those given one by
+			// keepUngeolocated, are geolocated from it.
+			autoGeolocate := false
+			for _, e := range g.events[start:end] {
+				if geolocate(e.IP, e.Geolocate) {
+					autoGeolocate = true
+				}
+			}
+
+			if err := m.importChunk(ctx, g.records[start:end], autoGeolocate, call); err != nil {
+				batchErr.add(chunk, start, end, err)
+				failed := &batchErr.Chunks[len(batchErr.Chunks)-1]
+				failed.Token = g.token
+				failed.Indexes = append([]int(nil), g.indexes[start:end]...)
+			}
+			chunk++
+		}
+	}
+
+	return batchErr.orNil()
+}
+
+// importChunk sends a chunk of ImportBatch with ctx like any other ingestion
+// call, but as a JSON request body.
+func (m *mixpanel) importChunk(ctx context.Context, records []map[string]interface{}, autoGeolocate bool, call *callOptions) error {
+	return m.sendWith(ctx, "import", records, call, func(ctx context.Context, endpoint string, payload []byte) (*Response, error) {
+		return m.sendChunk(ctx, endpoint, payload, autoGeolocate, call)
+	})
+}
+
+// sendChunk sends the JSON array payload to the endpoint of ImportBatch.
+func (m *mixpanel) sendChunk(ctx context.Context, endpoint string, payload []byte, autoGeolocate bool, call *callOptions) (*Response, error) {
+	payload, compressed, err := m.compressBody(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.importQuery()
+	if autoGeolocate {
+		query += "ip=1&"
+	}
+	reqUrl := m.ApiURL + "/" + endpoint
+	if query != "" {
+		reqUrl += "?" + strings.TrimSuffix(query, "&")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, endpointMethod(endpoint), reqUrl, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	m.authenticate(req, endpoint)
+	call.applyHeader(req)
+
+	status, body, err := m.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if status < 200 || status > 299 {
+		return &Response{HttpStatus: status}, apiError(reqUrl, status, body)
+	}
+
+	return &Response{HttpStatus: status}, nil
+}
+
+// MaxBatchUpdates is the number of profile updates UpdateBatch packs into a
+// single request.
+const MaxBatchUpdates = 50
+
+// A BatchUpdate is a single profile update sent as part of UpdateBatch.
+type BatchUpdate struct {
+	DistinctId string
+	Update
+
+	// Operations holds further operations applied to the same profile in the
+	// same batch element, mapping an operation such as "$add" to its value.
+	// They are sent alongside Update.Operation, which may be left empty.
+	Operations map[string]interface{}
+}
+
+// UpdateBatch sends profile updates to /engage as JSON arrays of up to
+// MaxBatchUpdates records and MaxPayloadBytes each. Every record is built and
+// validated exactly like a single Update, and nothing is sent if any of them
+// is invalid or too large: the error is then a *BatchItemError identifying
+// the record. All chunks are sent, in order; if any of them fails, a
+// *BatchError describes which. Mixpanel accepts or rejects a chunk of
+// updates as a whole, so the Start and End of a ChunkError give the updates
+// that were not applied.
+func (m *mixpanel) UpdateBatch(updates []BatchUpdate, opts ...CallOption) error {
+	return m.UpdateBatchCtx(context.Background(), updates, opts...)
+}
+
+// UpdateBatchCtx is like UpdateBatch, but sends the requests with ctx and
+// stops before the next chunk once ctx is done.
+func (m *mixpanel) UpdateBatchCtx(ctx context.Context, updates []BatchUpdate, opts ...CallOption) error {
+	return m.updateBatch(ctx, updates, newCallOptions(opts))
+}
+
+// updateBatch is UpdateBatch sending its requests with ctx. It stops before
+// the next chunk once ctx is done. call may be nil.
+func (m *mixpanel) updateBatch(ctx context.Context, updates []BatchUpdate, call *callOptions) error {
+	records := make([]map[string]interface{}, 0, len(updates))
+	for i := range updates {
+		u := &updates[i]
+		record, err := m.updateParams(u.DistinctId, &u.Update)
+		if err != nil {
+			return &BatchItemError{Index: i, DistinctId: u.DistinctId, Err: err}
+		}
+		for op, value := range u.Operations {
+			if props, ok := value.(map[string]interface{}); ok {
+				if err := m.validateProfile(props); err != nil {
+					return &BatchItemError{Index: i, DistinctId: u.DistinctId, Err: err}
+				}
+				value = m.scrubProperties(m.coerceProperties(props))
+			}
+			record[op] = value
+		}
+		keepUngeolocated(record, "$ip", u.IP, u.Geolocate)
+		records = append(records, record)
+	}
+
+	chunks, err := chunkRecords(records, MaxBatchUpdates, m.payloadLimit("engage"))
+	if err != nil {
+		var itemErr *BatchItemError
+		if errors.As(err, &itemErr) {
+			itemErr.DistinctId = updates[itemErr.Index].DistinctId
+		}
+		return err
+	}
+
+	batchErr := &BatchError{}
+
+	for chunk, bounds := range chunks {
+		start, end := bounds[0], bounds[1]
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// The chunk is geolocated if any of its records would be on its
+		// own; records with an explicit $ip, including those given one by
+		// keepUngeolocated, are geolocated from it.
+		autoGeolocate := false
+		for i := start; i < end; i++ {
+			if geolocate(updates[i].IP, updates[i].Geolocate) {
+				autoGeolocate = true
+			}
+		}
+
+		if err := m.send(ctx, "engage", records[start:end], autoGeolocate, call); err != nil {
+			batchErr.add(chunk, start, end, err)
+		}
+	}
+
+	return batchErr.orNil()
+}
+
+// keepUngeolocated gives props, the properties of an event or the record of
+// an update in a batch, an IP of "0" when it is not geolocated on its own and
+// carries no IP, because its Geolocate is false or the Scrubber dropped its
+// IP. Otherwise the ip=1 flag of a chunk geolocating its other items would
+// geolocate it from the address of the request. ipKey is "ip" for events and
+// "$ip" for updates.
+func keepUngeolocated(props map[string]interface{}, ipKey, ip string, g *bool) {
+	if _, ok := props[ipKey]; ok || geolocate(ip, g) {
+		return
+	}
+	props[ipKey] = "0"
+}
+
+// A ChunkError describes a chunk of a batch request that failed.
+type ChunkError struct {
+	// Index of the chunk, counting from zero.
+	Index int
+
+	// Start and End delimit the items of the chunk in the slice passed to the
+	// batch method, as in items[Start:End], except for ImportBatch given
+	// events with different Tokens, where they count the events of Token
+	// only.
+	Start, End int
+
+	// Indexes are the positions of the events of the chunk in the slice
+	// passed to ImportBatch, whatever their Token. It is nil for the other
+	// batch methods, whose chunks are items[Start:End].
+	Indexes []int
+
+	// Token is the project token of the chunk when ImportBatch was given
+	// events with their own Token, empty otherwise.
+	Token string
+
+	Err error
+
+	// Retryable reports whether the failure was transient, such as a
+	// network error, a rate limit, a server error or the circuit breaker of
+	// WithCircuitBreaker being open, so that the chunk can be sent again as
+	// is. Other failures, such as invalid data, are permanent.
+	//
+	// Only the HTTP status tells the two apart. The verbose status of /track
+	// and /engage, MixpanelError.Code, is 0 for any rejected payload, which
+	// is permanent, and the code in the error body of /import repeats the
+	// HTTP status, so neither says more.
+	Retryable bool
+}
+
+// A BatchItemError is returned by the batch methods when one of the items is
+// invalid or too large for a request, before anything is sent.
+type BatchItemError struct {
+	// Index of the invalid item in the slice passed to the batch method.
+	Index      int
+	DistinctId string
+	Err        error
+}
+
+func (err *BatchItemError) Error() string {
+	return fmt.Sprintf("mixpanel: batch item %d (%s): %v", err.Index, err.DistinctId, err.Err)
+}
+
+func (err *BatchItemError) Unwrap() error {
+	return err.Err
+}
+
+// A BatchError is returned by the batch methods when some of the chunks they
+// sent failed. The other chunks were accepted.
+type BatchError struct {
+	Chunks []ChunkError
+}
+
+func (err *BatchError) Error() string {
+	if len(err.Chunks) == 1 {
+		return fmt.Sprintf("mixpanel: batch chunk %d failed: %v", err.Chunks[0].Index, err.Chunks[0].Err)
+	}
+	return fmt.Sprintf("mixpanel: %d batch chunks failed, first: chunk %d: %v", len(err.Chunks), err.Chunks[0].Index, err.Chunks[0].Err)
+}
+
+// Retryable returns the chunks whose failure was transient.
+func (err *BatchError) Retryable() []ChunkError {
+	var chunks []ChunkError
+	for _, c := range err.Chunks {
+		if c.Retryable {
+			chunks = append(chunks, c)
+		}
+	}
+	return chunks
+}
+
+func (err *BatchError) add(index, start, end int, chunkErr error) {
+	err.Chunks = append(err.Chunks, ChunkError{
+		Index:     index,
+		Start:     start,
+		End:       end,
+		Err:       chunkErr,
+		Retryable: isTransient(chunkErr),
+	})
+}
+
+// isTransient reports whether a call that failed with err can succeed when
+// made again as is: IsRetryable reports it, or the circuit breaker was open.
+// Unlike IsRetryable, a call refused by the breaker is not worth retrying at
+// once, but it sent nothing and can be made again after the cool-down.
+func isTransient(err error) bool {
+	return IsRetryable(err) || errors.Is(err, ErrCircuitOpen)
+}
+
+func (err *BatchError) orNil() error {
+	if len(err.Chunks) == 0 {
+		return nil
+	}
+	return err
+}
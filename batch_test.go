@@ -0,0 +1,670 @@
+package mixpanel
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportNDJSON(t *testing.T) {
+	var (
+		header http.Header
+		body   string
+		path   string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		path = r.URL.Path
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("request body is not gzipped: %v", err)
+			return
+		}
+		data, _ := io.ReadAll(gz)
+		body = string(data)
+		w.WriteHeader(200)
+		w.Write([]byte("{\"code\":200,\"num_records_imported\":2,\"status\":\"OK\"}"))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL)
+
+	at := time.Date(2016, 3, 3, 15, 17, 53, 0, time.UTC)
+	err := client.ImportNDJSON([]BatchEvent{
+		{DistinctId: "13793", EventName: "Signed Up", Event: Event{Timestamp: &at}},
+		{DistinctId: "13794", EventName: "Signed Up", Event: Event{Timestamp: &at, Properties: map[string]interface{}{"Referred By": "Friend"}}},
+	})
+	if err != nil {
+		t.Fatalf("ImportNDJSON returned %v", err)
+	}
+
+	want := "{\"event\":\"Signed Up\",\"properties\":{\"distinct_id\":\"13793\",\"time\":1457018273000,\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}\n" +
+		"{\"event\":\"Signed Up\",\"properties\":{\"Referred By\":\"Friend\",\"distinct_id\":\"13794\",\"time\":1457018273000,\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}\n"
+	if body != want {
+		t.Errorf("request body returned %+v, want %+v", body, want)
+	}
+
+	if path != "/import" {
+		t.Errorf("path returned %+v, want %+v", path, "/import")
+	}
+
+	wantHeader := map[string]string{
+		"Content-Type":     "application/x-ndjson",
+		"Content-Encoding": "gzip",
+		"Authorization":    "Basic c2VjcmV0Og==",
+	}
+	got := map[string]string{}
+	for key := range wantHeader {
+		got[key] = header.Get(key)
+	}
+	if !reflect.DeepEqual(got, wantHeader) {
+		t.Errorf("request headers returned %+v, want %+v", got, wantHeader)
+	}
+}
+
+func TestImportNDJSONError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		w.Write([]byte("{\"code\":400,\"error\":\"some data points in the request failed validation\",\"status\":\"Bad Request\"}"))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL)
+
+	err := client.ImportNDJSON([]BatchEvent{{DistinctId: "13793", EventName: "Signed Up"}})
+
+	mpErr, ok := err.(*MixpanelError)
+	if !ok {
+		t.Fatalf("ImportNDJSON returned %v, want a *MixpanelError", err)
+	}
+	if mpErr.HttpStatus != 400 || mpErr.Message != "some data points in the request failed validation" {
+		t.Errorf("ImportNDJSON returned %+v", mpErr)
+	}
+}
+
+func TestImportNDJSONNetworkError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL)
+
+	events := make([]BatchEvent, 20000)
+	for i := range events {
+		events[i] = BatchEvent{DistinctId: strconv.Itoa(i), EventName: "Signed Up"}
+	}
+	err := client.ImportNDJSON(events)
+
+	var mpErr *MixpanelError
+	if !errors.As(err, &mpErr) {
+		t.Fatalf("ImportNDJSON returned %v, want a *MixpanelError", err)
+	}
+	if !IsRetryable(err) {
+		t.Errorf("IsRetryable returned false for %v", err)
+	}
+}
+
+func TestBatchCtxCanceled(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	events := make([]BatchEvent, 20000)
+	for i := range events {
+		events[i] = BatchEvent{DistinctId: strconv.Itoa(i), EventName: "Signed Up"}
+	}
+
+	if err := client.ImportNDJSONCtx(canceled, events); !errors.Is(err, context.Canceled) {
+		t.Errorf("ImportNDJSONCtx returned %v, want %v", err, context.Canceled)
+	}
+	if err := client.ImportBatchCtx(canceled, events); !errors.Is(err, context.Canceled) {
+		t.Errorf("ImportBatchCtx returned %v, want %v", err, context.Canceled)
+	}
+	if err := client.TrackBatchCtx(canceled, events[:MaxTrackBatch+1]); !errors.Is(err, context.Canceled) {
+		t.Errorf("TrackBatchCtx returned %v, want %v", err, context.Canceled)
+	}
+	if requests != 0 {
+		t.Errorf("batch methods sent %d requests, want none", requests)
+	}
+}
+
+func TestTrackBatchCtxStopsBetweenChunks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		cancel()
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+
+	events := make([]BatchEvent, 2*MaxTrackBatch)
+	for i := range events {
+		events[i] = BatchEvent{DistinctId: strconv.Itoa(i), EventName: "Signed Up", Event: Event{IP: "0"}}
+	}
+
+	err := client.TrackBatchCtx(ctx, events)
+	if requests != 1 || !errors.Is(err, context.Canceled) {
+		t.Errorf("TrackBatchCtx sent %d requests and returned %v, want 1 and %v", requests, err, context.Canceled)
+	}
+}
+
+func TestUpdateBatchMixedOperations(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.UpdateBatch([]BatchUpdate{
+		{
+			DistinctId: "13793",
+			Update: Update{
+				IP:         "127.0.0.1",
+				Operation:  "$set",
+				Properties: map[string]interface{}{"Plan": "pro"},
+			},
+			Operations: map[string]interface{}{
+				"$add": map[string]interface{}{"Logins": 1},
+			},
+		},
+	})
+
+	want := "[{\"$add\":{\"Logins\":1},\"$distinct_id\":\"13793\",\"$ip\":\"127.0.0.1\",\"$set\":{\"Plan\":\"pro\"},\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}]"
+
+	if !reflect.DeepEqual(decodeData(LastRequest), want) {
+		t.Errorf("LastRequest.URL returned %+v, want %+v",
+			decodeData(LastRequest), want)
+	}
+	if got := LastRequest.URL.Query().Get("ip"); got != "" {
+		t.Errorf("ip returned %+v, want it unset", got)
+	}
+}
+
+func TestUpdateBatchInvalidItem(t *testing.T) {
+	setup()
+	defer teardown()
+	LastRequest = nil
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithStrictMode())
+	err := client.UpdateBatch([]BatchUpdate{
+		{DistinctId: "13793", Update: Update{Operation: "$set", Properties: map[string]interface{}{"Plan": "pro"}}},
+		{DistinctId: "13794", Operations: map[string]interface{}{
+			"$set": map[string]interface{}{"$email": 42},
+		}},
+	})
+
+	var itemErr *BatchItemError
+	if !errors.As(err, &itemErr) || itemErr.Index != 1 || itemErr.DistinctId != "13794" {
+		t.Fatalf("UpdateBatch returned %v, want an error for item 1", err)
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("UpdateBatch returned %v, want it to wrap a *ValidationError", err)
+	}
+	if LastRequest != nil {
+		t.Errorf("UpdateBatch sent a request for an invalid batch")
+	}
+}
+
+func TestUpdateBatchChunks(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+
+	updates := make([]BatchUpdate, MaxBatchUpdates+1)
+	for i := range updates {
+		updates[i] = BatchUpdate{
+			DistinctId: "13793",
+			Update: Update{
+				Operation:  "$set",
+				Properties: map[string]interface{}{"Plan": "pro"},
+			},
+		}
+	}
+
+	if err := client.UpdateBatch(updates); err != nil {
+		t.Fatalf("UpdateBatch returned %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("UpdateBatch sent %d requests, want 2", requests)
+	}
+}
+
+func TestBatchRequestHeader(t *testing.T) {
+	var keys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.URL.Path+" "+r.Header.Get("X-Idempotency-Key"))
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL)
+
+	err := client.ImportNDJSON([]BatchEvent{{DistinctId: "13793", EventName: "Signed Up"}},
+		RequestHeader("X-Idempotency-Key", "import-1"))
+	if err != nil {
+		t.Fatalf("ImportNDJSON returned %v", err)
+	}
+
+	updates := make([]BatchUpdate, MaxBatchUpdates+1)
+	for i := range updates {
+		updates[i] = BatchUpdate{DistinctId: "13793", Update: Update{Operation: "$set"}}
+	}
+	if err := client.UpdateBatch(updates, RequestHeader("X-Idempotency-Key", "update-1")); err != nil {
+		t.Fatalf("UpdateBatch returned %v", err)
+	}
+
+	want := []string{"/import import-1", "/engage update-1", "/engage update-1"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("requests returned %+v, want %+v", keys, want)
+	}
+}
+
+func TestUpdateBatchErrorClassification(t *testing.T) {
+	var requests int
+	statuses := []int{200, 429, 400, 503}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := statuses[requests]
+		requests++
+		w.WriteHeader(status)
+		if status == 200 {
+			w.Write([]byte(`{"status":1,"error":null}`))
+		} else {
+			w.Write([]byte(`{"status":0,"error":"failed"}`))
+		}
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+
+	updates := make([]BatchUpdate, 3*MaxBatchUpdates+1)
+	for i := range updates {
+		updates[i] = BatchUpdate{DistinctId: "13793", Update: Update{Operation: "$set"}}
+	}
+
+	err := client.UpdateBatch(updates)
+
+	batchErr, ok := err.(*BatchError)
+	if !ok {
+		t.Fatalf("UpdateBatch returned %v, want a *BatchError", err)
+	}
+
+	type chunk struct {
+		Index, Start, End int
+		Retryable         bool
+	}
+	var got []chunk
+	for _, c := range batchErr.Chunks {
+		got = append(got, chunk{c.Index, c.Start, c.End, c.Retryable})
+	}
+	want := []chunk{
+		{1, 50, 100, true},
+		{2, 100, 150, false},
+		{3, 150, 151, true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UpdateBatch failed chunks %+v, want %+v", got, want)
+	}
+	if n := len(batchErr.Retryable()); n != 2 {
+		t.Errorf("Retryable returned %d chunks, want 2", n)
+	}
+}
+
+func TestUpdateBatchCircuitOpen(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithCircuitBreaker(BreakerOptions{Failures: 1, CoolDown: time.Hour}))
+
+	updates := make([]BatchUpdate, MaxBatchUpdates+1)
+	for i := range updates {
+		updates[i] = BatchUpdate{DistinctId: "13793", Update: Update{Operation: "$set"}}
+	}
+
+	var batchErr *BatchError
+	if err := client.UpdateBatch(updates); !errors.As(err, &batchErr) {
+		t.Fatalf("UpdateBatch returned %v, want a *BatchError", err)
+	}
+	if len(batchErr.Chunks) != 2 || !errors.Is(batchErr.Chunks[1].Err, ErrCircuitOpen) {
+		t.Fatalf("UpdateBatch failed chunks %+v, want the second refused by the breaker", batchErr.Chunks)
+	}
+	if n := len(batchErr.Retryable()); n != 2 {
+		t.Errorf("Retryable returned %d chunks, want 2", n)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&MixpanelError{HttpStatus: 429}, true},
+		{&MixpanelError{HttpStatus: 500}, true},
+		{&MixpanelError{HttpStatus: 502}, true},
+		{&MixpanelError{Message: "connection refused"}, true},
+		{&MixpanelError{HttpStatus: 400}, false},
+		{&MixpanelError{HttpStatus: 200, Code: 0}, false},
+		{ErrSelfAlias, false},
+	}
+	for _, c := range cases {
+		if got := IsRetryable(c.err); got != c.want {
+			t.Errorf("IsRetryable(%v) returned %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestImportBatchChunks(t *testing.T) {
+	var (
+		requests []int
+		header   http.Header
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		var records []map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&records); err != nil {
+			t.Errorf("decoding request body: %v", err)
+		}
+		requests = append(requests, len(records))
+		if r.Method != http.MethodPost || r.URL.Path != "/import" {
+			t.Errorf("request returned %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"code":200,"num_records_imported":1,"status":"OK"}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL)
+
+	at := time.Date(2016, 3, 3, 15, 17, 53, 0, time.UTC)
+	events := make([]BatchEvent, 2500)
+	for i := range events {
+		events[i] = BatchEvent{DistinctId: "13793", EventName: "Signed Up", Event: Event{IP: "0", Timestamp: &at}}
+	}
+
+	if err := client.ImportBatch(events); err != nil {
+		t.Fatalf("ImportBatch returned %v", err)
+	}
+	if want := []int{2000, 500}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("ImportBatch sent chunks of %+v, want %+v", requests, want)
+	}
+	if got := header.Get("Authorization"); got != "Basic c2VjcmV0Og==" {
+		t.Errorf("Authorization returned %+v", got)
+	}
+}
+
+func TestImportBatchWithoutTimestamp(t *testing.T) {
+	var records []map[string]map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&records)
+		w.Write([]byte(`{"code":200,"num_records_imported":1,"status":"OK"}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL)
+	if err := client.ImportBatch([]BatchEvent{{DistinctId: "13793", EventName: "Signed Up"}}); err != nil {
+		t.Fatalf("ImportBatch returned %v", err)
+	}
+
+	if len(records) != 1 || records[0]["properties"]["time"] == nil {
+		t.Errorf("ImportBatch sent %+v, want an event with a time", records)
+	}
+}
+
+func TestImportBatchPartialFailure(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":400,"error":"some data points in the request failed validation","status":"Bad Request"}`))
+			return
+		}
+		w.Write([]byte(`{"code":200,"num_records_imported":1,"status":"OK"}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL)
+
+	at := time.Date(2016, 3, 3, 15, 17, 53, 0, time.UTC)
+	events := make([]BatchEvent, 2500)
+	for i := range events {
+		events[i] = BatchEvent{DistinctId: "13793", EventName: "Signed Up", Event: Event{Timestamp: &at}}
+	}
+
+	err := client.ImportBatch(events)
+	batchErr, ok := err.(*BatchError)
+	if !ok || len(batchErr.Chunks) != 1 {
+		t.Fatalf("ImportBatch returned %+v, want a *BatchError for one chunk", err)
+	}
+	if c := batchErr.Chunks[0]; c.Index != 1 || c.Start != 2000 || c.End != 2500 {
+		t.Errorf("failed chunk returned %+v, want index 1, items 2000 to 2500", c)
+	}
+}
+
+func TestImportBatchTokens(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var records []ExportedEvent
+		json.NewDecoder(r.Body).Decode(&records)
+		tokens := ""
+		for _, record := range records {
+			tokens += record.Properties["token"].(string) + " "
+		}
+		requests = append(requests, tokens)
+		w.Write([]byte(`{"code":200,"num_records_imported":1,"status":"OK"}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL)
+
+	at := time.Date(2016, 3, 3, 15, 17, 53, 0, time.UTC)
+	event := Event{Timestamp: &at}
+	err := client.ImportBatch([]BatchEvent{
+		{DistinctId: "13793", EventName: "Signed Up", Event: event, Token: "tenant-a"},
+		{DistinctId: "13794", EventName: "Signed Up", Event: event},
+		{DistinctId: "13795", EventName: "Signed Up", Event: event, Token: "tenant-a"},
+	})
+	if err != nil {
+		t.Fatalf("ImportBatch returned %v", err)
+	}
+
+	want := []string{"tenant-a tenant-a ", "e3bc4100330c35722740fb8c6f5abddc "}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests returned %+v, want %+v", requests, want)
+	}
+}
+
+func TestUpdateBatchMatchesUpdate(t *testing.T) {
+	setup()
+	defer teardown()
+
+	ts := time.Unix(1600000000, 0)
+	updates := []Update{
+		{IP: "127.0.0.1", Timestamp: &ts, Operation: "$set", Properties: map[string]interface{}{"Plan": "pro"}},
+		{IP: "0", Timestamp: IgnoreTime, Operation: "$union", Properties: map[string]interface{}{"Tags": []string{"a"}}},
+	}
+
+	for _, u := range updates {
+		u := u
+		client.Update("13793", &u)
+		single := decodeData(LastRequest)
+
+		client.UpdateBatch([]BatchUpdate{{DistinctId: "13793", Update: u}})
+		batch := decodeData(LastRequest)
+
+		if want := "[" + single + "]"; batch != want {
+			t.Errorf("UpdateBatch sent %+v, want %+v", batch, want)
+		}
+	}
+}
+
+func TestTrackBatch(t *testing.T) {
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/track" {
+			t.Errorf("path returned %+v, want %+v", r.URL.Path, "/track")
+		}
+		bodies = append(bodies, decodeData(r))
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+
+	events := make([]BatchEvent, MaxTrackBatch+1)
+	for i := range events {
+		events[i] = BatchEvent{DistinctId: strconv.Itoa(i), EventName: "Signed Up", Event: Event{IP: "0"}}
+	}
+
+	if err := client.TrackBatch(events); err != nil {
+		t.Fatalf("TrackBatch returned %v", err)
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("TrackBatch sent %d requests, want 2", len(bodies))
+	}
+
+	var first, last []map[string]interface{}
+	json.Unmarshal([]byte(bodies[0]), &first)
+	json.Unmarshal([]byte(bodies[1]), &last)
+	if len(first) != MaxTrackBatch || len(last) != 1 {
+		t.Errorf("chunks returned %d and %d events, want %d and 1", len(first), len(last), MaxTrackBatch)
+	}
+
+	want := map[string]interface{}{
+		"event": "Signed Up",
+		"properties": map[string]interface{}{
+			"distinct_id": "50",
+			"ip":          "0",
+			"token":       "e3bc4100330c35722740fb8c6f5abddc",
+		},
+	}
+	if len(last) == 1 && !reflect.DeepEqual(last[0], want) {
+		t.Errorf("last event returned %+v, want %+v", last[0], want)
+	}
+}
+
+func TestBatchInvalidEvent(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL, WithStrictMode())
+	events := []BatchEvent{
+		{DistinctId: "13793", EventName: "Signed Up"},
+		{DistinctId: "13794", EventName: "Signed Up", Event: Event{Properties: map[string]interface{}{"$email": 42}}},
+	}
+
+	for name, batch := range map[string]func([]BatchEvent, ...CallOption) error{
+		"TrackBatch":  client.TrackBatch,
+		"ImportBatch": client.ImportBatch,
+	} {
+		LastRequest = nil
+		err := batch(events)
+
+		var itemErr *BatchItemError
+		if !errors.As(err, &itemErr) || itemErr.Index != 1 || itemErr.DistinctId != "13794" {
+			t.Errorf("%s returned %v, want an error for item 1", name, err)
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%s returned %v, want it to wrap a *ValidationError", name, err)
+		}
+		if LastRequest != nil {
+			t.Errorf("%s sent a request for an invalid batch", name)
+		}
+	}
+
+	// ImportNDJSON streams the events, so it fails once the request started.
+	var itemErr *BatchItemError
+	if err := client.ImportNDJSON(events); !errors.As(err, &itemErr) || itemErr.Index != 1 {
+		t.Errorf("ImportNDJSON returned %v, want an error for item 1", err)
+	}
+}
+
+func TestBatchGeolocateOptOut(t *testing.T) {
+	var (
+		query   string
+		records []map[string]interface{}
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		if r.URL.Path == "/import" {
+			json.NewDecoder(r.Body).Decode(&records)
+			w.Write([]byte(`{"code":200,"num_records_imported":2,"status":"OK"}`))
+			return
+		}
+		json.Unmarshal([]byte(decodeData(r)), &records)
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL)
+	no := false
+
+	client.UpdateBatch([]BatchUpdate{
+		{DistinctId: "13793", Update: Update{Operation: "$set"}},
+		{DistinctId: "13794", Update: Update{Operation: "$set", Geolocate: &no}},
+	})
+	if !strings.Contains(query, "ip=1") {
+		t.Errorf("UpdateBatch query returned %+v, want ip=1", query)
+	}
+	if len(records) != 2 || records[0]["$ip"] != nil || records[1]["$ip"] != "0" {
+		t.Errorf("UpdateBatch sent %+v, want $ip 0 for the second record only", records)
+	}
+
+	records = nil
+	at := time.Now()
+	client.ImportBatch([]BatchEvent{
+		{DistinctId: "13793", EventName: "Signed Up", Event: Event{Timestamp: &at}},
+		{DistinctId: "13794", EventName: "Signed Up", Event: Event{Timestamp: &at, Geolocate: &no}},
+	})
+	if !strings.Contains(query, "ip=1") {
+		t.Errorf("ImportBatch query returned %+v, want ip=1", query)
+	}
+	var ips []interface{}
+	for _, record := range records {
+		ips = append(ips, record["properties"].(map[string]interface{})["ip"])
+	}
+	if want := []interface{}{nil, "0"}; !reflect.DeepEqual(ips, want) {
+		t.Errorf("ImportBatch sent ips %+v, want %+v", ips, want)
+	}
+
+	records, ips = nil, nil
+	client.TrackBatch([]BatchEvent{
+		{DistinctId: "13793", EventName: "Signed Up"},
+		{DistinctId: "13794", EventName: "Signed Up", Event: Event{Geolocate: &no}},
+	})
+	if !strings.Contains(query, "ip=1") {
+		t.Errorf("TrackBatch query returned %+v, want ip=1", query)
+	}
+	for _, record := range records {
+		ips = append(ips, record["properties"].(map[string]interface{})["ip"])
+	}
+	if want := []interface{}{nil, "0"}; !reflect.DeepEqual(ips, want) {
+		t.Errorf("TrackBatch sent ips %+v, want %+v", ips, want)
+	}
+}
@@ -0,0 +1,174 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by the ingestion calls while the circuit breaker
+// enabled by WithCircuitBreaker is open.
+var ErrCircuitOpen = errors.New("mixpanel: circuit breaker is open")
+
+// BreakerOptions configures the circuit breaker of WithCircuitBreaker.
+type BreakerOptions struct {
+	// Failures is the number of consecutive failed calls that open the
+	// breaker. The default is 5.
+	Failures int
+
+	// CoolDown is how long the breaker stays open. Once it elapses, calls go
+	// through again: the first success closes the breaker, and a failure
+	// opens it for another CoolDown. The default is 30 seconds.
+	CoolDown time.Duration
+
+	// Drop makes the calls made while the breaker is open return nil instead
+	// of ErrCircuitOpen, dropping their data.
+	Drop bool
+}
+
+// A BreakerState is the state of a circuit breaker.
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// Health describes the circuit breaker of a client, as returned by Health.
+type Health struct {
+	State BreakerState
+
+	// Failures is the number of consecutive failed calls.
+	Failures int
+
+	// OpenedAt is when the breaker last opened, zero if it never did.
+	OpenedAt time.Time
+
+	// Rejected is the number of calls failed with ErrCircuitOpen or dropped
+	// since the client was created.
+	Rejected int64
+}
+
+// WithCircuitBreaker stops sending the ingestion calls, such as Track, Update
+// and each chunk of the batch calls, once opts.Failures calls in a row failed,
+// so that a slow or unavailable Mixpanel does not stall the callers. A call
+// fails when it gets no response, is rate limited, gets a server error or
+// times out; requests Mixpanel rejects as invalid do not count. While the
+// breaker is open, calls fail at once with ErrCircuitOpen, or are dropped
+// with opts.Drop, until opts.CoolDown elapses. Use WithTimeout to bound the
+// time a call may take before it counts as failed. Health reports the state
+// of the breaker.
+func WithCircuitBreaker(opts BreakerOptions) Option {
+	return func(m *mixpanel) {
+		if opts.Failures <= 0 {
+			opts.Failures = 5
+		}
+		if opts.CoolDown <= 0 {
+			opts.CoolDown = 30 * time.Second
+		}
+		m.breaker = &circuitBreaker{opts: opts}
+	}
+}
+
+// Health returns the state of the circuit breaker of the client. Without
+// WithCircuitBreaker, the breaker is always closed.
+func (m *mixpanel) Health() Health {
+	if m.breaker == nil {
+		return Health{State: BreakerClosed}
+	}
+	return m.breaker.health()
+}
+
+// circuitBreaker is the breaker of WithCircuitBreaker. It is safe for
+// concurrent use.
+type circuitBreaker struct {
+	opts BreakerOptions
+	now  func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	rejected int64
+}
+
+func (b *circuitBreaker) time() time.Time {
+	if b.now != nil {
+		return b.now()
+	}
+	return time.Now()
+}
+
+// state returns the state of the breaker. b.mu must be held.
+func (b *circuitBreaker) state() BreakerState {
+	switch {
+	case b.failures < b.opts.Failures:
+		return BreakerClosed
+	case b.time().Sub(b.openedAt) < b.opts.CoolDown:
+		return BreakerOpen
+	}
+	return BreakerHalfOpen
+}
+
+// allow reports whether a call may be sent, and otherwise returns the error
+// the call returns, nil if it is dropped.
+func (b *circuitBreaker) allow() (bool, error) {
+	if b == nil {
+		return true, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state() != BreakerOpen {
+		return true, nil
+	}
+	b.rejected++
+	if b.opts.Drop {
+		return false, nil
+	}
+	return false, ErrCircuitOpen
+}
+
+// record records the outcome of a call that was sent.
+func (b *circuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !breakerFailure(err) {
+		b.failures = 0
+		return
+	}
+
+	state := b.state()
+	b.failures++
+	if state == BreakerHalfOpen || b.failures == b.opts.Failures {
+		b.openedAt = b.time()
+	}
+}
+
+func (b *circuitBreaker) health() Health {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return Health{
+		State:    b.state(),
+		Failures: b.failures,
+		OpenedAt: b.openedAt,
+		Rejected: b.rejected,
+	}
+}
+
+// breakerFailure reports whether err, returned by a call, counts as a
+// failure for the circuit breaker.
+func breakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	return IsRetryable(err) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,107 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var requests int32
+	status := int32(http.StatusServiceUnavailable)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if code := atomic.LoadInt32(&status); code != http.StatusOK {
+			w.WriteHeader(int(code))
+			return
+		}
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	now := time.Now()
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithCircuitBreaker(BreakerOptions{Failures: 2, CoolDown: time.Minute})).(*mixpanel)
+	client.breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if err := client.Track("13793", "Signed Up", &Event{}); err == nil {
+			t.Fatalf("Track returned no error for a 503")
+		}
+	}
+	if got := client.Health(); got.State != BreakerOpen || got.Failures != 2 || !got.OpenedAt.Equal(now) {
+		t.Errorf("Health returned %+v, want an open breaker", got)
+	}
+
+	if err := client.Track("13793", "Signed Up", &Event{}); err != ErrCircuitOpen {
+		t.Errorf("Track returned %v, want %v", err, ErrCircuitOpen)
+	}
+	if got := atomic.LoadInt32(&requests); got != 2 {
+		t.Errorf("requests returned %+v, want %+v", got, 2)
+	}
+
+	// After the cool-down, a failure opens the breaker again, and a success
+	// closes it.
+	now = now.Add(time.Minute)
+	if got := client.Health().State; got != BreakerHalfOpen {
+		t.Errorf("State returned %+v, want %+v", got, BreakerHalfOpen)
+	}
+	client.Track("13793", "Signed Up", &Event{})
+	if got := client.Health().State; got != BreakerOpen {
+		t.Errorf("State returned %+v, want %+v", got, BreakerOpen)
+	}
+
+	now = now.Add(time.Minute)
+	atomic.StoreInt32(&status, http.StatusOK)
+	if err := client.Track("13793", "Signed Up", &Event{}); err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+	if got := client.Health(); got.State != BreakerClosed || got.Failures != 0 || got.Rejected != 1 {
+		t.Errorf("Health returned %+v, want a closed breaker", got)
+	}
+}
+
+func TestCircuitBreakerDrop(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithTimeout(10*time.Millisecond),
+		WithCircuitBreaker(BreakerOptions{Failures: 1, Drop: true}))
+
+	if err := client.Track("13793", "Signed Up", &Event{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Track returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := client.Track("13793", "Signed Up", &Event{}); err != nil {
+		t.Errorf("Track returned %v for a dropped call", err)
+	}
+	if got := client.Health().Rejected; got != 1 {
+		t.Errorf("Rejected returned %+v, want %+v", got, 1)
+	}
+}
+
+func TestBreakerFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{&MixpanelError{HttpStatus: 503}, true},
+		{&MixpanelError{HttpStatus: 400}, false},
+		{&MixpanelError{Err: context.DeadlineExceeded}, true},
+		{&MixpanelError{Err: context.Canceled}, false},
+		{ErrNoProperties, false},
+	}
+
+	for _, tt := range tests {
+		if got := breakerFailure(tt.err); got != tt.want {
+			t.Errorf("breakerFailure(%v) returned %+v, want %+v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package mixpanel
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrBufferFull is returned by a Buffered client when an operation fits
+// neither in memory nor in the spill file.
+var ErrBufferFull = errors.New("mixpanel: buffer is full")
+
+// BufferOptions configures a Buffered client.
+type BufferOptions struct {
+	// Size is the number of operations kept in memory. The default is 1000.
+	Size int
+
+	// SpillPath is a file that operations are appended to once the memory
+	// queue is full, instead of being rejected with ErrBufferFull. The file
+	// survives restarts: operations left in it are sent by the next Flush of
+	// a Buffered client using the same path, before anything queued later.
+	//
+	// The file holds one JSON-encoded Operation per line, in the order the
+	// operations were made.
+	SpillPath string
+
+	// MaxSpill caps the number of operations in the spill file. Zero means
+	// no cap.
+	MaxSpill int
+
+	// Store persists the operations queued in memory, so that they survive a
+	// crash or a restart: every operation is appended to it before the call
+	// returns, and removed once it is sent. Operations left in the store are
+	// queued by NewBuffered, before anything queued later, whatever Size is.
+	// Since an operation is removed after it is sent, a crash in between
+	// sends it again: delivery is at least once. See NewFileStore.
+	Store Store
+
+	// FlushInterval makes a background goroutine flush the queue this often.
+	// Zero means operations are only sent by Flush, Close and BatchSize.
+	FlushInterval time.Duration
+
+	// BatchSize makes the background goroutine flush the queue as soon as it
+	// holds this many operations. Zero means no threshold.
+	BatchSize int
+
+	// OnError is called with the *OperationError of every operation a
+	// flush drops because it failed permanently, see Flush, and with the
+	// error of every background flush stopped by a transient failure. The
+	// operation of a transient failure stays queued and is retried by the
+	// next flush, so the same failure may be reported several times. With
+	// no OnError, both kinds of errors are dropped.
+	OnError func(error)
+}
+
+// Buffered wraps a client and queues Track, Update and Alias calls, and their
+// Ctx variants, until Flush is called, or until the background flush enabled
+// by BufferOptions.FlushInterval and BatchSize runs. Other methods are passed
+// straight through. Queued calls are recorded as Operations, so call options
+// and the contexts given to the Ctx variants are not kept.
+type Buffered struct {
+	Mixpanel
+
+	opts BufferOptions
+
+	mu      sync.Mutex
+	queue   []Operation
+	spilled int
+
+	// flushMu makes flushes run one at a time, without holding mu while
+	// they send.
+	flushMu sync.Mutex
+
+	// kick asks the background goroutine, if any, to flush; stop makes it
+	// return and done is closed once it has.
+	kick      chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewBuffered returns a Buffered client sending through client. If the store
+// or the spill file already hold operations, they are sent first by the next
+// Flush. With a FlushInterval or a BatchSize, a background goroutine flushes
+// the queue until Close is called.
+func NewBuffered(client Mixpanel, opts BufferOptions) (*Buffered, error) {
+	if opts.Size <= 0 {
+		opts.Size = 1000
+	}
+
+	b := &Buffered{Mixpanel: client, opts: opts}
+
+	if opts.Store != nil {
+		ops, err := opts.Store.Load()
+		if err != nil {
+			return nil, err
+		}
+		b.queue = ops
+	}
+
+	if opts.SpillPath != "" {
+		ops, err := b.readSpill()
+		if err != nil {
+			return nil, err
+		}
+		b.spilled = len(ops)
+	}
+
+	if opts.FlushInterval > 0 || opts.BatchSize > 0 {
+		b.kick = make(chan struct{}, 1)
+		b.stop = make(chan struct{})
+		b.done = make(chan struct{})
+		b.checkBatchSize()
+		go b.flushLoop()
+	}
+
+	return b, nil
+}
+
+// flushLoop flushes the queue every FlushInterval and whenever enqueue
+// reaches BatchSize, until stop is closed.
+func (b *Buffered) flushLoop() {
+	defer close(b.done)
+
+	var tick <-chan time.Time
+	if b.opts.FlushInterval > 0 {
+		ticker := time.NewTicker(b.opts.FlushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+		case <-b.kick:
+		case <-b.stop:
+			return
+		}
+
+		if err := b.Flush(); err != nil && b.opts.OnError != nil {
+			b.opts.OnError(err)
+		}
+	}
+}
+
+func (b *Buffered) Track(distinctId, eventName string, e *Event, opts ...CallOption) error {
+	return b.enqueue(TrackOperation(distinctId, eventName, e))
+}
+
+func (b *Buffered) TrackCtx(ctx context.Context, distinctId, eventName string, e *Event, opts ...CallOption) error {
+	return b.enqueue(TrackOperation(distinctId, eventName, e))
+}
+
+func (b *Buffered) Update(distinctId string, u *Update, opts ...CallOption) error {
+	return b.enqueue(UpdateOperation(distinctId, u))
+}
+
+func (b *Buffered) UpdateCtx(ctx context.Context, distinctId string, u *Update, opts ...CallOption) error {
+	return b.enqueue(UpdateOperation(distinctId, u))
+}
+
+func (b *Buffered) Alias(distinctId, newId string, opts ...CallOption) error {
+	return b.enqueue(AliasOperation(distinctId, newId))
+}
+
+func (b *Buffered) AliasCtx(ctx context.Context, distinctId, newId string, opts ...CallOption) error {
+	return b.enqueue(AliasOperation(distinctId, newId))
+}
+
+// Len returns the number of queued operations, in memory and spilled.
+func (b *Buffered) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.queue) + b.spilled
+}
+
+func (b *Buffered) enqueue(op Operation) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Once anything is spilled, later operations are spilled too so that
+	// they are sent in order.
+	if b.spilled == 0 && len(b.queue) < b.opts.Size {
+		if b.opts.Store != nil {
+			if err := b.opts.Store.Append([]Operation{op}); err != nil {
+				return err
+			}
+		}
+		b.queue = append(b.queue, op)
+		b.checkBatchSize()
+		return nil
+	}
+
+	if b.opts.SpillPath == "" || (b.opts.MaxSpill > 0 && b.spilled >= b.opts.MaxSpill) {
+		return ErrBufferFull
+	}
+
+	if err := b.appendSpill([]Operation{op}); err != nil {
+		return err
+	}
+	b.spilled++
+	b.checkBatchSize()
+
+	return nil
+}
+
+// checkBatchSize kicks the background goroutine once the queue reaches
+// BatchSize. b.mu must be held.
+func (b *Buffered) checkBatchSize() {
+	if b.opts.BatchSize <= 0 || len(b.queue)+b.spilled < b.opts.BatchSize {
+		return
+	}
+
+	select {
+	case b.kick <- struct{}{}:
+	default:
+		// A flush is already pending.
+	}
+}
+
+// Flush sends the queued operations in order, starting with those in memory
+// and then those in the spill file. It stops at the first transient failure,
+// one IsRetryable reports or ErrCircuitOpen, keeping the failed operation and
+// everything after it queued, and returns an *OperationError for it.
+//
+// An operation failing permanently, such as an invalid one or one Mixpanel
+// rejects with a 400, would fail the same way on every flush and hold up the
+// operations after it: it is dropped instead, and reported to OnError as an
+// *OperationError, whose Index is its position among the operations the
+// flush sent.
+//
+// Calls made while Flush sends are queued without waiting for it, and are
+// sent by the next flush. Concurrent flushes run one at a time.
+func (b *Buffered) Flush() error {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	b.mu.Lock()
+	queued := append([]Operation(nil), b.queue...)
+	b.mu.Unlock()
+
+	done, err := b.apply(queued)
+
+	// Calls only append to the queue and the store, so the operations done
+	// with are still at their head.
+	b.mu.Lock()
+	b.queue = b.queue[done:]
+	storeErr := b.removeStored(done)
+	spilled := b.spilled
+	b.mu.Unlock()
+
+	if storeErr != nil {
+		return storeErr
+	}
+	if err != nil || spilled == 0 {
+		return err
+	}
+
+	b.mu.Lock()
+	ops, err := b.readSpill()
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	done, err = b.apply(ops)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Reread the spill file for the operations spilled while sending.
+	current, readErr := b.readSpill()
+	if readErr != nil {
+		return readErr
+	}
+	rest := current[done:]
+	b.spilled = len(rest)
+	if len(rest) == 0 {
+		if removeErr := os.Remove(b.opts.SpillPath); removeErr != nil {
+			return removeErr
+		}
+		return err
+	}
+	if writeErr := b.writeSpill(rest); writeErr != nil {
+		return writeErr
+	}
+	return err
+}
+
+// apply sends ops in order, dropping those failing permanently, as described
+// by Flush. It returns the number of operations done with, sent or dropped,
+// and the error of the transient failure that stopped it, if any.
+func (b *Buffered) apply(ops []Operation) (int, error) {
+	for i, op := range ops {
+		err := applyOperation(b.Mixpanel, op)
+		if err == nil {
+			continue
+		}
+
+		opErr := &OperationError{Index: i, Operation: op, Err: err}
+		if isTransient(err) {
+			return i, opErr
+		}
+		if b.opts.OnError != nil {
+			b.opts.OnError(opErr)
+		}
+	}
+
+	return len(ops), nil
+}
+
+// Close stops the background goroutine, if any, and flushes the queued
+// operations.
+func (b *Buffered) Close() error {
+	if b.stop != nil {
+		b.closeOnce.Do(func() {
+			close(b.stop)
+			<-b.done
+		})
+	}
+
+	return b.Flush()
+}
+
+// removeStored removes the first n operations, which were sent, from the
+// store, if any.
+func (b *Buffered) removeStored(n int) error {
+	if b.opts.Store == nil || n == 0 {
+		return nil
+	}
+	return b.opts.Store.Remove(n)
+}
+
+func (b *Buffered) readSpill() ([]Operation, error) {
+	return readOperations(b.opts.SpillPath)
+}
+
+// readOperations reads the operations written to path by writeOperations. A
+// missing file holds no operations.
+func readOperations(path string) ([]Operation, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ops []Operation
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<24)
+	for scanner.Scan() {
+		var op Operation
+		if err := json.Unmarshal(scanner.Bytes(), &op); err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+
+	return ops, scanner.Err()
+}
+
+func (b *Buffered) appendSpill(ops []Operation) error {
+	f, err := os.OpenFile(b.opts.SpillPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	return writeOperations(f, ops)
+}
+
+func (b *Buffered) writeSpill(ops []Operation) error {
+	f, err := os.OpenFile(b.opts.SpillPath, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	return writeOperations(f, ops)
+}
+
+// writeOperations writes ops to f, one JSON object per line, and closes it.
+// The operations are synced to disk, so that they survive a crash.
+func writeOperations(f *os.File, ops []Operation) error {
+	enc := json.NewEncoder(f)
+	for _, op := range ops {
+		if err := enc.Encode(op); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
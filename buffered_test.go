@@ -0,0 +1,256 @@
+package mixpanel
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func countLines(t *testing.T, path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening spill file: %v", err)
+	}
+	defer f.Close()
+
+	n := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		n++
+	}
+	return n
+}
+
+func eventNames(p *MockPeople) []string {
+	var names []string
+	for _, e := range p.Events {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+func TestBufferedFlush(t *testing.T) {
+	mock := NewMock()
+	b, _ := NewBuffered(mock, BufferOptions{})
+
+	b.Track("13793", "Signed Up", &Event{})
+	b.Update("13793", &Update{Operation: "$set", Properties: map[string]interface{}{"Plan": "pro"}})
+
+	if len(mock.People) != 0 {
+		t.Fatalf("Buffered sent operations before Flush")
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+
+	p := mock.People["13793"]
+	if !reflect.DeepEqual(eventNames(p), []string{"Signed Up"}) || p.Properties["Plan"] != "pro" {
+		t.Errorf("Close sent %s", mock)
+	}
+}
+
+func TestBufferedUnset(t *testing.T) {
+	mock := NewMock()
+	b, _ := NewBuffered(mock, BufferOptions{})
+
+	b.Update("13793", &Update{Operation: OpSet, Properties: map[string]interface{}{"Plan": "pro", "Trial": true}})
+	b.Update("13793", &Update{Operation: OpUnset, Unset: []string{"Trial"}})
+
+	if err := b.Flush(); err != nil {
+		t.Fatalf("Flush returned %v", err)
+	}
+
+	want := map[string]interface{}{"Plan": "pro"}
+	if got := mock.People["13793"].Properties; !reflect.DeepEqual(got, want) {
+		t.Errorf("Properties returned %+v, want %+v", got, want)
+	}
+}
+
+func TestBufferedFull(t *testing.T) {
+	b, _ := NewBuffered(NewMock(), BufferOptions{Size: 1})
+
+	b.Track("13793", "1", &Event{})
+	if err := b.Track("13793", "2", &Event{}); err != ErrBufferFull {
+		t.Errorf("Track returned %v, want %v", err, ErrBufferFull)
+	}
+}
+
+func TestBufferedSpill(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spill.ndjson")
+
+	b, err := NewBuffered(NewMock(), BufferOptions{Size: 2, SpillPath: path, MaxSpill: 3})
+	if err != nil {
+		t.Fatalf("NewBuffered returned %v", err)
+	}
+
+	for i := 1; i <= 5; i++ {
+		if err := b.Track("13793", strconv.Itoa(i), &Event{}); err != nil {
+			t.Fatalf("Track returned %v", err)
+		}
+	}
+	if err := b.Track("13793", "6", &Event{}); err != ErrBufferFull {
+		t.Errorf("Track returned %v, want %v", err, ErrBufferFull)
+	}
+
+	if n := countLines(t, path); n != 3 {
+		t.Errorf("spill file has %d operations, want 3", n)
+	}
+
+	// A new client on the same file, as after a restart, drains the spill
+	// file before operations queued later.
+	mock := NewMock()
+	b, err = NewBuffered(mock, BufferOptions{Size: 2, SpillPath: path})
+	if err != nil {
+		t.Fatalf("NewBuffered returned %v", err)
+	}
+	if b.Len() != 3 {
+		t.Errorf("Len returned %d, want 3", b.Len())
+	}
+
+	b.Track("13793", "7", &Event{})
+
+	if err := b.Flush(); err != nil {
+		t.Fatalf("Flush returned %v", err)
+	}
+
+	want := []string{"3", "4", "5", "7"}
+	if got := eventNames(mock.People["13793"]); !reflect.DeepEqual(got, want) {
+		t.Errorf("Flush sent %+v, want %+v", got, want)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("spill file still exists after Flush")
+	}
+}
+
+// waitEmpty waits for the background flush of b to empty its queue.
+func waitEmpty(t *testing.T, b *Buffered) {
+	deadline := time.Now().Add(time.Second)
+	for b.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("queue still holds %d operations", b.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBufferedBatchSize(t *testing.T) {
+	mock := NewMock()
+	b, _ := NewBuffered(mock, BufferOptions{BatchSize: 2})
+	defer b.Close()
+
+	b.Track("13793", "1", &Event{})
+	if b.Len() != 1 {
+		t.Errorf("Len returned %d below BatchSize, want 1", b.Len())
+	}
+
+	b.Track("13793", "2", &Event{})
+	waitEmpty(t, b)
+
+	if got := eventNames(mock.People["13793"]); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("background flush sent %+v, want %+v", got, []string{"1", "2"})
+	}
+}
+
+func TestBufferedFlushInterval(t *testing.T) {
+	errs := make(chan error, 10)
+	mock := NewMock()
+	b, _ := NewBuffered(mock, BufferOptions{
+		FlushInterval: 10 * time.Millisecond,
+		OnError:       func(err error) { errs <- err },
+	})
+
+	b.Track("13793", "Signed Up", &Event{})
+	waitEmpty(t, b)
+
+	// A transient failure keeps the event queued.
+	mock.FailNext(&MixpanelError{HttpStatus: 503, Message: "unavailable"})
+	b.Track("13793", "Logged In", &Event{})
+
+	select {
+	case err := <-errs:
+		if _, ok := err.(*OperationError); !ok {
+			t.Errorf("OnError got %v, want an *OperationError", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("OnError was not called")
+	}
+	waitEmpty(t, b)
+
+	if got := eventNames(mock.People["13793"]); !reflect.DeepEqual(got, []string{"Signed Up", "Logged In"}) {
+		t.Errorf("background flush sent %+v, want the failed event retried", got)
+	}
+	if err := b.Close(); err != nil {
+		t.Errorf("Close returned %v", err)
+	}
+}
+
+func TestBufferedDropsPermanentFailures(t *testing.T) {
+	var dropped []*OperationError
+	mock := NewMock()
+	b, _ := NewBuffered(mock, BufferOptions{
+		OnError: func(err error) { dropped = append(dropped, err.(*OperationError)) },
+	})
+
+	b.Alias("13793", "13793")
+	b.Track("13793", "Signed Up", &Event{})
+
+	if err := b.Flush(); err != nil {
+		t.Fatalf("Flush returned %v", err)
+	}
+	if b.Len() != 0 {
+		t.Errorf("Len returned %d after Flush, want the failed alias dropped", b.Len())
+	}
+	if len(dropped) != 1 || dropped[0].Err != ErrSelfAlias || dropped[0].Operation.Type != OperationAlias {
+		t.Errorf("OnError got %+v, want the failed alias", dropped)
+	}
+	if got := eventNames(mock.People["13793"]); !reflect.DeepEqual(got, []string{"Signed Up"}) {
+		t.Errorf("Flush sent %+v, want %+v", got, []string{"Signed Up"})
+	}
+}
+
+// blockingClient blocks Track until release is closed.
+type blockingClient struct {
+	*Mock
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingClient) Track(distinctId, eventName string, e *Event, opts ...CallOption) error {
+	c.started <- struct{}{}
+	<-c.release
+	return c.Mock.Track(distinctId, eventName, e, opts...)
+}
+
+func TestBufferedFlushDoesNotBlockCalls(t *testing.T) {
+	client := &blockingClient{Mock: NewMock(), started: make(chan struct{}, 1), release: make(chan struct{})}
+	b, _ := NewBuffered(client, BufferOptions{})
+
+	b.Track("13793", "1", &Event{})
+	flushed := make(chan error)
+	go func() { flushed <- b.Flush() }()
+	<-client.started
+
+	queued := make(chan struct{})
+	go func() {
+		b.Track("13793", "2", &Event{})
+		close(queued)
+	}()
+	select {
+	case <-queued:
+	case <-time.After(time.Second):
+		t.Fatalf("Track blocked while Flush was sending")
+	}
+
+	close(client.release)
+	if err := <-flushed; err != nil {
+		t.Fatalf("Flush returned %v", err)
+	}
+	if b.Len() != 1 {
+		t.Errorf("Len returned %d, want the event queued during Flush kept", b.Len())
+	}
+}
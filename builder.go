@@ -0,0 +1,301 @@
+package mixpanel
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrNoEventName is returned by EventBuilder.Build for an event without a
+// name.
+var ErrNoEventName = errors.New("mixpanel: event has no name")
+
+// MaxPropertyValueLength is the longest string property value, in bytes,
+// Mixpanel stores. Longer values are truncated by Mixpanel. Unlike
+// MaxPropertyNameLength, it counts the bytes of the UTF-8 encoding, the
+// stricter reading of the limit, so that a value the builders accept is
+// never truncated, even when it is not ASCII.
+const MaxPropertyValueLength = 255
+
+// MaxPropertyDepth is the deepest nesting of lists and objects Mixpanel
+// accepts in a property value. A list of strings is nested one level deep.
+const MaxPropertyDepth = 3
+
+// builtProperties are the properties set by the client itself, which the
+// builders reject as properties.
+var builtProperties = map[string]string{
+	"time":         "is set with At",
+	"$time":        "is set with At",
+	"token":        "is set by the client",
+	"$token":       "is set by the client",
+	"distinct_id":  "is set by the client",
+	"$distinct_id": "is set by the client",
+}
+
+// An EventBuilder builds an Event step by step, and checks it against
+// Mixpanel's documented limits before it is sent, whatever the options of the
+// client:
+//
+//	err := mixpanel.NewEvent("Signed Up").
+//		Set("Plan", "pro").
+//		At(signedUpAt).
+//		Track(client, "13793")
+//
+// Set returns the builder, so calls can be chained; the first invalid
+// property is reported by Build, as a *ValidationError.
+type EventBuilder struct {
+	name  string
+	event Event
+}
+
+// NewEvent returns a builder for an event called name.
+func NewEvent(name string) *EventBuilder {
+	return &EventBuilder{name: name}
+}
+
+// Set sets the property key to value.
+func (b *EventBuilder) Set(key string, value interface{}) *EventBuilder {
+	if b.event.Properties == nil {
+		b.event.Properties = map[string]interface{}{}
+	}
+	b.event.Properties[key] = value
+	return b
+}
+
+// At sets the time of the event.
+func (b *EventBuilder) At(t time.Time) *EventBuilder {
+	b.event.Timestamp = &t
+	return b
+}
+
+// IP sets the IP address of the event, as Event.IP.
+func (b *EventBuilder) IP(ip string) *EventBuilder {
+	b.event.IP = ip
+	return b
+}
+
+// Name returns the name of the event.
+func (b *EventBuilder) Name() string {
+	return b.name
+}
+
+// Build checks the event and returns it. It fails with ErrNoEventName if the
+// name is empty, and with a *ValidationError describing the first rule the
+// event breaks otherwise:
+//
+//   - it has more than MaxEventProperties properties;
+//   - a property name is longer than MaxPropertyNameLength;
+//   - a property is set by the client, such as "time" or "distinct_id";
+//   - a reserved property has the wrong type, see ReservedPropertyKinds;
+//   - a string value is longer than MaxPropertyValueLength bytes;
+//   - a value is nested deeper than MaxPropertyDepth;
+//   - the time is outside the years 2000 to 2100.
+func (b *EventBuilder) Build() (*Event, error) {
+	if b.name == "" {
+		return nil, ErrNoEventName
+	}
+	if len(b.event.Properties) > MaxEventProperties {
+		return nil, &ValidationError{
+			Event:  b.name,
+			Reason: fmt.Sprintf("has %d properties, more than the limit of %d", len(b.event.Properties), MaxEventProperties),
+		}
+	}
+	if err := checkBuiltProperties(b.name, b.event.Properties); err != nil {
+		return nil, err
+	}
+	if err := checkBuiltTime(b.name, b.event.Timestamp); err != nil {
+		return nil, err
+	}
+
+	e := b.event
+	e.Properties = copyMap(b.event.Properties)
+	return &e, nil
+}
+
+// Track builds the event and tracks it for distinctId with client. Nothing is
+// sent if the event is invalid.
+func (b *EventBuilder) Track(client Mixpanel, distinctId string, opts ...CallOption) error {
+	e, err := b.Build()
+	if err != nil {
+		return err
+	}
+	return client.Track(distinctId, b.name, e, opts...)
+}
+
+// An UpdateBuilder builds a profile Update step by step, and checks it like
+// an EventBuilder does:
+//
+//	err := mixpanel.NewUpdate(mixpanel.OpSet).
+//		Set("$email", "john@example.com").
+//		Update(client, "13793")
+type UpdateBuilder struct {
+	update Update
+}
+
+// NewUpdate returns a builder for an update applying operation, such as
+// OpSet or OpAdd.
+func NewUpdate(operation string) *UpdateBuilder {
+	return &UpdateBuilder{update: Update{Operation: operation}}
+}
+
+// Set sets the property key to value, the operand of the operation.
+func (b *UpdateBuilder) Set(key string, value interface{}) *UpdateBuilder {
+	if b.update.Properties == nil {
+		b.update.Properties = map[string]interface{}{}
+	}
+	b.update.Properties[key] = value
+	return b
+}
+
+// Unset adds keys to the properties removed by OpUnset.
+func (b *UpdateBuilder) Unset(keys ...string) *UpdateBuilder {
+	b.update.Unset = append(b.update.Unset, keys...)
+	return b
+}
+
+// At sets the time of the update.
+func (b *UpdateBuilder) At(t time.Time) *UpdateBuilder {
+	b.update.Timestamp = &t
+	return b
+}
+
+// IgnoreTime sends the update without a time, as the IgnoreTime timestamp
+// does.
+func (b *UpdateBuilder) IgnoreTime() *UpdateBuilder {
+	b.update.Timestamp = IgnoreTime
+	return b
+}
+
+// IP sets the IP address of the update, as Update.IP.
+func (b *UpdateBuilder) IP(ip string) *UpdateBuilder {
+	b.update.IP = ip
+	return b
+}
+
+// Build checks the update and returns it, or a *ValidationError describing
+// the first rule it breaks: the operation is unknown, it has nothing to
+// apply, which matches ErrNoProperties, or a property breaks one of the rules
+// listed by EventBuilder.Build.
+func (b *UpdateBuilder) Build() (*Update, error) {
+	switch b.update.Operation {
+	case OpSet, OpSetOnce, OpAdd, OpAppend, OpUnion, OpRemove, OpUnset:
+	default:
+		return nil, &ValidationError{Reason: fmt.Sprintf("has unknown operation %q", b.update.Operation)}
+	}
+	if b.update.isEmpty() {
+		return nil, &ValidationError{Reason: "has no properties", Err: ErrNoProperties}
+	}
+	if err := checkBuiltProperties("", b.update.Properties); err != nil {
+		return nil, err
+	}
+	if b.update.Timestamp != IgnoreTime {
+		if err := checkBuiltTime("", b.update.Timestamp); err != nil {
+			return nil, err
+		}
+	}
+
+	u := b.update
+	u.Properties = copyMap(b.update.Properties)
+	u.Unset = append([]string(nil), b.update.Unset...)
+	return &u, nil
+}
+
+// Update builds the update and applies it to the profile of distinctId with
+// client. Nothing is sent if the update is invalid.
+func (b *UpdateBuilder) Update(client Mixpanel, distinctId string, opts ...CallOption) error {
+	u, err := b.Build()
+	if err != nil {
+		return err
+	}
+	return client.Update(distinctId, u, opts...)
+}
+
+// checkBuiltProperties checks props against the rules of the builders, in
+// the order of their names. eventName is empty for a profile update.
+func checkBuiltProperties(eventName string, props map[string]interface{}) error {
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := props[key]
+		invalid := func(reason string) error {
+			return &ValidationError{Event: eventName, Property: key, Reason: reason}
+		}
+
+		if utf8.RuneCountInString(key) > MaxPropertyNameLength {
+			return invalid(fmt.Sprintf("is longer than %d characters", MaxPropertyNameLength))
+		}
+		if reason, ok := builtProperties[key]; ok {
+			return invalid(reason)
+		}
+		if kind, ok := ReservedPropertyKinds[key]; ok && !kind.matches(value) {
+			return invalid(fmt.Sprintf("must be %v, got %T", kind, value))
+		}
+		if reason := checkBuiltValue(reflect.ValueOf(value), 0); reason != "" {
+			return invalid(reason)
+		}
+	}
+
+	return nil
+}
+
+// checkBuiltValue checks the length of the strings in v and its nesting
+// depth, v being nested depth levels deep. It returns the reason v is
+// invalid, or "".
+func checkBuiltValue(v reflect.Value, depth int) string {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		if v.Len() > MaxPropertyValueLength {
+			return fmt.Sprintf("is longer than %d bytes", MaxPropertyValueLength)
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as a base64 string.
+			return ""
+		}
+		if depth >= MaxPropertyDepth {
+			return fmt.Sprintf("is nested deeper than %d levels", MaxPropertyDepth)
+		}
+		if v.Kind() == reflect.Map {
+			iter := v.MapRange()
+			for iter.Next() {
+				if reason := checkBuiltValue(iter.Value(), depth+1); reason != "" {
+					return reason
+				}
+			}
+			return ""
+		}
+		for i := 0; i < v.Len(); i++ {
+			if reason := checkBuiltValue(v.Index(i), depth+1); reason != "" {
+				return reason
+			}
+		}
+	}
+
+	return ""
+}
+
+// checkBuiltTime checks that t, if set, is within the years 2000 to 2100.
+func checkBuiltTime(eventName string, t *time.Time) error {
+	if t == nil || (!t.Before(minPlausibleTime) && t.Before(maxPlausibleTime)) {
+		return nil
+	}
+	return &ValidationError{
+		Event:    eventName,
+		Property: "time",
+		Reason:   fmt.Sprintf("is %v, outside the years 2000 to 2100", t.UTC().Format(time.RFC3339)),
+	}
+}
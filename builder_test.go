@@ -0,0 +1,152 @@
+package mixpanel
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventBuilder(t *testing.T) {
+	client := NewMock()
+	at := time.Now().Add(-time.Minute)
+
+	err := NewEvent("Signed Up").
+		Set("Plan", "pro").
+		Set("Tags", []string{"beta"}).
+		At(at).
+		IP("0").
+		Track(client, "13793")
+	if err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+
+	want := []MockEvent{{
+		Event: Event{
+			IP:         "0",
+			Timestamp:  &at,
+			Properties: map[string]interface{}{"Plan": "pro", "Tags": []string{"beta"}},
+		},
+		Name:     "Signed Up",
+		Endpoint: "track",
+	}}
+	if got := client.People["13793"].Events; !reflect.DeepEqual(got, want) {
+		t.Errorf("Events returned %+v, want %+v", got, want)
+	}
+}
+
+func TestEventBuilderValidation(t *testing.T) {
+	tooMany := NewEvent("Signed Up")
+	for i := 0; i <= MaxEventProperties; i++ {
+		tooMany.Set("p"+strconv.Itoa(i), i)
+	}
+
+	tests := []struct {
+		builder *EventBuilder
+		want    *ValidationError
+	}{
+		{
+			tooMany,
+			&ValidationError{Event: "Signed Up", Reason: "has 256 properties, more than the limit of 255"},
+		},
+		{
+			NewEvent("Signed Up").Set(strings.Repeat("x", 256), 1),
+			&ValidationError{Event: "Signed Up", Property: strings.Repeat("x", 256), Reason: "is longer than 255 characters"},
+		},
+		{
+			NewEvent("Signed Up").Set("Bio", strings.Repeat("é", 128)),
+			&ValidationError{Event: "Signed Up", Property: "Bio", Reason: "is longer than 255 bytes"},
+		},
+		{
+			NewEvent("Signed Up").Set("time", time.Now()),
+			&ValidationError{Event: "Signed Up", Property: "time", Reason: "is set with At"},
+		},
+		{
+			NewEvent("Signed Up").Set("$email", 42),
+			&ValidationError{Event: "Signed Up", Property: "$email", Reason: "must be a string, got int"},
+		},
+		{
+			NewEvent("Signed Up").Set("Bio", strings.Repeat("x", 256)),
+			&ValidationError{Event: "Signed Up", Property: "Bio", Reason: "is longer than 255 bytes"},
+		},
+		{
+			NewEvent("Signed Up").Set("Tree", map[string]interface{}{"a": []interface{}{[]int{1}, []interface{}{[]int{1}}}}),
+			&ValidationError{Event: "Signed Up", Property: "Tree", Reason: "is nested deeper than 3 levels"},
+		},
+		{
+			NewEvent("Signed Up").At(time.Unix(1e12, 0)),
+			&ValidationError{Event: "Signed Up", Property: "time", Reason: "is " + time.Unix(1e12, 0).UTC().Format(time.RFC3339) + ", outside the years 2000 to 2100"},
+		},
+	}
+
+	for _, tt := range tests {
+		if _, err := tt.builder.Build(); !reflect.DeepEqual(err, tt.want) {
+			t.Errorf("Build returned %v, want %v", err, tt.want)
+		}
+	}
+
+	if _, err := NewEvent("").Build(); err != ErrNoEventName {
+		t.Errorf("Build returned %v, want %v", err, ErrNoEventName)
+	}
+
+	// A name of 255 characters is accepted, whatever its length in bytes.
+	if _, err := NewEvent("Signed Up").Set(strings.Repeat("é", 255), 1).Build(); err != nil {
+		t.Errorf("Build returned %v for a name of 255 characters", err)
+	}
+
+	nested := map[string]interface{}{"a": map[string]interface{}{"b": []int{1}}}
+	if _, err := NewEvent("Signed Up").Set("Tree", nested).Build(); err != nil {
+		t.Errorf("Build returned %v for a value nested 3 levels deep", err)
+	}
+}
+
+func TestEventBuilderNotSent(t *testing.T) {
+	client := NewMock()
+
+	if err := NewEvent("Signed Up").Set("distinct_id", "1").Track(client, "13793"); err == nil {
+		t.Errorf("Track returned no error for an invalid event")
+	}
+	if len(client.People) != 0 {
+		t.Errorf("People returned %+v, want nothing tracked", client.People)
+	}
+}
+
+func TestUpdateBuilder(t *testing.T) {
+	client := NewMock()
+
+	if err := NewUpdate(OpSet).Set("$email", "john@example.com").IP("0").Update(client, "13793"); err != nil {
+		t.Fatalf("Update returned %v", err)
+	}
+
+	want := map[string]interface{}{"$email": "john@example.com"}
+	if got := client.People["13793"].Properties; !reflect.DeepEqual(got, want) {
+		t.Errorf("Properties returned %+v, want %+v", got, want)
+	}
+
+	if err := NewUpdate(OpUnset).Unset("$email").Update(client, "13793"); err != nil {
+		t.Fatalf("Update returned %v", err)
+	}
+	if got := client.People["13793"].Properties; len(got) != 0 {
+		t.Errorf("Properties returned %+v, want none", got)
+	}
+}
+
+func TestUpdateBuilderValidation(t *testing.T) {
+	if _, err := NewUpdate("$merge").Set("Plan", "pro").Build(); err == nil || err.Error() != `mixpanel: invalid profile update: has unknown operation "$merge"` {
+		t.Errorf("Build returned %v for an unknown operation", err)
+	}
+	if _, err := NewUpdate(OpSet).Build(); !errors.Is(err, ErrNoProperties) {
+		t.Errorf("Build returned %v, want %v", err, ErrNoProperties)
+	}
+
+	want := &ValidationError{Property: "$token", Reason: "is set by the client"}
+	if _, err := NewUpdate(OpSet).Set("$token", "abc").Build(); !reflect.DeepEqual(err, want) {
+		t.Errorf("Build returned %v, want %v", err, want)
+	}
+
+	if _, err := NewUpdate(OpSet).Set("Plan", "pro").IgnoreTime().Build(); err != nil {
+		t.Errorf("Build returned %v", err)
+	}
+}
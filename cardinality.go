@@ -0,0 +1,70 @@
+package mixpanel
+
+import "sync"
+
+// WithCardinalityWarnings enables a detector for high-cardinality properties,
+// such as a UUID sent as a regular property, which bloat Mixpanel's property
+// index and degrade its UI. One in every sampleEvery events is inspected, and
+// a warning is logged once per property when its string values have taken
+// more than threshold distinct values across the sampled events. Only string
+// values are tracked, and at most threshold+1 of them per property, so the
+// overhead stays bounded.
+//
+// The warning suggests sending the value as the $insert_id of the event
+// when it identifies the event, such as a request id, and otherwise dropping
+// it or moving it to a profile property.
+func WithCardinalityWarnings(sampleEvery, threshold int) Option {
+	if sampleEvery < 1 {
+		sampleEvery = 1
+	}
+
+	return func(m *mixpanel) {
+		m.cardinality = &cardinalityDetector{
+			every:     sampleEvery,
+			threshold: threshold,
+			seen:      map[string]map[string]struct{}{},
+			warned:    map[string]bool{},
+		}
+	}
+}
+
+type cardinalityDetector struct {
+	mu        sync.Mutex
+	every     int
+	threshold int
+	n         int
+	seen      map[string]map[string]struct{}
+	warned    map[string]bool
+}
+
+// observe samples props and logs a warning for every property that has just
+// crossed the threshold.
+func (d *cardinalityDetector) observe(logf func(format string, v ...interface{}), eventName string, props map[string]interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.n++
+	if (d.n-1)%d.every != 0 {
+		return
+	}
+
+	for key, value := range props {
+		s, ok := value.(string)
+		if !ok || d.warned[key] {
+			continue
+		}
+
+		values := d.seen[key]
+		if values == nil {
+			values = map[string]struct{}{}
+			d.seen[key] = values
+		}
+		values[s] = struct{}{}
+
+		if len(values) > d.threshold {
+			d.warned[key] = true
+			delete(d.seen, key)
+			logf("mixpanel: property %q of event %q looks high-cardinality (more than %d distinct values sampled); consider sending it as $insert_id if it identifies the event, or else dropping it or setting it as a profile property", key, eventName, d.threshold)
+		}
+	}
+}
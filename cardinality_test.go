@@ -0,0 +1,52 @@
+package mixpanel
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestCardinalityWarnings(t *testing.T) {
+	setup()
+	defer teardown()
+
+	logger := &testLogger{}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithLogger(logger), WithCardinalityWarnings(2, 10))
+
+	for i := 0; i < 100; i++ {
+		client.Track("13793", "Viewed", &Event{
+			Properties: map[string]interface{}{
+				"request_id": "req-" + strconv.Itoa(i),
+				"plan":       "pro",
+			},
+		})
+	}
+
+	want := []string{
+		"mixpanel: property \"request_id\" of event \"Viewed\" looks high-cardinality (more than 10 distinct values sampled); consider sending it as $insert_id if it identifies the event, or else dropping it or setting it as a profile property",
+	}
+	if !reflect.DeepEqual(logger.lines, want) {
+		t.Errorf("logged %+v, want %+v", logger.lines, want)
+	}
+}
+
+func TestCardinalityWarningsDisabled(t *testing.T) {
+	setup()
+	defer teardown()
+
+	logger := &testLogger{}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithLogger(logger))
+
+	for i := 0; i < 100; i++ {
+		client.Track("13793", "Viewed", &Event{
+			Properties: map[string]interface{}{
+				"request_id": "req-" + strconv.Itoa(i),
+			},
+		})
+	}
+
+	if len(logger.lines) != 0 {
+		t.Errorf("logged %+v, want nothing", logger.lines)
+	}
+}
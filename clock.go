@@ -0,0 +1,32 @@
+package mixpanel
+
+import "time"
+
+// A Clock tells the current time. The client asks it whenever it needs
+// "now": to route events older than the import threshold to /import, to
+// stamp the events of WithAutoInsertID, the events imported without a
+// Timestamp, the transactions of TrackCharge and the failures kept by
+// WithErrorRing, and to time the cool-down of WithCircuitBreaker. Durations,
+// such as those reported to a MetricsCollector, the delays of Retry-After
+// and rate limits use the system clock. Mock.SetClock gives a Mock a Clock
+// too.
+type Clock interface {
+	Now() time.Time
+}
+
+// WithClock makes the client read the current time from c instead of the
+// system clock, for example to test code that depends on the import
+// threshold with a fixed time.
+func WithClock(c Clock) Option {
+	return func(m *mixpanel) {
+		m.clock = c
+	}
+}
+
+// now returns the current time, from the Clock of WithClock if any.
+func (m *mixpanel) now() time.Time {
+	if m.clock != nil {
+		return m.clock.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,76 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestWithClock(t *testing.T) {
+	setup()
+	defer teardown()
+
+	now := time.Date(2020, 1, 10, 12, 0, 0, 0, time.UTC)
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithClock(fixedClock(now)))
+
+	recent := now.Add(-24 * time.Hour)
+	client.Track("13793", "Signed Up", &Event{Timestamp: &recent})
+	if path := LastRequest.URL.Path; path != "/track" {
+		t.Errorf("path returned %+v for an event a day old, want %+v", path, "/track")
+	}
+
+	stale := now.Add(-6 * 24 * time.Hour)
+	client.Track("13793", "Signed Up", &Event{Timestamp: &stale})
+	if path := LastRequest.URL.Path; path != "/import" {
+		t.Errorf("path returned %+v for an event six days old, want %+v", path, "/import")
+	}
+
+	client.TrackCharge("13793", 9.99, nil)
+	var update struct {
+		Append struct {
+			Transactions map[string]interface{} `json:"$transactions"`
+		} `json:"$append"`
+	}
+	json.Unmarshal([]byte(decodeData(LastRequest)), &update)
+	if got, want := update.Append.Transactions["$time"], "2020-01-10T12:00:00"; got != want {
+		t.Errorf("$time returned %+v, want %+v", got, want)
+	}
+}
+
+func TestWithClockErrorRing(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	now := time.Date(2020, 1, 10, 12, 0, 0, 0, time.UTC)
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithClock(fixedClock(now)), WithErrorRing(1))
+	client.Track("13793", "Signed Up", &Event{})
+
+	if errs := client.RecentErrors(); len(errs) != 1 || !errs[0].Time.Equal(now) {
+		t.Errorf("RecentErrors returned %+v, want a failure at %v", errs, now)
+	}
+}
+
+func TestTimestampTimeZone(t *testing.T) {
+	setup()
+	defer teardown()
+
+	utc := time.Now().UTC().Truncate(time.Second)
+	at := utc.In(time.FixedZone("CET", 3600))
+	client.Track("13793", "Signed Up", &Event{Timestamp: &at})
+
+	var e ExportedEvent
+	json.Unmarshal([]byte(decodeData(LastRequest)), &e)
+	if got, want := e.Properties["time"], float64(utc.Unix()); got != want {
+		t.Errorf("time returned %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,83 @@
+package mixpanel
+
+import (
+	"fmt"
+	"time"
+)
+
+// A Coercion converts a property value into a type that serializes
+// meaningfully for Mixpanel. It returns false if it does not apply to the
+// value, in which case the next coercion in the table is tried.
+type Coercion func(value interface{}) (interface{}, bool)
+
+// DefaultCoercions is the coercion table used by WithCoercions when it is
+// called without arguments. Order matters: the first matching rule wins, so
+// more specific types must come before broader ones such as fmt.Stringer.
+var DefaultCoercions = []Coercion{
+	CoerceDuration,
+	CoerceStringer,
+}
+
+// CoerceDuration converts a time.Duration to whole milliseconds instead of the
+// nanosecond int64 it would otherwise be marshaled as.
+func CoerceDuration(value interface{}) (interface{}, bool) {
+	d, ok := value.(time.Duration)
+	if !ok {
+		return nil, false
+	}
+
+	return int64(d / time.Millisecond), true
+}
+
+// CoerceStringer converts any fmt.Stringer (for example *big.Int) to the
+// string it returns. time.Time is left alone, since its JSON encoding is
+// already a format Mixpanel understands.
+func CoerceStringer(value interface{}) (interface{}, bool) {
+	if _, ok := value.(time.Time); ok {
+		return nil, false
+	}
+
+	s, ok := value.(fmt.Stringer)
+	if !ok {
+		return nil, false
+	}
+
+	return s.String(), true
+}
+
+// WithCoercions enables coercion of property values before they are marshaled.
+// The coercions are tried in order for every property value sent by Track and
+// Update. Without arguments DefaultCoercions is used. Coercion is disabled
+// unless this option is given.
+func WithCoercions(coercions ...Coercion) Option {
+	if len(coercions) == 0 {
+		coercions = DefaultCoercions
+	}
+
+	return func(m *mixpanel) {
+		m.coercions = coercions
+	}
+}
+
+func (m *mixpanel) coerce(value interface{}) interface{} {
+	for _, c := range m.coercions {
+		if v, ok := c(value); ok {
+			return v
+		}
+	}
+
+	return value
+}
+
+func (m *mixpanel) coerceProperties(props map[string]interface{}) map[string]interface{} {
+	if len(m.coercions) == 0 || props == nil {
+		return props
+	}
+
+	coerced := make(map[string]interface{}, len(props))
+	for key, value := range props {
+		coerced[key] = m.coerce(value)
+	}
+
+	return coerced
+}
@@ -0,0 +1,109 @@
+package mixpanel
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type plan int
+
+func (p plan) String() string {
+	return [...]string{"free", "pro"}[p]
+}
+
+func TestCoerceDuration(t *testing.T) {
+	got, ok := CoerceDuration(1500 * time.Millisecond)
+	if !ok || !reflect.DeepEqual(got, int64(1500)) {
+		t.Errorf("CoerceDuration returned %+v, %v, want %+v, true", got, ok, int64(1500))
+	}
+
+	if _, ok := CoerceDuration("1.5s"); ok {
+		t.Errorf("CoerceDuration applied to a string")
+	}
+}
+
+func TestCoerceStringer(t *testing.T) {
+	got, ok := CoerceStringer(big.NewInt(42))
+	if !ok || !reflect.DeepEqual(got, "42") {
+		t.Errorf("CoerceStringer returned %+v, %v, want %+v, true", got, ok, "42")
+	}
+
+	got, ok = CoerceStringer(plan(1))
+	if !ok || !reflect.DeepEqual(got, "pro") {
+		t.Errorf("CoerceStringer returned %+v, %v, want %+v, true", got, ok, "pro")
+	}
+
+	if _, ok := CoerceStringer(time.Now()); ok {
+		t.Errorf("CoerceStringer applied to a time.Time")
+	}
+}
+
+func TestTrackWithCoercions(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithCoercions())
+
+	client.Track("13793", "Played", &Event{
+		Properties: map[string]interface{}{
+			"Length": 90 * time.Second,
+			"Plan":   plan(0),
+		},
+	})
+
+	want := "{\"event\":\"Played\",\"properties\":{\"Length\":90000,\"Plan\":\"free\",\"distinct_id\":\"13793\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+
+	if !reflect.DeepEqual(decodeData(LastRequest), want) {
+		t.Errorf("LastRequest.URL returned %+v, want %+v",
+			decodeData(LastRequest), want)
+	}
+}
+
+func TestUpdateWithCustomCoercion(t *testing.T) {
+	setup()
+	defer teardown()
+
+	upper := func(v interface{}) (interface{}, bool) {
+		if v == "free" {
+			return "FREE", true
+		}
+		return nil, false
+	}
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithCoercions(upper))
+
+	client.Update("13793", &Update{
+		Operation: "$set",
+		Properties: map[string]interface{}{
+			"Plan":   "free",
+			"Length": 90 * time.Second,
+		},
+	})
+
+	want := "{\"$distinct_id\":\"13793\",\"$set\":{\"Length\":90000000000,\"Plan\":\"FREE\"},\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}"
+
+	if !reflect.DeepEqual(decodeData(LastRequest), want) {
+		t.Errorf("LastRequest.URL returned %+v, want %+v",
+			decodeData(LastRequest), want)
+	}
+}
+
+func TestTrackWithoutCoercions(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.Track("13793", "Played", &Event{
+		Properties: map[string]interface{}{
+			"Length": 90 * time.Second,
+		},
+	})
+
+	want := "{\"event\":\"Played\",\"properties\":{\"Length\":90000000000,\"distinct_id\":\"13793\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+
+	if !reflect.DeepEqual(decodeData(LastRequest), want) {
+		t.Errorf("LastRequest.URL returned %+v, want %+v",
+			decodeData(LastRequest), want)
+	}
+}
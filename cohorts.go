@@ -0,0 +1,103 @@
+package mixpanel
+
+import "context"
+
+// DefaultCohortProperty is the profile property ReconcileCohorts maintains
+// unless WithCohortProperty is used.
+const DefaultCohortProperty = "cohorts"
+
+// WithCohortProperty sets the list property of a profile that holds its
+// cohort ids, as maintained by ReconcileCohorts.
+func WithCohortProperty(name string) Option {
+	return func(m *mixpanel) {
+		m.cohortProperty = name
+	}
+}
+
+// ReconcileCohorts fetches the current cohort list of a profile through the
+// query API and sends the $union and $remove updates needed to make it equal
+// desired. Nothing is sent if the list is already up to date. The updates
+// leave the profile's last seen time and location untouched.
+func (m *mixpanel) ReconcileCohorts(distinctId string, desired []string) error {
+	return m.ReconcileCohortsCtx(context.Background(), distinctId, desired)
+}
+
+// ReconcileCohortsCtx is like ReconcileCohorts, but sends the requests with
+// ctx.
+func (m *mixpanel) ReconcileCohortsCtx(ctx context.Context, distinctId string, desired []string) error {
+	if m.disabled() {
+		return nil
+	}
+
+	props, err := m.profilePropertiesCtx(ctx, distinctId)
+	if err != nil {
+		return err
+	}
+
+	var current []string
+	if list, ok := props[m.cohortProperty].([]interface{}); ok {
+		for _, v := range list {
+			if id, ok := v.(string); ok {
+				current = append(current, id)
+			}
+		}
+	}
+
+	add, remove := diffCohorts(current, desired)
+
+	if len(add) > 0 {
+		err := m.UpdateCtx(ctx, distinctId, &Update{
+			Operation:  "$union",
+			Timestamp:  IgnoreTime,
+			IP:         "0",
+			Properties: map[string]interface{}{m.cohortProperty: add},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// $remove takes a single value per property, so every dropped cohort is
+	// its own update.
+	for _, id := range remove {
+		err := m.UpdateCtx(ctx, distinctId, &Update{
+			Operation:  "$remove",
+			Timestamp:  IgnoreTime,
+			IP:         "0",
+			Properties: map[string]interface{}{m.cohortProperty: id},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// diffCohorts returns the ids in desired but not in current, and the ids in
+// current but not in desired, both in their original order.
+func diffCohorts(current, desired []string) (add, remove []string) {
+	inCurrent := map[string]bool{}
+	for _, id := range current {
+		inCurrent[id] = true
+	}
+	inDesired := map[string]bool{}
+	for _, id := range desired {
+		inDesired[id] = true
+	}
+
+	for _, id := range desired {
+		if !inCurrent[id] {
+			add = append(add, id)
+			inCurrent[id] = true
+		}
+	}
+	for _, id := range current {
+		if !inDesired[id] {
+			remove = append(remove, id)
+			inDesired[id] = true
+		}
+	}
+
+	return add, remove
+}
@@ -0,0 +1,64 @@
+package mixpanel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestReconcileCohorts(t *testing.T) {
+	var (
+		query   string
+		updates []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2.0/engage":
+			r.ParseForm()
+			query = r.Form.Get("distinct_id")
+			w.Write([]byte(`{"page":0,"page_size":1000,"results":[{"$distinct_id":"13793","$properties":{"cohorts":["a","b"]}}],"status":"ok","total":1}`))
+		case "/engage":
+			updates = append(updates, decodeData(r))
+			w.Write([]byte(`{"status":1,"error":null}`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL, WithQueryURL(ts.URL))
+
+	if err := client.ReconcileCohorts("13793", []string{"b", "c", "d"}); err != nil {
+		t.Fatalf("ReconcileCohorts returned %v", err)
+	}
+
+	if query != "13793" {
+		t.Errorf("queried distinct_id %+v, want %+v", query, "13793")
+	}
+
+	want := []string{
+		"{\"$distinct_id\":\"13793\",\"$ignore_time\":true,\"$ip\":\"0\",\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\",\"$union\":{\"cohorts\":[\"c\",\"d\"]}}",
+		"{\"$distinct_id\":\"13793\",\"$ignore_time\":true,\"$ip\":\"0\",\"$remove\":{\"cohorts\":\"a\"},\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}",
+	}
+	if !reflect.DeepEqual(updates, want) {
+		t.Errorf("updates returned %+v, want %+v", updates, want)
+	}
+}
+
+func TestReconcileCohortsUpToDate(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2.0/engage" {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"results":[{"$distinct_id":"13793","$properties":{"segments":["a"]}}]}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL,
+		WithQueryURL(ts.URL), WithCohortProperty("segments"))
+
+	if err := client.ReconcileCohorts("13793", []string{"a"}); err != nil {
+		t.Fatalf("ReconcileCohorts returned %v", err)
+	}
+}
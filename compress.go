@@ -0,0 +1,57 @@
+package mixpanel
+
+import (
+	"bytes"
+	"compress/gzip"
+)
+
+// WithCompression sends the payload of Track, Update, Alias and the other
+// ingestion calls, as well as the chunks of ImportBatch, as a gzip-compressed
+// JSON request body, with a Content-Encoding of gzip, instead of
+// base64-encoded in the data form parameter. Large UpdateBatch payloads then
+// take a fraction of the bytes. ImportNDJSON is always compressed.
+func WithCompression() Option {
+	return func(m *mixpanel) {
+		m.rawJSONBody = true
+		m.compress = true
+		m.compressAbove = 0
+	}
+}
+
+// WithCompressionThreshold is like WithCompression, but only compresses
+// payloads larger than size bytes, before encoding; smaller ones are sent as
+// plain JSON, as compressing them saves little and costs CPU time on both
+// ends.
+func WithCompressionThreshold(size int) Option {
+	return func(m *mixpanel) {
+		m.rawJSONBody = true
+		m.compress = true
+		m.compressAbove = size
+	}
+}
+
+// compressBody returns data gzip-compressed, and true, if the client
+// compresses payloads of its size, and data unchanged otherwise.
+func (m *mixpanel) compressBody(data []byte) ([]byte, bool, error) {
+	if !m.compress || len(data) <= m.compressAbove {
+		return data, false, nil
+	}
+	compressed, err := gzipBytes(data)
+	if err != nil {
+		return nil, false, err
+	}
+	return compressed, true, nil
+}
+
+// gzipBytes returns data compressed with gzip.
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,99 @@
+package mixpanel
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWithCompression(t *testing.T) {
+	var (
+		query, encoding string
+		body            []byte
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		encoding = r.Header.Get("Content-Encoding")
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatalf("gzip.NewReader returned %v", err)
+		}
+		body, _ = io.ReadAll(gz)
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithCompression())
+
+	updates := []BatchUpdate{
+		{DistinctId: "13793", Update: Update{IP: "0", Timestamp: IgnoreTime, Operation: "$set", Properties: map[string]interface{}{"Plan": "pro"}}},
+		{DistinctId: "13794", Update: Update{IP: "0", Timestamp: IgnoreTime, Operation: "$set", Properties: map[string]interface{}{"Plan": "free"}}},
+	}
+	if err := client.UpdateBatch(updates); err != nil {
+		t.Fatalf("UpdateBatch returned %v", err)
+	}
+
+	var got []map[string]interface{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("body %q is not JSON: %v", body, err)
+	}
+	want := []map[string]interface{}{
+		{"$token": "e3bc4100330c35722740fb8c6f5abddc", "$distinct_id": "13793", "$ip": "0", "$ignore_time": true, "$set": map[string]interface{}{"Plan": "pro"}},
+		{"$token": "e3bc4100330c35722740fb8c6f5abddc", "$distinct_id": "13794", "$ip": "0", "$ignore_time": true, "$set": map[string]interface{}{"Plan": "free"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("body returned %+v, want %+v", got, want)
+	}
+	if encoding != "gzip" {
+		t.Errorf("Content-Encoding returned %+v, want %+v", encoding, "gzip")
+	}
+	if query != "verbose=1" {
+		t.Errorf("query returned %+v, want %+v", query, "verbose=1")
+	}
+}
+
+func TestWithCompressionThreshold(t *testing.T) {
+	var (
+		encoding string
+		body     []byte
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		body, _ = io.ReadAll(r.Body)
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithCompressionThreshold(1024))
+
+	if err := client.Track("13793", "Signed Up", &Event{IP: "0"}); err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+	if encoding != "" {
+		t.Errorf("Content-Encoding returned %+v for a small payload, want none", encoding)
+	}
+	if !json.Valid(body) {
+		t.Errorf("body %q is not JSON", body)
+	}
+
+	props := map[string]interface{}{"Bio": strings.Repeat("x", 2048)}
+	if err := client.Track("13793", "Signed Up", &Event{IP: "0", Properties: props}); err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+	if encoding != "gzip" {
+		t.Errorf("Content-Encoding returned %+v for a large payload, want %+v", encoding, "gzip")
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("gzip.NewReader returned %v", err)
+	}
+	if data, _ := io.ReadAll(gz); !json.Valid(data) {
+		t.Errorf("decompressed body %q is not JSON", data)
+	}
+}
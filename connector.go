@@ -0,0 +1,156 @@
+package mixpanel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ErrNoServiceAccount is returned by the methods that need a service account
+// when none was given with WithServiceAccount.
+var ErrNoServiceAccount = errors.New("mixpanel: no service account configured")
+
+type serviceAccount struct {
+	username  string
+	secret    string
+	projectId int
+}
+
+// WithServiceAccount sets the service account used by the methods of the app
+// API, such as ConnectorStatus, which do not accept the project's API secret,
+// and by the requests to /import, the query APIs and Export, which Mixpanel
+// recommends authenticating with a service account and then sends to the
+// project projectId. Other ingestion requests keep using the project token
+// and API secret. Service accounts are created in the organization settings;
+// projectId is the numeric id of the project, shown in its settings.
+func WithServiceAccount(username, secret string, projectId int) Option {
+	return func(m *mixpanel) {
+		m.serviceAccount = &serviceAccount{username: username, secret: secret, projectId: projectId}
+	}
+}
+
+// serviceAccountEndpoints are the endpoints authenticated with the service
+// account when one is configured: "import", and "query" and "export" for the
+// query APIs and Export.
+var serviceAccountEndpoints = map[string]bool{
+	"import": true,
+	"query":  true,
+	"export": true,
+}
+
+// authenticate sets the credentials of req, a request to endpoint: those of
+// the service account for serviceAccountEndpoints when one is configured,
+// adding its project_id to the query, and the API secret otherwise.
+func (m *mixpanel) authenticate(req *http.Request, endpoint string) {
+	sa := m.serviceAccount
+	if !serviceAccountEndpoints[endpoint] || sa == nil {
+		req.SetBasicAuth(m.ApiSecret, "")
+		return
+	}
+
+	req.SetBasicAuth(sa.username, sa.secret)
+	if req.URL.RawQuery != "" {
+		req.URL.RawQuery += "&"
+	}
+	req.URL.RawQuery += "project_id=" + strconv.Itoa(sa.projectId)
+}
+
+// ConnectorStatus is the sync status of a warehouse connector.
+type ConnectorStatus struct {
+	// State of the last sync, such as "succeeded", "failed" or "running".
+	State string `json:"state"`
+
+	// LastRunAt is when the last sync started.
+	LastRunAt time.Time `json:"last_run_at"`
+
+	// LastError describes why the last sync failed, if it did.
+	LastError string `json:"last_error"`
+}
+
+// ConnectorStatus returns the status of the last sync of the warehouse
+// connector connectorId, as shown in the project's warehouse sources. It
+// calls the app API under the query API host,
+// {QueryURL}/app/projects/{projectId}/warehouse-sources/imports/{connectorId},
+// authenticated with the service account given to WithServiceAccount, and
+// fails with ErrNoServiceAccount without one.
+func (m *mixpanel) ConnectorStatus(connectorId string) (*ConnectorStatus, error) {
+	return m.ConnectorStatusCtx(context.Background(), connectorId)
+}
+
+// ConnectorStatusCtx is like ConnectorStatus, but sends the request with ctx.
+func (m *mixpanel) ConnectorStatusCtx(ctx context.Context, connectorId string) (*ConnectorStatus, error) {
+	var status ConnectorStatus
+	path := "/warehouse-sources/imports/" + url.PathEscape(connectorId)
+	if err := m.appRequest(ctx, http.MethodGet, path, nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// appRequest sends a request to path under the app API of the project of the
+// service account, {QueryURL}/app/projects/{projectId}, authenticated with
+// the service account, with query and the JSON encoding of body, if not nil.
+// The "results" field of the response is decoded into v, if not nil.
+func (m *mixpanel) appRequest(ctx context.Context, method, path string, query url.Values, body, v interface{}) error {
+	sa := m.serviceAccount
+	if sa == nil {
+		return ErrNoServiceAccount
+	}
+
+	ctx, cancel := m.withTimeout(ctx, "query")
+	defer cancel()
+
+	reqUrl := fmt.Sprintf("%s/app/projects/%d%s", m.QueryURL, sa.projectId, path)
+	if len(query) > 0 {
+		reqUrl += "?" + query.Encode()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl, reqBody)
+	if err != nil {
+		return err
+	}
+
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.SetBasicAuth(sa.username, sa.secret)
+
+	status, respBody, err := m.do(req)
+
+	if err != nil {
+		return err
+	}
+
+	if status < 200 || status > 299 {
+		return apiError(reqUrl, status, respBody)
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	resp := struct {
+		Results interface{} `json:"results"`
+	}{v}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return &MixpanelError{URL: reqUrl, HttpStatus: status, Message: err.Error()}
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package mixpanel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConnectorStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/app/projects/12345/warehouse-sources/imports/orders" {
+			t.Errorf("path returned %+v", r.URL.Path)
+		}
+		if user, pass, _ := r.BasicAuth(); user != "ops.ab12cd.mp-service-account" || pass != "sa-secret" {
+			t.Errorf("basic auth returned %q:%q", user, pass)
+		}
+		w.Write([]byte(`{"status":"ok","results":{"state":"failed","last_run_at":"2016-03-03T15:17:53Z","last_error":"table not found"}}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", "", WithQueryURL(ts.URL),
+		WithServiceAccount("ops.ab12cd.mp-service-account", "sa-secret", 12345))
+
+	status, err := client.ConnectorStatus("orders")
+	if err != nil {
+		t.Fatalf("ConnectorStatus returned %v", err)
+	}
+
+	want := &ConnectorStatus{
+		State:     "failed",
+		LastRunAt: time.Date(2016, 3, 3, 15, 17, 53, 0, time.UTC),
+		LastError: "table not found",
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("ConnectorStatus returned %+v, want %+v", status, want)
+	}
+}
+
+func TestConnectorStatusNoServiceAccount(t *testing.T) {
+	setup()
+	defer teardown()
+
+	if _, err := client.ConnectorStatus("orders"); err != ErrNoServiceAccount {
+		t.Errorf("ConnectorStatus returned %v, want %v", err, ErrNoServiceAccount)
+	}
+}
+
+func TestServiceAccountImport(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL,
+		WithServiceAccount("ops.ab12cd.mp-service-account", "sa-secret", 12345))
+
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	client.Track("13793", "Signed Up", &Event{Timestamp: &old})
+
+	if LastRequest.URL.Path != "/import" {
+		t.Fatalf("path returned %+v, want %+v", LastRequest.URL.Path, "/import")
+	}
+	if user, pass, _ := LastRequest.BasicAuth(); user != "ops.ab12cd.mp-service-account" || pass != "sa-secret" {
+		t.Errorf("basic auth returned %q:%q", user, pass)
+	}
+	if got := LastRequest.URL.Query().Get("project_id"); got != "12345" {
+		t.Errorf("project_id returned %+v, want %+v", got, "12345")
+	}
+
+	client.Track("13793", "Signed Up", &Event{})
+
+	if user, pass, _ := LastRequest.BasicAuth(); user != "secret" || pass != "" {
+		t.Errorf("track basic auth returned %q:%q, want the API secret", user, pass)
+	}
+	if got := LastRequest.URL.Query().Get("project_id"); got != "" {
+		t.Errorf("track project_id returned %+v, want it unset", got)
+	}
+}
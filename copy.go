@@ -0,0 +1,98 @@
+package mixpanel
+
+import (
+	"context"
+	"time"
+)
+
+// copyBatchSize is the number of events CopyEvents imports per request.
+const copyBatchSize = 2000
+
+// copyRetries is the number of times CopyEvents retries a batch that failed
+// with a transient error, waiting copyBackoff between attempts.
+const copyRetries = 5
+
+var copyBackoff = Backoff{Base: time.Second, Max: 30 * time.Second, Jitter: true}
+
+// CopyEvents streams the events selected by from out of this client's project
+// and imports them into the project of dest with ImportNDJSON, for example to
+// migrate between projects. Events are rewritten to carry dest's token but
+// keep their distinct id, time and other properties, including $insert_id, so
+// that copying the same range twice does not create duplicates. Batches that
+// fail with a transient error are retried with backoff. The copy stops when
+// ctx is done.
+func (m *mixpanel) CopyEvents(ctx context.Context, from ExportParams, dest Mixpanel) error {
+	return copyEvents(ctx, dest, func(fn func(ExportedEvent) error) error {
+		return m.ExportEach(ctx, from, fn)
+	})
+}
+
+// copyEvents imports the events produced by export into dest in batches.
+func copyEvents(ctx context.Context, dest Mixpanel, export func(func(ExportedEvent) error) error) error {
+	var batch []BatchEvent
+
+	err := export(func(e ExportedEvent) error {
+		batch = append(batch, exportedToBatch(e))
+		if len(batch) < copyBatchSize {
+			return nil
+		}
+		err := importWithRetry(ctx, dest, batch)
+		batch = nil
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(batch) > 0 {
+		return importWithRetry(ctx, dest, batch)
+	}
+
+	return nil
+}
+
+// exportedToBatch turns an exported event back into an event to import. The
+// token, distinct id and time are taken out of the properties, since the
+// importing client sets them itself.
+func exportedToBatch(e ExportedEvent) BatchEvent {
+	b := BatchEvent{
+		EventName: e.Event,
+		Event:     Event{Properties: map[string]interface{}{}},
+	}
+
+	for key, value := range e.Properties {
+		switch key {
+		case "token":
+		case "distinct_id":
+			b.DistinctId, _ = value.(string)
+		case "time":
+			if secs, ok := value.(float64); ok {
+				t := time.Unix(int64(secs), 0)
+				b.Timestamp = &t
+			}
+		default:
+			b.Properties[key] = value
+		}
+	}
+
+	return b
+}
+
+func importWithRetry(ctx context.Context, dest Mixpanel, batch []BatchEvent) error {
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := dest.ImportNDJSON(batch)
+		if err == nil || !IsRetryable(err) || attempt == copyRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(copyBackoff.Delay(attempt)):
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package mixpanel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newExportServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2.0/export" {
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+		w.Write([]byte(
+			"{\"event\":\"Signed Up\",\"properties\":{\"token\":\"source\",\"distinct_id\":\"13793\",\"time\":1457018273,\"$insert_id\":\"a1\",\"plan\":\"pro\"}}\n" +
+				"{\"event\":\"Logged In\",\"properties\":{\"token\":\"source\",\"distinct_id\":\"13794\",\"time\":1457018274,\"$insert_id\":\"a2\"}}\n"))
+	}))
+}
+
+func TestCopyEvents(t *testing.T) {
+	ts := newExportServer(t)
+	defer ts.Close()
+
+	source := New("source", "", "secret", "", WithExportURL(ts.URL))
+	dest := NewMock()
+
+	if err := source.CopyEvents(context.Background(), ExportParams{}, dest); err != nil {
+		t.Fatalf("CopyEvents returned %v", err)
+	}
+
+	events := dest.People["13793"].Events
+	if len(events) != 1 || events[0].Name != "Signed Up" {
+		t.Fatalf("dest received %+v", events)
+	}
+	if want := time.Unix(1457018273, 0); events[0].Timestamp == nil || !events[0].Timestamp.Equal(want) {
+		t.Errorf("Timestamp returned %v, want %v", events[0].Timestamp, want)
+	}
+	want := map[string]interface{}{"$insert_id": "a1", "plan": "pro"}
+	if !reflect.DeepEqual(events[0].Properties, want) {
+		t.Errorf("Properties returned %+v, want %+v", events[0].Properties, want)
+	}
+	if n := len(dest.People["13794"].Events); n != 1 {
+		t.Errorf("dest received %d events for 13794, want 1", n)
+	}
+}
+
+func TestCopyEventsRetries(t *testing.T) {
+	ts := newExportServer(t)
+	defer ts.Close()
+
+	var attempts int
+	destServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(503)
+			return
+		}
+		w.Write([]byte(`{"code":200,"status":"OK"}`))
+	}))
+	defer destServer.Close()
+
+	defer func(b Backoff) { copyBackoff = b }(copyBackoff)
+	copyBackoff = Backoff{Base: time.Millisecond}
+
+	source := New("source", "", "secret", "", WithExportURL(ts.URL))
+	dest := New("dest", "", "secret", destServer.URL)
+
+	if err := source.CopyEvents(context.Background(), ExportParams{}, dest); err != nil {
+		t.Fatalf("CopyEvents returned %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("dest received %d import requests, want 2", attempts)
+	}
+}
+
+func TestCopyEventsCancelled(t *testing.T) {
+	ts := newExportServer(t)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	source := New("source", "", "secret", "", WithExportURL(ts.URL))
+	dest := NewMock()
+
+	if err := source.CopyEvents(ctx, ExportParams{}, dest); err == nil {
+		t.Errorf("CopyEvents returned no error for a cancelled context")
+	}
+	if len(dest.People) != 0 {
+		t.Errorf("dest received events after cancellation")
+	}
+}
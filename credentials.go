@@ -0,0 +1,46 @@
+package mixpanel
+
+import "net/http"
+
+// A ServiceAccount holds the credentials of a Mixpanel service account, as
+// given to WithServiceAccount.
+type ServiceAccount struct {
+	Username string
+	Secret   string
+
+	// ProjectID is the numeric id of the project, shown in its settings.
+	ProjectID int
+}
+
+// Credentials gathers the credentials of a project. Each request uses those
+// its endpoint takes: the token for the ingestion payloads, the service
+// account, when set, for /import, the query APIs, Export and the app API,
+// and the API secret for the rest.
+type Credentials struct {
+	Token     string
+	APISecret string
+
+	// ServiceAccount is optional, but needed by the app API, such as
+	// ConnectorStatus and the Lexicon schemas.
+	ServiceAccount *ServiceAccount
+}
+
+// WithCredentials sets the credentials of the client, replacing the token and
+// API secret given to New. An empty Token leaves the token as is.
+func WithCredentials(c Credentials) Option {
+	return func(m *mixpanel) {
+		if c.Token != "" {
+			m.Token = c.Token
+		}
+		m.ApiSecret = c.APISecret
+		if sa := c.ServiceAccount; sa != nil {
+			WithServiceAccount(sa.Username, sa.Secret, sa.ProjectID)(m)
+		}
+	}
+}
+
+// NewWithCredentials returns a client sending to Mixpanel's default hosts with
+// http.DefaultClient, authenticated with c.
+func NewWithCredentials(c Credentials, opts ...Option) Mixpanel {
+	return NewFromClient(http.DefaultClient, c.Token, "", "", "", append([]Option{WithCredentials(c)}, opts...)...)
+}
@@ -0,0 +1,44 @@
+package mixpanel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCredentials(t *testing.T) {
+	type auth struct{ user, projectId string }
+	var got []auth
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		got = append(got, auth{user, r.URL.Query().Get("project_id")})
+		if r.URL.Path == "/2.0/engage" {
+			w.Write([]byte(`{"results":[],"page":0,"page_size":1000}`))
+			return
+		}
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	client := NewWithCredentials(Credentials{
+		Token:          "e3bc4100330c35722740fb8c6f5abddc",
+		APISecret:      "secret",
+		ServiceAccount: &ServiceAccount{Username: "ops.ab12cd.mp-service-account", Secret: "sa-secret", ProjectID: 12345},
+	}, WithAPIURL(ts.URL), WithQueryURL(ts.URL))
+
+	if err := client.Track("13793", "Signed Up", &Event{}); err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+	if _, err := client.QueryProfiles(context.Background(), ProfileQuery{}); err != nil {
+		t.Fatalf("QueryProfiles returned %v", err)
+	}
+
+	want := []auth{{"secret", ""}, {"ops.ab12cd.mp-service-account", "12345"}}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("auth returned %+v, want %+v", got, want)
+	}
+	if m := client.(*mixpanel); m.Token != "e3bc4100330c35722740fb8c6f5abddc" {
+		t.Errorf("Token returned %+v", m.Token)
+	}
+}
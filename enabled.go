@@ -0,0 +1,24 @@
+package mixpanel
+
+// WithEnabledFunc calls enabled before every ingestion request, such as
+// those of Track, Update and the batch methods, and drops the request
+// without sending anything, to Mixpanel or to the Sink of WithSink, when it
+// returns false. The call then returns nil as if the request had been sent;
+// events and updates are still checked first, so that invalid ones are
+// reported either way, except by ImportNDJSON. enabled can change its
+// answer at any time, for example when a feature flag or the consent of the
+// user changes; it must be safe for concurrent use. The query methods and
+// Export are not affected, but the calls reading profiles to update them,
+// Merge with PreferProfile, ReconcileCohorts and UnsetPropertyWhere, return
+// nil at once, without reading anything.
+func WithEnabledFunc(enabled func() bool) Option {
+	return func(m *mixpanel) {
+		m.enabled = enabled
+	}
+}
+
+// disabled reports whether the function of WithEnabledFunc turns ingestion
+// off.
+func (m *mixpanel) disabled() bool {
+	return m.enabled != nil && !m.enabled()
+}
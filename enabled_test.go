@@ -0,0 +1,59 @@
+package mixpanel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWithEnabledFunc(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	var enabled int32
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL, WithStrictMode(), WithQueryURL(ts.URL), WithEnabledFunc(func() bool {
+		return atomic.LoadInt32(&enabled) == 1
+	}))
+
+	events := []BatchEvent{{DistinctId: "13793", EventName: "Signed Up"}}
+	calls := map[string]func() error{
+		"Track":        func() error { return client.Track("13793", "Signed Up", &Event{}) },
+		"Update":       func() error { return client.PeopleSet("13793", map[string]interface{}{"Plan": "pro"}) },
+		"TrackBatch":   func() error { return client.TrackBatch(events) },
+		"ImportBatch":  func() error { return client.ImportBatch(events) },
+		"ImportNDJSON": func() error { return client.ImportNDJSON(events) },
+
+		"Merge": func() error {
+			return client.Merge([]string{"13793", "13794"}, PreferProfile("13793"))
+		},
+		"ReconcileCohorts": func() error { return client.ReconcileCohorts("13793", []string{"vip"}) },
+		"UnsetPropertyWhere": func() error {
+			_, err := client.UnsetPropertyWhere(context.Background(), `defined(properties["legacy"])`, []string{"legacy"})
+			return err
+		},
+	}
+	for name, call := range calls {
+		if err := call(); err != nil {
+			t.Errorf("%v returned %v while disabled", name, err)
+		}
+	}
+	if got := atomic.LoadInt32(&requests); got != 0 {
+		t.Errorf("requests returned %+v while disabled, want %+v", got, 0)
+	}
+
+	if err := client.PeopleSet("13793", map[string]interface{}{"$email": 42}); err == nil {
+		t.Errorf("PeopleSet returned no error for an invalid update while disabled")
+	}
+
+	atomic.StoreInt32(&enabled, 1)
+	client.Track("13793", "Signed Up", &Event{})
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("requests returned %+v once enabled, want %+v", got, 1)
+	}
+}
@@ -0,0 +1,153 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A FailedRequest is a request recorded by WithErrorRing.
+type FailedRequest struct {
+	// Time is when the request failed, read from the Clock of WithClock if
+	// any.
+	Time     time.Time
+	Endpoint string
+
+	// Payload is the decoded JSON payload of the request, with the project
+	// token replaced by "REDACTED".
+	Payload interface{}
+
+	// Err is the error of the request. A *MixpanelError in it is replaced by
+	// a copy whose Payload and URL have the token redacted too.
+	Err error
+}
+
+// WithErrorRing keeps the last size failed requests so that they can be
+// inspected with RecentErrors, for example from an admin endpoint. Older
+// failures are evicted first.
+func WithErrorRing(size int) Option {
+	return func(m *mixpanel) {
+		if size > 0 {
+			m.errorRing = &errorRing{entries: make([]FailedRequest, size)}
+		}
+	}
+}
+
+// RecentErrors returns the failed requests recorded by WithErrorRing, oldest
+// first. It returns nil if the option is not in use.
+func (m *mixpanel) RecentErrors() []FailedRequest {
+	if m.errorRing == nil {
+		return nil
+	}
+	return m.errorRing.list()
+}
+
+type errorRing struct {
+	mu      sync.Mutex
+	entries []FailedRequest
+	next    int
+	full    bool
+}
+
+// add records a request to endpoint, with the JSON payload data, that failed
+// with err at now.
+func (r *errorRing) add(now time.Time, endpoint string, data []byte, err error) {
+	var payload interface{}
+	if json.Unmarshal(data, &payload) == nil {
+		redactToken(payload)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries[r.next] = FailedRequest{
+		Time:     now,
+		Endpoint: endpoint,
+		Payload:  payload,
+		Err:      redactError(err),
+	}
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *errorRing) list() []FailedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([]FailedRequest(nil), r.entries[:r.next]...)
+	}
+
+	list := make([]FailedRequest, 0, len(r.entries))
+	list = append(list, r.entries[r.next:]...)
+	return append(list, r.entries[:r.next]...)
+}
+
+// redactError returns a copy of the *MixpanelError in err, if any, with the
+// token redacted from its payload, and the data parameter, which holds the
+// token too, removed from its URL. err itself is left unchanged.
+func redactError(err error) error {
+	var mpErr *MixpanelError
+	if !errors.As(err, &mpErr) {
+		return err
+	}
+
+	redacted := *mpErr
+	redacted.Payload = nil
+	var payload interface{}
+	if json.Unmarshal(mpErr.Payload, &payload) == nil {
+		redactToken(payload)
+		redacted.Payload, _ = json.Marshal(payload)
+	}
+
+	redacted.URL = redactURL(mpErr.URL)
+	redacted.Message = strings.Replace(mpErr.Message, mpErr.URL, redacted.URL, -1)
+	var urlErr *url.Error
+	if errors.As(mpErr.Err, &urlErr) {
+		redactedErr := *urlErr
+		redactedErr.URL = redactURL(urlErr.URL)
+		redacted.Err = &redactedErr
+	}
+
+	return &redacted
+}
+
+// redactURL returns rawURL with the value of its data parameter replaced by
+// "REDACTED".
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	query := u.Query()
+	if _, ok := query["data"]; !ok {
+		return rawURL
+	}
+	query.Set("data", "REDACTED")
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// redactToken replaces every "token" and "$token" value in a decoded JSON
+// payload.
+func redactToken(v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if key == "token" || key == "$token" {
+				v[key] = "REDACTED"
+				continue
+			}
+			redactToken(value)
+		}
+	case []interface{}:
+		for _, value := range v {
+			redactToken(value)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package mixpanel
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestErrorRing(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+		w.Write([]byte(`{"status":0,"error":"invalid"}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithErrorRing(2))
+
+	for i := 1; i <= 3; i++ {
+		client.Track("13793", "Event "+strconv.Itoa(i), &Event{})
+	}
+
+	failed := client.RecentErrors()
+	if len(failed) != 2 {
+		t.Fatalf("RecentErrors returned %d requests, want 2", len(failed))
+	}
+
+	want := map[string]interface{}{
+		"event": "Event 2",
+		"properties": map[string]interface{}{
+			"distinct_id": "13793",
+			"token":       "REDACTED",
+		},
+	}
+	if !reflect.DeepEqual(failed[0].Payload, want) {
+		t.Errorf("oldest payload returned %+v, want %+v", failed[0].Payload, want)
+	}
+	if failed[1].Payload.(map[string]interface{})["event"] != "Event 3" {
+		t.Errorf("newest payload returned %+v", failed[1].Payload)
+	}
+	if failed[1].Endpoint != "track" || failed[1].Err == nil {
+		t.Errorf("newest failure returned %+v", failed[1])
+	}
+}
+
+func TestErrorRingDisabled(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.Track("13793", "Signed Up", &Event{})
+
+	if failed := client.RecentErrors(); failed != nil {
+		t.Errorf("RecentErrors returned %+v, want nil", failed)
+	}
+}
+
+func TestErrorRingRedactsError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithErrorRing(1), WithQueryStringData())
+	err := client.Track("13793", "Signed Up", &Event{})
+
+	failed := client.RecentErrors()
+	if len(failed) != 1 {
+		t.Fatalf("RecentErrors returned %d requests, want 1", len(failed))
+	}
+
+	mpErr, ok := failed[0].Err.(*MixpanelError)
+	if !ok {
+		t.Fatalf("Err returned %v, want a *MixpanelError", failed[0].Err)
+	}
+	for name, s := range map[string]string{
+		"Payload": string(mpErr.Payload),
+		"URL":     mpErr.URL,
+		"Message": mpErr.Message,
+		"Err":     mpErr.Err.Error(),
+	} {
+		// "eyJ" starts the base64 encoding of the payload.
+		if strings.Contains(s, "e3bc4100330c35722740fb8c6f5abddc") || strings.Contains(s, "eyJ") {
+			t.Errorf("%v returned %q, want the token redacted", name, s)
+		}
+	}
+
+	var original *MixpanelError
+	if errors.As(err, &original) && !strings.Contains(string(original.Payload), "e3bc4100330c35722740fb8c6f5abddc") {
+		t.Errorf("Payload of the returned error was redacted too")
+	}
+}
@@ -16,7 +16,7 @@ func ExampleMixpanel() {
 	})
 }
 
-func ExamplePeople() {
+func ExampleMixpanel_people() {
 	client := New("mytoken", "", "", "")
 
 	client.Update("1", &Update{
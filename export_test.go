@@ -0,0 +1,108 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExport(t *testing.T) {
+	var (
+		method string
+		query  url.Values
+		raw    string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		query = r.URL.Query()
+		raw = r.URL.RawQuery
+		w.Write([]byte("{\"event\":\"Signed Up\",\"properties\":{\"distinct_id\":\"13793\",\"plan\":\"pro\"}}\n" +
+			"{\"event\":\"Signed Up\",\"properties\":{\"distinct_id\":\"13794\",\"plan\":\"pro\"}}\n"))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", "", WithExportURL(ts.URL))
+
+	events, err := client.Export(ExportParams{
+		From:   time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC),
+		To:     time.Date(2016, 3, 3, 0, 0, 0, 0, time.UTC),
+		Events: []string{"Signed Up", "Logged In"},
+		Where:  `properties["plan"] == "pro"`,
+	})
+	if err != nil {
+		t.Fatalf("Export returned %v", err)
+	}
+
+	if method != http.MethodGet {
+		t.Errorf("method returned %+v, want %+v", method, http.MethodGet)
+	}
+
+	wantQuery := url.Values{
+		"from_date": {"2016-03-01"},
+		"to_date":   {"2016-03-03"},
+		"event":     {`["Signed Up","Logged In"]`},
+		"where":     {`properties["plan"] == "pro"`},
+	}
+	if !reflect.DeepEqual(query, wantQuery) {
+		t.Errorf("query returned %+v, want %+v", query, wantQuery)
+	}
+	if !strings.Contains(raw, "event=%5B%22Signed+Up%22%2C%22Logged+In%22%5D") {
+		t.Errorf("raw query %+v does not contain the encoded event array", raw)
+	}
+
+	want := []ExportedEvent{
+		{Event: "Signed Up", Properties: map[string]interface{}{"distinct_id": "13793", "plan": "pro"}},
+		{Event: "Signed Up", Properties: map[string]interface{}{"distinct_id": "13794", "plan": "pro"}},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Export returned %+v, want %+v", events, want)
+	}
+}
+
+func TestExportEach(t *testing.T) {
+	sent := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{\"event\":\"Signed Up\",\"properties\":{\"distinct_id\":\"13793\"}}\n"))
+		w.(http.Flusher).Flush()
+
+		// The rest of the export is only written once the first event has
+		// been handed to the callback.
+		select {
+		case <-sent:
+		case <-time.After(time.Second):
+			t.Errorf("first event not decoded before the end of the response")
+		}
+		w.Write([]byte("{\"event\":\"Logged In\",\"properties\":{\"distinct_id\":\"13793\"}}\n"))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", "", WithExportURL(ts.URL))
+
+	var names []string
+	err := client.ExportEach(context.Background(), ExportParams{}, func(e ExportedEvent) error {
+		names = append(names, e.Event)
+		if len(names) == 1 {
+			close(sent)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExportEach returned %v", err)
+	}
+	if want := []string{"Signed Up", "Logged In"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ExportEach returned %+v, want %+v", names, want)
+	}
+
+	stop := errors.New("stop")
+	if err := client.ExportEach(context.Background(), ExportParams{}, func(e ExportedEvent) error {
+		return stop
+	}); err != stop {
+		t.Errorf("ExportEach returned %v, want %v", err, stop)
+	}
+}
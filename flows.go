@@ -0,0 +1,79 @@
+package mixpanel
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// FlowsOpts refines a Flows query.
+type FlowsOpts struct {
+	// Steps is the number of events after the anchor event to follow, or
+	// before it with Reverse. The default is 3.
+	Steps int
+
+	// Reverse follows the sequences leading to the anchor event instead of
+	// those starting from it.
+	Reverse bool
+
+	// Where is a segmentation expression the users must match, for example
+	// `properties["plan"] == "pro"`.
+	Where string
+
+	// Limit caps the number of sequences returned, most common first. Zero
+	// means Mixpanel's default.
+	Limit int
+}
+
+// FlowsResult holds the most common event sequences found by Flows.
+type FlowsResult struct {
+	Flows []Flow `json:"flows"`
+}
+
+// A Flow is an event sequence and the number of users who went through it.
+type Flow struct {
+	// Steps are the event names of the sequence, starting with the anchor
+	// event, or ending with it for a reverse query.
+	Steps []string `json:"steps"`
+	Count int      `json:"count"`
+}
+
+func (opts FlowsOpts) values(event string, from, to time.Time) url.Values {
+	v := url.Values{
+		"event":     {event},
+		"from_date": {from.Format("2006-01-02")},
+		"to_date":   {to.Format("2006-01-02")},
+		"steps":     {"3"},
+	}
+	if opts.Steps > 0 {
+		v.Set("steps", strconv.Itoa(opts.Steps))
+	}
+	if opts.Reverse {
+		v.Set("direction", "reverse")
+	}
+	if opts.Where != "" {
+		v.Set("where", opts.Where)
+	}
+	if opts.Limit > 0 {
+		v.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	return v
+}
+
+// Flows returns the most common sequences of events users performed after
+// event, or before it with opts.Reverse, between the days from and to,
+// inclusive. It reads the /2.0/flows endpoint of the query API, authenticated
+// with the API secret.
+func (m *mixpanel) Flows(event string, from, to time.Time, opts FlowsOpts) (*FlowsResult, error) {
+	return m.FlowsCtx(context.Background(), event, from, to, opts)
+}
+
+// FlowsCtx is like Flows, but sends the request with ctx.
+func (m *mixpanel) FlowsCtx(ctx context.Context, event string, from, to time.Time, opts FlowsOpts) (*FlowsResult, error) {
+	var result FlowsResult
+	if err := m.queryAt(ctx, m.QueryURL, "/2.0/flows", opts.values(event, from, to), &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,40 @@
+package mixpanel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFlows(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2.0/flows" || r.Method != http.MethodGet {
+			t.Errorf("request returned %s %s", r.Method, r.URL.Path)
+		}
+		want := "direction=reverse&event=Subscribed&from_date=2016-03-01&steps=2&to_date=2016-03-03"
+		if r.URL.RawQuery != want {
+			t.Errorf("query returned %+v, want %+v", r.URL.RawQuery, want)
+		}
+		w.Write([]byte(`{"flows":[{"steps":["Viewed Pricing","Started Trial","Subscribed"],"count":42},{"steps":["Signed Up","Viewed Pricing","Subscribed"],"count":17}]}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", "", WithQueryURL(ts.URL))
+
+	result, err := client.Flows("Subscribed",
+		time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2016, 3, 3, 0, 0, 0, 0, time.UTC),
+		FlowsOpts{Steps: 2, Reverse: true})
+	if err != nil {
+		t.Fatalf("Flows returned %v", err)
+	}
+
+	want := &FlowsResult{Flows: []Flow{
+		{Steps: []string{"Viewed Pricing", "Started Trial", "Subscribed"}, Count: 42},
+		{Steps: []string{"Signed Up", "Viewed Pricing", "Subscribed"}, Count: 17},
+	}}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("Flows returned %+v, want %+v", result, want)
+	}
+}
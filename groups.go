@@ -0,0 +1,93 @@
+package mixpanel
+
+import "context"
+
+// A GroupUpdateRequest is an operation on a group profile, sent by
+// GroupUpdate.
+type GroupUpdateRequest struct {
+	// Operation such as "$set", "$set_once", "$union", "$remove" or "$unset".
+	Operation string
+
+	// Properties of the operation. Unused by "$unset".
+	Properties map[string]interface{}
+
+	// Unset is the list of property names removed by "$unset".
+	Unset []string
+}
+
+// InGroup attributes a tracked event to the groups groupIds of the group key
+// groupKey, such as "company_id", by setting the groupKey property of the
+// event: to the id when there is one, or to the list of ids. Only Track,
+// TrackCtx and the Mock use this option; it can be given once per group key.
+func InGroup(groupKey string, groupIds ...string) CallOption {
+	return func(call *callOptions) {
+		if call.groups == nil {
+			call.groups = map[string][]string{}
+		}
+		call.groups[groupKey] = groupIds
+	}
+}
+
+// withGroups returns e with the group properties given with InGroup, leaving
+// e itself unchanged. call may be nil.
+func withGroups(e *Event, call *callOptions) *Event {
+	if call == nil || len(call.groups) == 0 {
+		return e
+	}
+
+	grouped := *e
+	grouped.Properties = copyMap(e.Properties)
+	for key, ids := range call.groups {
+		if len(ids) == 1 {
+			grouped.Properties[key] = ids[0]
+		} else {
+			grouped.Properties[key] = ids
+		}
+	}
+
+	return &grouped
+}
+
+// GroupUpdate applies g to the group profile identified by groupKey, the
+// group key property such as "company_id", and groupID, the group's value of
+// that property.
+func (m *mixpanel) GroupUpdate(groupKey, groupID string, g *GroupUpdateRequest, opts ...CallOption) error {
+	return m.GroupUpdateCtx(context.Background(), groupKey, groupID, g, opts...)
+}
+
+// GroupUpdateCtx is like GroupUpdate, but sends the request with ctx.
+func (m *mixpanel) GroupUpdateCtx(ctx context.Context, groupKey, groupID string, g *GroupUpdateRequest, opts ...CallOption) error {
+	params := m.groupParams(groupKey, groupID)
+
+	if g.Operation == OpUnset {
+		params["$unset"] = g.Unset
+	} else {
+		if err := m.validateProfile(g.Properties); err != nil {
+			return err
+		}
+		params[g.Operation] = m.scrubProperties(m.coerceProperties(g.Properties))
+	}
+
+	return m.send(ctx, "groups", params, false, newCallOptions(opts))
+}
+
+// GroupDelete deletes the group profile identified by groupKey and groupID.
+func (m *mixpanel) GroupDelete(groupKey, groupID string, opts ...CallOption) error {
+	return m.GroupDeleteCtx(context.Background(), groupKey, groupID, opts...)
+}
+
+// GroupDeleteCtx is like GroupDelete, but sends the request with ctx.
+func (m *mixpanel) GroupDeleteCtx(ctx context.Context, groupKey, groupID string, opts ...CallOption) error {
+	params := m.groupParams(groupKey, groupID)
+	params["$delete"] = ""
+
+	return m.send(ctx, "groups", params, false, newCallOptions(opts))
+}
+
+func (m *mixpanel) groupParams(groupKey, groupID string) map[string]interface{} {
+	return map[string]interface{}{
+		"$token":     m.Token,
+		"$group_key": groupKey,
+		"$group_id":  groupID,
+	}
+}
@@ -0,0 +1,67 @@
+package mixpanel
+
+import (
+	"testing"
+)
+
+func TestGroupUpdate(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.GroupUpdate("company_id", "acme", &GroupUpdateRequest{
+		Operation:  "$set",
+		Properties: map[string]interface{}{"Plan": "enterprise"},
+	})
+
+	want := "{\"$group_id\":\"acme\",\"$group_key\":\"company_id\",\"$set\":{\"Plan\":\"enterprise\"},\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("LastRequest.URL returned %+v, want %+v", got, want)
+	}
+	if LastRequest.URL.Path != "/groups" {
+		t.Errorf("path returned %+v, want %+v", LastRequest.URL.Path, "/groups")
+	}
+}
+
+func TestGroupUpdateUnset(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.GroupUpdate("company_id", "acme", &GroupUpdateRequest{
+		Operation: "$unset",
+		Unset:     []string{"Plan"},
+	})
+
+	want := "{\"$group_id\":\"acme\",\"$group_key\":\"company_id\",\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\",\"$unset\":[\"Plan\"]}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("LastRequest.URL returned %+v, want %+v", got, want)
+	}
+}
+
+func TestGroupDelete(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.GroupDelete("company_id", "acme")
+
+	want := "{\"$delete\":\"\",\"$group_id\":\"acme\",\"$group_key\":\"company_id\",\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("LastRequest.URL returned %+v, want %+v", got, want)
+	}
+}
+
+func TestInGroup(t *testing.T) {
+	setup()
+	defer teardown()
+
+	props := map[string]interface{}{"Plan": "pro"}
+	client.Track("13793", "Signed Up", &Event{IP: "0", Properties: props},
+		InGroup("company_id", "acme"), InGroup("team_id", "red", "blue"))
+
+	want := "{\"event\":\"Signed Up\",\"properties\":{\"Plan\":\"pro\",\"company_id\":\"acme\",\"distinct_id\":\"13793\",\"ip\":\"0\",\"team_id\":[\"red\",\"blue\"],\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("LastRequest.URL returned %+v, want %+v", got, want)
+	}
+	if len(props) != 1 {
+		t.Errorf("Track modified the properties of the event: %+v", props)
+	}
+}
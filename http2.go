@@ -0,0 +1,40 @@
+package mixpanel
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// NewHTTP2Client returns an *http.Client whose transport negotiates HTTP/2
+// with the Mixpanel API, so that many concurrent sends share a single
+// multiplexed connection instead of opening one connection per request. Pass
+// it to NewFromClient.
+//
+// Proxies are taken from the environment (HTTPS_PROXY and friends). Requests
+// to an https URL are tunnelled through the proxy with CONNECT, so HTTP/2 is
+// still negotiated end to end with Mixpanel. The client falls back to
+// HTTP/1.1 when the server does not offer HTTP/2.
+//
+// A timeout of zero means no timeout.
+func NewHTTP2Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+}
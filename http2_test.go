@@ -0,0 +1,82 @@
+package mixpanel
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTLSServer(http2 bool, proto *string) *httptest.Server {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if proto != nil {
+			*proto = r.Proto
+		}
+		w.WriteHeader(200)
+		w.Write([]byte("{\"status\":1,\"error\":null}"))
+	}))
+	ts.EnableHTTP2 = http2
+	ts.StartTLS()
+	return ts
+}
+
+func trustServer(c *http.Client, ts *httptest.Server) *http.Client {
+	c.Transport.(*http.Transport).TLSClientConfig = &tls.Config{
+		RootCAs: ts.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs,
+	}
+	return c
+}
+
+func TestNewHTTP2Client(t *testing.T) {
+	var proto string
+	ts := newTLSServer(true, &proto)
+	defer ts.Close()
+
+	c := trustServer(NewHTTP2Client(0), ts)
+	client := NewFromClient(c, "e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+
+	err := client.Track("13793", "Signed Up", &Event{
+		Properties: map[string]interface{}{
+			"Referred By": "Friend",
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+	if proto != "HTTP/2.0" {
+		t.Errorf("request protocol returned %+v, want %+v", proto, "HTTP/2.0")
+	}
+}
+
+func benchmarkTrackParallel(b *testing.B, c *http.Client, ts *httptest.Server) {
+	client := NewFromClient(c, "e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+	e := &Event{
+		Properties: map[string]interface{}{
+			"Referred By": "Friend",
+		},
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := client.Track("13793", "Signed Up", e); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkTrackHTTP1(b *testing.B) {
+	ts := newTLSServer(false, nil)
+	defer ts.Close()
+
+	benchmarkTrackParallel(b, ts.Client(), ts)
+}
+
+func BenchmarkTrackHTTP2(b *testing.B) {
+	ts := newTLSServer(true, nil)
+	defer ts.Close()
+
+	benchmarkTrackParallel(b, trustServer(NewHTTP2Client(0), ts), ts)
+}
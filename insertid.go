@@ -0,0 +1,81 @@
+package mixpanel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// WithAutoInsertID makes every event carry a $insert_id derived from its
+// distinct id, name, time and properties, so that Mixpanel drops duplicates
+// of it, such as those sent by a retry or a double call. The id is the first
+// 32 hex digits of a SHA-256 hash of the four, so unrelated events
+// practically never collide, but the time is taken to the second: two events
+// with the same name and properties for the same distinct id in the same
+// second get the same id, and only one of them is kept.
+//
+// Events without a Timestamp are stamped with the current time, which is
+// then sent as their time, so that duplicates are only recognised within the
+// same second. A $insert_id set with Event.InsertId, as a property of the
+// event or as a default property is kept.
+func WithAutoInsertID() Option {
+	return func(m *mixpanel) {
+		m.autoInsertId = true
+	}
+}
+
+func (m *mixpanel) addInsertId(eventType string, props map[string]interface{}, distinctId, eventName string, timestamp *time.Time) {
+	if _, ok := props["$insert_id"]; ok {
+		return
+	}
+
+	var t time.Time
+	if timestamp != nil {
+		t = *timestamp
+	} else {
+		t = m.now()
+		props["time"] = m.timestamp(eventType, t)
+	}
+
+	props["$insert_id"] = insertId(distinctId, eventName, t, props)
+}
+
+// insertId returns the $insert_id of an event, as described in
+// WithAutoInsertID. props are the properties of the event, whose time is
+// left out since t stands for it.
+func insertId(distinctId, eventName string, t time.Time, props map[string]interface{}) string {
+	h := sha256.New()
+	h.Write([]byte(distinctId))
+	h.Write([]byte{0})
+	h.Write([]byte(eventName))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatInt(t.Unix(), 10)))
+	h.Write([]byte{0})
+
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		if key != "time" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	// Each property is hashed on its own, in the order of its name, so that
+	// equal properties hash alike and a value encoding/json cannot encode,
+	// such as NaN, leaves only that property out of the hash.
+	for _, key := range keys {
+		value, err := json.Marshal(props[key])
+		if err != nil {
+			continue
+		}
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write(value)
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
@@ -0,0 +1,87 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAutoInsertID(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithAutoInsertID())
+
+	track := func(distinctId, eventName string, at time.Time, props map[string]interface{}) interface{} {
+		client.Track(distinctId, eventName, &Event{Timestamp: &at, Properties: props})
+		var e ExportedEvent
+		json.Unmarshal([]byte(decodeData(LastRequest)), &e)
+		return e.Properties["$insert_id"]
+	}
+
+	at := time.Now().Add(-time.Hour)
+	first := track("13793", "Signed Up", at, map[string]interface{}{"Plan": "pro"})
+	if id, ok := first.(string); !ok || len(id) != 32 {
+		t.Fatalf("$insert_id returned %+v, want 32 hex digits", first)
+	}
+
+	if again := track("13793", "Signed Up", at, map[string]interface{}{"Plan": "pro"}); again != first {
+		t.Errorf("$insert_id returned %+v for the same event, want %+v", again, first)
+	}
+
+	for _, other := range []interface{}{
+		track("13794", "Signed Up", at, map[string]interface{}{"Plan": "pro"}),
+		track("13793", "Logged In", at, map[string]interface{}{"Plan": "pro"}),
+		track("13793", "Signed Up", at.Add(time.Second), map[string]interface{}{"Plan": "pro"}),
+		track("13793", "Signed Up", at, map[string]interface{}{"Plan": "free"}),
+	} {
+		if other == first {
+			t.Errorf("$insert_id returned %+v for a different event", other)
+		}
+	}
+}
+
+func TestAutoInsertIDKeepsExplicit(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithAutoInsertID())
+	client.Track("13793", "Signed Up", &Event{Properties: map[string]interface{}{"$insert_id": "signup-13793"}})
+
+	var e ExportedEvent
+	json.Unmarshal([]byte(decodeData(LastRequest)), &e)
+	if got := e.Properties["$insert_id"]; got != "signup-13793" {
+		t.Errorf("$insert_id returned %+v, want %+v", got, "signup-13793")
+	}
+}
+
+func TestEventInsertId(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithAutoInsertID())
+	client.Track("13793", "Signed Up", &Event{
+		InsertId:   "signup-13793",
+		Properties: map[string]interface{}{"$insert_id": "other"},
+	})
+
+	var e ExportedEvent
+	json.Unmarshal([]byte(decodeData(LastRequest)), &e)
+	if got := e.Properties["$insert_id"]; got != "signup-13793" {
+		t.Errorf("$insert_id returned %+v, want %+v", got, "signup-13793")
+	}
+}
+
+func TestInsertIdSkipsUnencodableValues(t *testing.T) {
+	at := time.Now()
+	pro := insertId("13793", "Signed Up", at, map[string]interface{}{"Plan": "pro", "Score": math.NaN()})
+	free := insertId("13793", "Signed Up", at, map[string]interface{}{"Plan": "free", "Score": math.NaN()})
+	if pro == free {
+		t.Errorf("insertId returned %+v for events differing beside a NaN property", pro)
+	}
+
+	if got, want := pro, insertId("13793", "Signed Up", at, map[string]interface{}{"Plan": "pro"}); got != want {
+		t.Errorf("insertId returned %+v, want %+v, the id without the NaN property", got, want)
+	}
+}
@@ -0,0 +1,83 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// StreamJQL runs the JQL script on Mixpanel's side, with params available to
+// it as the params global, and writes its results to w as they are decoded,
+// one JSON value per line. Aggregating or transforming in the script keeps
+// the data pulled from Mixpanel small; the results are streamed rather than
+// held in memory, so large ones are fine too. params may be nil. The request
+// is authenticated with the API secret.
+func (m *mixpanel) StreamJQL(ctx context.Context, script string, params interface{}, w io.Writer) error {
+	ctx, cancel := m.withTimeout(ctx, "query")
+	defer cancel()
+
+	form := url.Values{"script": {script}}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		form.Set("params", string(data))
+	}
+
+	reqUrl := m.QueryURL + "/2.0/jql"
+
+	req, err := http.NewRequestWithContext(ctx, endpointMethod("/2.0/jql"), reqUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	m.authenticate(req, "query")
+
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return &MixpanelError{URL: reqUrl, Message: err.Error(), Err: err}
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return apiError(reqUrl, resp.StatusCode, body)
+	}
+
+	decodeErr := func(err error) error {
+		return &MixpanelError{URL: reqUrl, HttpStatus: resp.StatusCode, Message: err.Error()}
+	}
+
+	// The results are a single JSON array; decode it one element at a time.
+	dec := json.NewDecoder(resp.Body)
+	tok, err := dec.Token()
+	if err != nil {
+		return decodeErr(err)
+	}
+	if tok != json.Delim('[') {
+		return decodeErr(fmt.Errorf("unexpected %v at start of JQL results", tok))
+	}
+
+	for dec.More() {
+		var result json.RawMessage
+		if err := dec.Decode(&result); err != nil {
+			return decodeErr(err)
+		}
+		if _, err := fmt.Fprintf(w, "%s\n", result); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return decodeErr(err)
+	}
+
+	return nil
+}
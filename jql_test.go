@@ -0,0 +1,40 @@
+package mixpanel
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStreamJQL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/2.0/jql" || r.Method != http.MethodPost {
+			t.Errorf("request returned %s %s", r.Method, r.URL.Path)
+		}
+		if user, _, _ := r.BasicAuth(); user != "secret" {
+			t.Errorf("basic auth user returned %q, want %q", user, "secret")
+		}
+		r.ParseForm()
+		if got := r.PostForm.Get("params"); got != `{"from_date":"2016-03-01"}` {
+			t.Errorf("params returned %q", got)
+		}
+		w.Write([]byte(`[{"key":["Signed Up"],"value":12},` + "\n" + `{"key":["Watched Video"],"value":40}]`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", "", WithQueryURL(ts.URL))
+
+	script := `function main() { return Events(params).groupBy(["name"], mixpanel.reducer.count()); }`
+	var buf bytes.Buffer
+	err := client.StreamJQL(context.Background(), script, map[string]string{"from_date": "2016-03-01"}, &buf)
+	if err != nil {
+		t.Fatalf("StreamJQL returned %v", err)
+	}
+
+	want := `{"key":["Signed Up"],"value":12}` + "\n" + `{"key":["Watched Video"],"value":40}` + "\n"
+	if buf.String() != want {
+		t.Errorf("StreamJQL wrote %+v, want %+v", buf.String(), want)
+	}
+}
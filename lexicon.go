@@ -0,0 +1,52 @@
+package mixpanel
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// A SchemaEntry is the Lexicon schema of an event or of the profile
+// properties, which documents them in Mixpanel's data dictionary.
+type SchemaEntry struct {
+	// EntityType is "event" or "profile".
+	EntityType string `json:"entityType"`
+
+	// Name is the name of the event, or "$user" for the profile properties.
+	Name string `json:"name"`
+
+	// Schema is a JSON Schema describing the entity, for example:
+	//
+	//	map[string]interface{}{
+	//		"description": "A user signed up",
+	//		"properties": map[string]interface{}{
+	//			"Plan": map[string]interface{}{"type": "string"},
+	//		},
+	//	}
+	Schema map[string]interface{} `json:"schemaJson"`
+}
+
+// UploadSchemas creates or replaces the Lexicon schemas of entries in the
+// project of the service account given to WithServiceAccount, so that the
+// data dictionary can be kept in sync from code. It fails with
+// ErrNoServiceAccount without one.
+func (m *mixpanel) UploadSchemas(ctx context.Context, entries []SchemaEntry) error {
+	body := map[string]interface{}{"entries": entries}
+	return m.appRequest(ctx, http.MethodPost, "/schemas", nil, body, nil)
+}
+
+// ListSchemas returns the Lexicon schemas of the project of the service
+// account given to WithServiceAccount: all of them if entityType is empty,
+// and those of entityType, "event" or "profile", otherwise.
+func (m *mixpanel) ListSchemas(ctx context.Context, entityType string) ([]SchemaEntry, error) {
+	path := "/schemas"
+	if entityType != "" {
+		path += "/" + url.PathEscape(entityType)
+	}
+
+	var entries []SchemaEntry
+	if err := m.appRequest(ctx, http.MethodGet, path, nil, nil, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,84 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestUploadSchemas(t *testing.T) {
+	var body map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/app/projects/12345/schemas" {
+			t.Errorf("request returned %+v %+v", r.Method, r.URL.Path)
+		}
+		if user, pass, _ := r.BasicAuth(); user != "ops.ab12cd.mp-service-account" || pass != "sa-secret" {
+			t.Errorf("basic auth returned %q:%q", user, pass)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"status":"ok","results":{"added":1,"deleted":0}}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", "", WithQueryURL(ts.URL),
+		WithServiceAccount("ops.ab12cd.mp-service-account", "sa-secret", 12345))
+
+	err := client.UploadSchemas(context.Background(), []SchemaEntry{{
+		EntityType: "event",
+		Name:       "Signed Up",
+		Schema:     map[string]interface{}{"description": "A user signed up"},
+	}})
+	if err != nil {
+		t.Fatalf("UploadSchemas returned %v", err)
+	}
+
+	want := map[string]interface{}{
+		"entries": []interface{}{map[string]interface{}{
+			"entityType": "event",
+			"name":       "Signed Up",
+			"schemaJson": map[string]interface{}{"description": "A user signed up"},
+		}},
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("body returned %+v, want %+v", body, want)
+	}
+}
+
+func TestListSchemas(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/app/projects/12345/schemas/event" {
+			t.Errorf("path returned %+v", r.URL.Path)
+		}
+		w.Write([]byte(`{"status":"ok","results":[{"entityType":"event","name":"Signed Up","schemaJson":{"description":"A user signed up"}}]}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", "", WithQueryURL(ts.URL),
+		WithServiceAccount("ops.ab12cd.mp-service-account", "sa-secret", 12345))
+
+	entries, err := client.ListSchemas(context.Background(), "event")
+	if err != nil {
+		t.Fatalf("ListSchemas returned %v", err)
+	}
+
+	want := []SchemaEntry{{
+		EntityType: "event",
+		Name:       "Signed Up",
+		Schema:     map[string]interface{}{"description": "A user signed up"},
+	}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("ListSchemas returned %+v, want %+v", entries, want)
+	}
+}
+
+func TestSchemasNoServiceAccount(t *testing.T) {
+	setup()
+	defer teardown()
+
+	if _, err := client.ListSchemas(context.Background(), ""); err != ErrNoServiceAccount {
+		t.Errorf("ListSchemas returned %v, want %v", err, ErrNoServiceAccount)
+	}
+}
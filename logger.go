@@ -0,0 +1,111 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Logger receives diagnostic messages from the client. *log.Logger satisfies
+// it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// StructuredLogger is implemented by loggers that accept a message together
+// with key/value fields. When the logger given to WithLogger implements it,
+// diagnostics that carry fields are sent to Log. Otherwise they are formatted
+// for Printf as the message followed by key=value pairs in key order.
+type StructuredLogger interface {
+	Logger
+	Log(msg string, fields map[string]interface{})
+}
+
+// LeveledLogger is implemented by loggers that tell diagnostics apart by
+// severity. When the logger given to WithLogger implements it, the client
+// logs each request at debug level, event routing, retries and warnings
+// from Mixpanel at info level, and data it drops or could not validate at
+// error level. Loggers with Printf only get the info and error diagnostics.
+type LeveledLogger interface {
+	Logger
+	Debugf(format string, v ...interface{})
+	Infof(format string, v ...interface{})
+	Errorf(format string, v ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, v ...interface{}) {}
+
+// WithLogger routes the client's diagnostic messages to l. By default they are
+// discarded.
+func WithLogger(l Logger) Option {
+	return func(m *mixpanel) {
+		if l == nil {
+			l = nopLogger{}
+		}
+		m.logger = l
+	}
+}
+
+// logWarnings logs any non-fatal warnings included in a successful verbose
+// response, such as the use of a deprecated property.
+func (m *mixpanel) logWarnings(eventType string, body []byte) {
+	var resp struct {
+		Warnings []string `json:"warnings"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return
+	}
+
+	for _, warning := range resp.Warnings {
+		m.infof("mixpanel: %s warning: %s", eventType, warning)
+	}
+}
+
+// logFields logs msg with fields through the configured logger.
+func (m *mixpanel) logFields(msg string, fields map[string]interface{}) {
+	if l, ok := m.logger.(StructuredLogger); ok {
+		l.Log(msg, fields)
+		return
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, fields[key]))
+	}
+
+	m.infof("%s %s", msg, strings.Join(pairs, " "))
+}
+
+// debugf logs a debug diagnostic, for leveled loggers only.
+func (m *mixpanel) debugf(format string, v ...interface{}) {
+	if l, ok := m.logger.(LeveledLogger); ok {
+		l.Debugf(format, v...)
+	}
+}
+
+// infof logs an info diagnostic.
+func (m *mixpanel) infof(format string, v ...interface{}) {
+	if l, ok := m.logger.(LeveledLogger); ok {
+		l.Infof(format, v...)
+		return
+	}
+	m.logger.Printf(format, v...)
+}
+
+// errorf logs an error diagnostic.
+func (m *mixpanel) errorf(format string, v ...interface{}) {
+	if l, ok := m.logger.(LeveledLogger); ok {
+		l.Errorf(format, v...)
+		return
+	}
+	m.logger.Printf(format, v...)
+}
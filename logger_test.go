@@ -0,0 +1,203 @@
+package mixpanel
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testLogger struct {
+	lines []string
+}
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestLogWarnings(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		w.Write([]byte("{\"status\":1,\"error\":null,\"warnings\":[\"$browser is deprecated\"]}"))
+	}))
+	defer ts.Close()
+
+	logger := &testLogger{}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithLogger(logger))
+
+	err := client.Track("13793", "Signed Up", &Event{
+		Properties: map[string]interface{}{
+			"$browser": "Chrome",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+
+	want := []string{"mixpanel: track warning: $browser is deprecated"}
+	if !reflect.DeepEqual(logger.lines, want) {
+		t.Errorf("logged %+v, want %+v", logger.lines, want)
+	}
+}
+
+type testStructuredLogger struct {
+	testLogger
+	msgs   []string
+	fields []map[string]interface{}
+}
+
+func (l *testStructuredLogger) Log(msg string, fields map[string]interface{}) {
+	l.msgs = append(l.msgs, msg)
+	l.fields = append(l.fields, fields)
+}
+
+func TestLogImportRouting(t *testing.T) {
+	setup()
+	defer teardown()
+
+	logger := &testStructuredLogger{}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithLogger(logger))
+
+	recent := time.Now()
+	client.Track("13793", "Signed Up", &Event{Timestamp: &recent})
+
+	at := time.Date(2016, 3, 3, 15, 17, 53, 0, time.UTC)
+	client.Track("13793", "Signed Up", &Event{Timestamp: &at})
+
+	want := []map[string]interface{}{{
+		"eventName": "Signed Up",
+		"timestamp": int64(1457018273),
+		"threshold": "120h0m0s",
+		"endpoint":  "import",
+		"forced":    false,
+	}}
+	if !reflect.DeepEqual(logger.fields, want) {
+		t.Errorf("logged fields %+v, want %+v", logger.fields, want)
+	}
+	if !reflect.DeepEqual(logger.msgs, []string{"mixpanel: routing event"}) {
+		t.Errorf("logged messages %+v", logger.msgs)
+	}
+	if len(logger.lines) != 0 {
+		t.Errorf("logged lines %+v, want none", logger.lines)
+	}
+}
+
+func TestLogImportRoutingPrintf(t *testing.T) {
+	setup()
+	defer teardown()
+
+	logger := &testLogger{}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithLogger(logger))
+
+	at := time.Date(2016, 3, 3, 15, 17, 53, 0, time.UTC)
+	client.Track("13793", "Signed Up", &Event{Timestamp: &at}, ForceEndpoint(EndpointTrack))
+
+	want := []string{"mixpanel: routing event endpoint=track eventName=Signed Up forced=true threshold=120h0m0s timestamp=1457018273"}
+	if !reflect.DeepEqual(logger.lines, want) {
+		t.Errorf("logged %+v, want %+v", logger.lines, want)
+	}
+}
+
+func TestDefaultLoggerSilent(t *testing.T) {
+	setup()
+	defer teardown()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe returned %v", err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	log.SetOutput(w)
+	defer func() {
+		os.Stderr = stderr
+		log.SetOutput(stderr)
+	}()
+
+	at := time.Now().Add(-30 * 24 * time.Hour)
+	client.Track("13793", "Signed Up", &Event{Timestamp: &at})
+
+	w.Close()
+	out, _ := io.ReadAll(r)
+	if len(out) > 0 {
+		t.Errorf("default logger wrote %q", out)
+	}
+}
+
+func TestStdLoggerStaleTimestamp(t *testing.T) {
+	setup()
+	defer teardown()
+
+	var buf bytes.Buffer
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithLogger(log.New(&buf, "", 0)))
+
+	at := time.Now().Add(-30 * 24 * time.Hour)
+	client.Track("13793", "Signed Up", &Event{Timestamp: &at})
+
+	if got := buf.String(); !strings.HasPrefix(got, "mixpanel: routing event endpoint=import") {
+		t.Errorf("logged %q, want the stale timestamp routing message", got)
+	}
+}
+
+type testLeveledLogger struct {
+	testLogger
+	debug, info, errors []string
+}
+
+func (l *testLeveledLogger) Debugf(format string, v ...interface{}) {
+	l.debug = append(l.debug, fmt.Sprintf(format, v...))
+}
+
+func (l *testLeveledLogger) Infof(format string, v ...interface{}) {
+	l.info = append(l.info, fmt.Sprintf(format, v...))
+}
+
+func (l *testLeveledLogger) Errorf(format string, v ...interface{}) {
+	l.errors = append(l.errors, fmt.Sprintf(format, v...))
+}
+
+func TestLeveledLogger(t *testing.T) {
+	var attempts int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	logger := &testLeveledLogger{}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithLogger(logger),
+		WithRetries(1, Backoff{Base: time.Millisecond}))
+
+	client.Track("13793", "Signed Up", &Event{
+		IP:         "0",
+		Properties: map[string]interface{}{strings.Repeat("x", MaxPropertyNameLength+1): 1},
+	})
+
+	wantDebug := []string{
+		"mixpanel: POST " + ts.URL + "/track",
+		"mixpanel: POST " + ts.URL + "/track",
+	}
+	if !reflect.DeepEqual(logger.debug, wantDebug) {
+		t.Errorf("logged debug %+v, want %+v", logger.debug, wantDebug)
+	}
+	if len(logger.info) != 1 || !strings.HasPrefix(logger.info[0], "mixpanel: retrying track request (retry 1 of 1)") {
+		t.Errorf("logged info %+v, want the retry", logger.info)
+	}
+	if len(logger.errors) != 1 || !strings.Contains(logger.errors[0], "is longer than 255 characters") {
+		t.Errorf("logged errors %+v, want the long property name", logger.errors)
+	}
+	if len(logger.lines) != 0 {
+		t.Errorf("logged lines %+v, want none", logger.lines)
+	}
+}
@@ -0,0 +1,44 @@
+package mixpanel
+
+import "net/http"
+
+// endpointMethods is the HTTP method used for each endpoint the client calls.
+// Ingestion endpoints take POST; the query and export APIs are read with GET
+// and their parameters go in the URL, except for the profile query, whose
+// filters can be long, and the endpoints that create something, which are
+// sent as a POST form body instead.
+//
+//	track, import, engage   POST
+//	groups                  POST
+//	/2.0/engage             POST
+//	/2.0/export             GET
+//	/2.0/flows              GET
+//	/2.0/jql                POST
+//	pipeline creation       POST
+//	pipeline status         GET
+//
+// Every endpoint is listed, so that a new one has to be added here.
+var endpointMethods = map[string]string{
+	"track":       http.MethodPost,
+	"import":      http.MethodPost,
+	"engage":      http.MethodPost,
+	"groups":      http.MethodPost,
+	"/2.0/engage": http.MethodPost,
+	"/2.0/export": http.MethodGet,
+	"/2.0/flows":  http.MethodGet,
+	"/2.0/jql":    http.MethodPost,
+
+	"/2.0/nessie/pipeline/create": http.MethodPost,
+	"/2.0/nessie/pipeline/status": http.MethodGet,
+}
+
+// endpointMethod returns the HTTP method for endpoint, which is either an
+// ingestion endpoint name such as "track" or a query API path. An endpoint
+// missing from endpointMethods is sent with POST, which keeps its parameters,
+// and the API secret of the query API, out of the URL.
+func endpointMethod(endpoint string) string {
+	if method, ok := endpointMethods[endpoint]; ok {
+		return method
+	}
+	return http.MethodPost
+}
@@ -0,0 +1,100 @@
+package mixpanel
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEndpointMethods(t *testing.T) {
+	methods := map[string]string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods[r.URL.Path] = r.Method
+		w.Write([]byte(`{"status":1,"error":null,"results":[]}`))
+	}))
+	defer ts.Close()
+
+	client := NewFromClient(http.DefaultClient, "e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL,
+		WithQueryURL(ts.URL), WithExportURL(ts.URL)).(*mixpanel)
+
+	client.Track("13793", "Signed Up", &Event{})
+	client.Update("13793", &Update{Operation: "$set", Properties: map[string]interface{}{"Plan": "pro"}})
+	client.Merge([]string{"13793", "13794"})
+	client.profileProperties("13793")
+	client.Export(ExportParams{})
+	client.query("/2.0/flows", url.Values{"event": {"Signed Up"}}, &struct{}{})
+	client.query("/2.0/unknown", url.Values{"event": {"Signed Up"}}, &struct{}{})
+
+	want := map[string]string{
+		"/track":       http.MethodPost,
+		"/engage":      http.MethodPost,
+		"/import":      http.MethodPost,
+		"/2.0/engage":  http.MethodPost,
+		"/2.0/export":  http.MethodGet,
+		"/2.0/flows":   http.MethodGet,
+		"/2.0/unknown": http.MethodPost,
+	}
+	if !reflect.DeepEqual(methods, want) {
+		t.Errorf("methods returned %+v, want %+v", methods, want)
+	}
+}
+
+// TestEndpointMethodsComplete checks that every endpoint the package sends a
+// request to, as a literal argument of the functions choosing the method, is
+// listed in endpointMethods.
+func TestEndpointMethodsComplete(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatalf("ParseDir returned %+v", err)
+	}
+
+	senders := map[string]bool{"endpointMethod": true, "query": true, "queryAt": true, "send": true, "sendWith": true}
+	endpoints := map[string]string{EndpointTrack: "ForceEndpoint", EndpointImport: "ForceEndpoint"}
+	for _, file := range pkgs["mixpanel"].Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+
+			var name string
+			switch fun := call.Fun.(type) {
+			case *ast.Ident:
+				name = fun.Name
+			case *ast.SelectorExpr:
+				name = fun.Sel.Name
+			}
+			if !senders[name] {
+				return true
+			}
+
+			for _, arg := range call.Args {
+				if lit, ok := arg.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					endpoint, _ := strconv.Unquote(lit.Value)
+					endpoints[endpoint] = fset.Position(lit.Pos()).String()
+				}
+			}
+			return true
+		})
+	}
+
+	if len(endpoints) < len(endpointMethods) {
+		t.Errorf("found endpoints %+v, want at least the %d of endpointMethods", endpoints, len(endpointMethods))
+	}
+	for endpoint, pos := range endpoints {
+		if _, ok := endpointMethods[endpoint]; !ok {
+			t.Errorf("endpoint %q used at %s is missing from endpointMethods", endpoint, pos)
+		}
+	}
+}
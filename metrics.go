@@ -0,0 +1,67 @@
+package mixpanel
+
+import (
+	"expvar"
+	"time"
+)
+
+// A MetricsCollector is called by a client given WithMetricsCollector after
+// every request it sends for an ingestion call, retries included, for
+// example to feed Prometheus counters and histograms. endpoint is "track",
+// "import", "engage" or "groups", batchSize is the number of events or
+// updates in the request, duration is how long the request took and err is
+// its error, if any. OnSend is called from the goroutines making the calls,
+// so it must be safe for concurrent use.
+type MetricsCollector interface {
+	OnSend(endpoint string, batchSize int, duration time.Duration, err error)
+}
+
+// WithMetricsCollector calls collector after every ingestion request. See
+// ExpvarMetrics for a collector publishing with expvar.
+func WithMetricsCollector(collector MetricsCollector) Option {
+	return func(m *mixpanel) {
+		m.metrics = collector
+	}
+}
+
+// collectSend reports a request that started at start to the metrics
+// collector, if any.
+func (m *mixpanel) collectSend(endpoint string, batchSize int, start time.Time, err error) {
+	if m.metrics != nil {
+		m.metrics.OnSend(endpoint, batchSize, time.Since(start), err)
+	}
+}
+
+// ExpvarMetrics is a MetricsCollector publishing its counters with expvar,
+// and thus on /debug/vars when the expvar handler is served. For each
+// endpoint, it counts:
+//
+//   - "<endpoint>.requests": the requests sent;
+//   - "<endpoint>.failures": the requests that failed;
+//   - "<endpoint>.events": the events or updates sent;
+//   - "<endpoint>.failed_events": the events or updates of failed requests;
+//   - "<endpoint>.seconds": the total duration of the requests.
+type ExpvarMetrics struct {
+	vars *expvar.Map
+}
+
+// NewExpvarMetrics returns an ExpvarMetrics publishing its counters as the
+// expvar map name. Like expvar.NewMap, it panics if name is already used.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	return &ExpvarMetrics{vars: expvar.NewMap(name)}
+}
+
+func (e *ExpvarMetrics) OnSend(endpoint string, batchSize int, duration time.Duration, err error) {
+	e.vars.Add(endpoint+".requests", 1)
+	e.vars.Add(endpoint+".events", int64(batchSize))
+	if err != nil {
+		e.vars.Add(endpoint+".failures", 1)
+		e.vars.Add(endpoint+".failed_events", int64(batchSize))
+	}
+	e.vars.AddFloat(endpoint+".seconds", duration.Seconds())
+}
+
+// Map returns the expvar map holding the counters.
+func (e *ExpvarMetrics) Map() *expvar.Map {
+	return e.vars
+}
@@ -0,0 +1,72 @@
+package mixpanel
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingCollector struct {
+	mu    sync.Mutex
+	sends []string
+}
+
+func (c *recordingCollector) OnSend(endpoint string, batchSize int, duration time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	outcome := "ok"
+	if err != nil {
+		outcome = "failed"
+	}
+	c.sends = append(c.sends, endpoint+" "+outcome)
+}
+
+func TestMetricsCollector(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	collector := &recordingCollector{}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithMetricsCollector(collector), WithRetries(1, Backoff{Base: time.Millisecond}))
+
+	if err := client.Track("13793", "Signed Up", &Event{}); err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+
+	want := []string{"track failed", "track ok"}
+	if len(collector.sends) != 2 || collector.sends[0] != want[0] || collector.sends[1] != want[1] {
+		t.Errorf("sends returned %+v, want %+v", collector.sends, want)
+	}
+}
+
+func TestExpvarMetrics(t *testing.T) {
+	metrics := NewExpvarMetrics("mixpanel_test_metrics")
+
+	metrics.OnSend("track", 50, time.Second, nil)
+	metrics.OnSend("track", 20, time.Second/2, errors.New("unavailable"))
+
+	want := map[string]string{
+		"track.requests":      "2",
+		"track.failures":      "1",
+		"track.events":        "70",
+		"track.failed_events": "20",
+		"track.seconds":       "1.5",
+	}
+	for key, value := range want {
+		if got := metrics.Map().Get(key); got == nil || got.String() != value {
+			t.Errorf("%s returned %v, want %v", key, got, value)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package mixpanel
+
+import "context"
+
+// A SendFunc sends the JSON-encoded payload of an ingestion call to endpoint,
+// "track", "import", "engage" or "groups", and returns the response of
+// Mixpanel. The response is nil if there was none, for example when the
+// request failed or the client sends to a Sink.
+type SendFunc func(ctx context.Context, endpoint string, payload []byte) (*Response, error)
+
+// A Middleware wraps the sending of the ingestion requests, for example to
+// add tracing spans or metrics, or to scrub payloads before they leave the
+// process:
+//
+//	func scrub(next mixpanel.SendFunc) mixpanel.SendFunc {
+//		return func(ctx context.Context, endpoint string, payload []byte) (*mixpanel.Response, error) {
+//			return next(ctx, endpoint, removeEmails(payload))
+//		}
+//	}
+//
+// A middleware runs once for each attempt, retries included, after the rate
+// limits of the client.
+type Middleware func(next SendFunc) SendFunc
+
+// WithMiddleware wraps the ingestion requests, including the chunks of
+// ImportBatch, with mw. The first middleware is the outermost one: it sees the
+// payload first and the response last. Calls that are not plain ingestion
+// calls, such as ImportNDJSON, the query methods and Export, are not wrapped.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(m *mixpanel) {
+		m.middleware = append(m.middleware, mw...)
+	}
+}
+
+// chain wraps send with the middleware of the client.
+func (m *mixpanel) chain(send SendFunc) SendFunc {
+	for i := len(m.middleware) - 1; i >= 0; i-- {
+		send = m.middleware[i](send)
+	}
+	return send
+}
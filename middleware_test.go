@@ -0,0 +1,82 @@
+package mixpanel
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next SendFunc) SendFunc {
+			return func(ctx context.Context, endpoint string, payload []byte) (*Response, error) {
+				calls = append(calls, name+" "+endpoint)
+				resp, err := next(ctx, endpoint, payload)
+				calls = append(calls, name+" "+resp.Status)
+				return resp, err
+			}
+		}
+	}
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithMiddleware(record("outer"), record("inner")))
+	if err := client.Track("13793", "Signed Up", &Event{}); err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+
+	want := []string{"outer track", "inner track", "inner 1", "outer 1"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls returned %+v, want %+v", calls, want)
+	}
+}
+
+func TestMiddlewareScrub(t *testing.T) {
+	sink := &memorySink{}
+	scrub := func(next SendFunc) SendFunc {
+		return func(ctx context.Context, endpoint string, payload []byte) (*Response, error) {
+			return next(ctx, endpoint, bytes.ReplaceAll(payload, []byte("john@example.com"), []byte("[email]")))
+		}
+	}
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", "", WithSink(sink), WithMiddleware(scrub))
+	if err := client.Update("13793", &Update{Operation: "$set", Properties: map[string]interface{}{"$email": "john@example.com"}}); err != nil {
+		t.Fatalf("Update returned %v", err)
+	}
+
+	want := []string{"engage {\"$distinct_id\":\"13793\",\"$set\":{\"$email\":\"[email]\"},\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}"}
+	if !reflect.DeepEqual(sink.payloads, want) {
+		t.Errorf("payloads returned %+v, want %+v", sink.payloads, want)
+	}
+}
+
+func TestMiddlewareImportBatch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":200,"num_records_imported":1,"status":"OK"}`))
+	}))
+	defer ts.Close()
+
+	var endpoints []string
+	record := func(next SendFunc) SendFunc {
+		return func(ctx context.Context, endpoint string, payload []byte) (*Response, error) {
+			endpoints = append(endpoints, endpoint)
+			return next(ctx, endpoint, payload)
+		}
+	}
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL, WithMiddleware(record))
+	if err := client.ImportBatch([]BatchEvent{{DistinctId: "13793", EventName: "Signed Up", Event: Event{}}}); err != nil {
+		t.Fatalf("ImportBatch returned %v", err)
+	}
+
+	if want := []string{"import"}; !reflect.DeepEqual(endpoints, want) {
+		t.Errorf("endpoints returned %+v, want %+v", endpoints, want)
+	}
+}
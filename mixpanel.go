@@ -1,38 +1,274 @@
 package mixpanel
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 var IgnoreTime *time.Time = &time.Time{}
 
+// Events older than importThreshold are sent to /import instead of /track,
+// unless WithImportThreshold says otherwise.
+const importThreshold = 5 * 24 * time.Hour
+
 type MixpanelError struct {
 	URL        string `json:"-"`
 	Message    string `json:"error"`
 	HttpStatus int    `json:"-"`
 	Code       int    `json:"status"`
+
+	// Err is the transport error of a request that got no response, such
+	// as a cancelled context or a network failure.
+	Err error `json:"-"`
+
+	// RetryAfter is the delay asked for by the Retry-After header of the
+	// response, if any.
+	RetryAfter time.Duration `json:"-"`
+
+	// RawBody is the start of the response body, up to maxRawBody bytes, when
+	// the response was an HTTP error or could not be parsed, such as an HTML
+	// error page from a proxy.
+	RawBody string `json:"-"`
+
+	// Header holds the headers of the response, such as the rate limit
+	// headers, or nil if there was no response.
+	Header http.Header `json:"-"`
+
+	// Payload is the JSON document sent by an ingestion call, before base64
+	// encoding, so that the rejected data can be logged. It includes the
+	// project token; the copies kept by WithErrorRing have it redacted.
+	Payload []byte `json:"-"`
+
+	// FailedRecords lists the events rejected by /import in strict mode,
+	// see WithStrictImport.
+	FailedRecords []ImportRecordError `json:"-"`
+}
+
+// Sentinel errors matched by a *MixpanelError with errors.Is.
+var (
+	// ErrInvalidToken matches a request rejected for its project token or
+	// credentials.
+	ErrInvalidToken = errors.New("mixpanel: invalid token")
+
+	// ErrRateLimited matches a request rejected with HTTP 429.
+	ErrRateLimited = errors.New("mixpanel: rate limited")
+
+	// ErrPayloadTooLarge matches a request rejected with HTTP 413.
+	ErrPayloadTooLarge = errors.New("mixpanel: payload too large")
+)
+
+// Is reports whether err matches target, one of ErrInvalidToken,
+// ErrRateLimited and ErrPayloadTooLarge.
+func (err *MixpanelError) Is(target error) bool {
+	switch target {
+	case ErrInvalidToken:
+		return err.HttpStatus == http.StatusUnauthorized ||
+			strings.Contains(strings.ToLower(err.Message), "token")
+	case ErrRateLimited:
+		return err.HttpStatus == http.StatusTooManyRequests
+	case ErrPayloadTooLarge:
+		return err.HttpStatus == http.StatusRequestEntityTooLarge
+	}
+	return false
 }
 
+// maxRawBody is the most of a response body kept in MixpanelError.RawBody.
+const maxRawBody = 512
+
 func (err *MixpanelError) Error() string {
+	if err.RawBody != "" {
+		return fmt.Sprintf("MixpanelClient status=%v code=%v message=%v body=%q", err.HttpStatus, err.Code, err.Message, err.RawBody)
+	}
 	return fmt.Sprintf("MixpanelClient status=%v code=%v message=%v", err.HttpStatus, err.Code, err.Message)
 }
 
+// rawBody returns body truncated to maxRawBody bytes, for MixpanelError.
+func rawBody(body []byte) string {
+	if len(body) > maxRawBody {
+		body = body[:maxRawBody]
+	}
+	return string(body)
+}
+
+// Unwrap returns the transport error, so that errors.Is reports a request
+// aborted by its context as context.Canceled or context.DeadlineExceeded.
+func (err *MixpanelError) Unwrap() error {
+	return err.Err
+}
+
+// IsRetryable reports whether err is a transient failure worth retrying: a
+// network error, a rate limit (HTTP 429) or a server error (HTTP 5xx). Other
+// errors, such as a request Mixpanel rejected as invalid, are permanent.
+func IsRetryable(err error) bool {
+	return isRetryableWith(err, func(status int) bool {
+		return status == http.StatusTooManyRequests || status >= 500
+	})
+}
+
+// isRetryableWith reports whether err is a transient failure, as IsRetryable
+// does, but with retryStatus telling which HTTP statuses are transient.
+func isRetryableWith(err error, retryStatus func(status int) bool) bool {
+	var mpErr *MixpanelError
+	if !errors.As(err, &mpErr) {
+		return false
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		// Retrying cannot help once the caller gave up.
+		return false
+	case mpErr.HttpStatus == 0:
+		// The request never got a response.
+		return true
+	}
+
+	return retryStatus(mpErr.HttpStatus)
+}
+
+// ErrNoProperties is returned by Update for an update without an operation or
+// properties, and matches the *ValidationError returned for an event without
+// properties when WithRequireProperties is used.
+var ErrNoProperties = errors.New("mixpanel: no properties given")
+
+// ErrSelfAlias is returned by Alias when an id is aliased to itself.
+var ErrSelfAlias = errors.New("mixpanel: cannot alias a distinct id to itself")
+
 // The Mixapanel struct store the mixpanel endpoint and the project token
+//
+// Every implementation in this package, the clients returned by New and its
+// variants, Mock, Async and Buffered, is safe for concurrent use by multiple
+// goroutines. The events, updates and property maps passed to a call are only
+// read, so they may be shared between concurrent calls, but must not be
+// modified until the call returns or, with Async, until the call is sent.
 type Mixpanel interface {
 	// Create a mixpanel event
-	Track(distinctId, eventName string, e *Event) error
+	Track(distinctId, eventName string, e *Event, opts ...CallOption) error
+
+	// Create a mixpanel event, with properties extracted from ctx
+	TrackCtx(ctx context.Context, distinctId, eventName string, e *Event, opts ...CallOption) error
 
 	// Set properties for a mixpanel user.
-	Update(distinctId string, u *Update) error
+	Update(distinctId string, u *Update, opts ...CallOption) error
+
+	// Set properties for a mixpanel user, sending the request with ctx.
+	UpdateCtx(ctx context.Context, distinctId string, u *Update, opts ...CallOption) error
+
+	Alias(distinctId, newId string, opts ...CallOption) error
+
+	AliasCtx(ctx context.Context, distinctId, newId string, opts ...CallOption) error
+
+	Import(distinctId, eventName string, e *Event, opts ...CallOption) error
+	ImportCtx(ctx context.Context, distinctId, eventName string, e *Event, opts ...CallOption) error
+	Merge(distinctIds []string, opts ...CallOption) error
+	MergeCtx(ctx context.Context, distinctIds []string, opts ...CallOption) error
+	MergeIdentity(identifiedId, anonId string, opts ...CallOption) error
+	MergeIdentityCtx(ctx context.Context, identifiedId, anonId string, opts ...CallOption) error
+
+	// Link an anonymous id to an identified user with the $identify event.
+	Identify(anonId, identifiedId string, opts ...CallOption) error
+	IdentifyCtx(ctx context.Context, anonId, identifiedId string, opts ...CallOption) error
+
+	// Deprecated: Use Identify, which takes the ids the other way around.
+	CreateIdentity(identifiedId, anonId string) error
+
+	// Import events as a gzip-compressed NDJSON request body.
+	ImportNDJSON(events []BatchEvent, opts ...CallOption) error
+	ImportNDJSONCtx(ctx context.Context, events []BatchEvent, opts ...CallOption) error
+	ImportBatch(events []BatchEvent, opts ...CallOption) error
+	ImportBatchCtx(ctx context.Context, events []BatchEvent, opts ...CallOption) error
+	TrackBatch(events []BatchEvent, opts ...CallOption) error
+	TrackBatchCtx(ctx context.Context, events []BatchEvent, opts ...CallOption) error
+
+	// Bring the cohort list property of a user in line with desired.
+	ReconcileCohorts(distinctId string, desired []string) error
+	ReconcileCohortsCtx(ctx context.Context, distinctId string, desired []string) error
 
-	Alias(distinctId, newId string) error
+	// Set the $created property of a user unless it is already set.
+	EnsureCreated(distinctId string, t time.Time) error
+	EnsureCreatedCtx(ctx context.Context, distinctId string, t time.Time) error
+	Increment(distinctId, property string, by float64) error
+	IncrementCtx(ctx context.Context, distinctId, property string, by float64) error
+	PeopleDelete(distinctId string, opts ...CallOption) error
+	PeopleDeleteCtx(ctx context.Context, distinctId string, opts ...CallOption) error
+	TrackCharge(distinctId string, amount float64, properties map[string]interface{}) error
+	TrackChargeCtx(ctx context.Context, distinctId string, amount float64, properties map[string]interface{}) error
+	ClearCharges(distinctId string) error
+	ClearChargesCtx(ctx context.Context, distinctId string) error
+	SetOnce(distinctId string, props map[string]interface{}) error
+	SetOnceCtx(ctx context.Context, distinctId string, props map[string]interface{}) error
+	PeopleSet(distinctId string, props map[string]interface{}, opts ...CallOption) error
+	PeopleSetCtx(ctx context.Context, distinctId string, props map[string]interface{}, opts ...CallOption) error
+	PeopleIncrement(distinctId string, by map[string]float64, opts ...CallOption) error
+	PeopleIncrementCtx(ctx context.Context, distinctId string, by map[string]float64, opts ...CallOption) error
+	PeopleAppend(distinctId string, props map[string]interface{}, opts ...CallOption) error
+	PeopleAppendCtx(ctx context.Context, distinctId string, props map[string]interface{}, opts ...CallOption) error
+	PeopleUnion(distinctId string, lists map[string][]interface{}, opts ...CallOption) error
+	PeopleUnionCtx(ctx context.Context, distinctId string, lists map[string][]interface{}, opts ...CallOption) error
+	PeopleRemove(distinctId string, props map[string]interface{}, opts ...CallOption) error
+	PeopleRemoveCtx(ctx context.Context, distinctId string, props map[string]interface{}, opts ...CallOption) error
+	Unset(distinctId string, keys []string) error
+	UnsetCtx(ctx context.Context, distinctId string, keys []string) error
+	GroupUpdate(groupKey, groupID string, g *GroupUpdateRequest, opts ...CallOption) error
+	GroupUpdateCtx(ctx context.Context, groupKey, groupID string, g *GroupUpdateRequest, opts ...CallOption) error
+	GroupDelete(groupKey, groupID string, opts ...CallOption) error
+	GroupDeleteCtx(ctx context.Context, groupKey, groupID string, opts ...CallOption) error
+	Bump(distinctId, property string) error
+	BumpCtx(ctx context.Context, distinctId, property string) error
 
-	Merge(distinctIds []string) error
+	// Send many profile updates in as few requests as possible.
+	UpdateBatch(updates []BatchUpdate, opts ...CallOption) error
+	UpdateBatchCtx(ctx context.Context, updates []BatchUpdate, opts ...CallOption) error
+
+	// Download raw events from the export API.
+	Export(p ExportParams) ([]ExportedEvent, error)
+	ExportCtx(ctx context.Context, p ExportParams) ([]ExportedEvent, error)
+	ExportEach(ctx context.Context, p ExportParams, fn func(ExportedEvent) error) error
+
+	// Return the most recent failed requests recorded by WithErrorRing.
+	RecentErrors() []FailedRequest
+
+	// Return the state of the circuit breaker of WithCircuitBreaker.
+	Health() Health
+
+	// Copy exported events into the project of another client.
+	CopyEvents(ctx context.Context, from ExportParams, dest Mixpanel) error
+	CreatePipeline(p PipelineParams) ([]string, error)
+	CreatePipelineCtx(ctx context.Context, p PipelineParams) ([]string, error)
+	ConnectorStatus(connectorId string) (*ConnectorStatus, error)
+	ConnectorStatusCtx(ctx context.Context, connectorId string) (*ConnectorStatus, error)
+
+	// Keep the Lexicon schemas and the annotations of the project in sync.
+	UploadSchemas(ctx context.Context, entries []SchemaEntry) error
+	ListSchemas(ctx context.Context, entityType string) ([]SchemaEntry, error)
+	CreateAnnotation(ctx context.Context, a Annotation) (*Annotation, error)
+	ListAnnotations(ctx context.Context, from, to time.Time) ([]Annotation, error)
+
+	ValidateRegion(ctx context.Context) error
+	Flows(event string, from, to time.Time, opts FlowsOpts) (*FlowsResult, error)
+	FlowsCtx(ctx context.Context, event string, from, to time.Time, opts FlowsOpts) (*FlowsResult, error)
+	StreamJQL(ctx context.Context, script string, params interface{}, w io.Writer) error
+	UnsetPropertyWhere(ctx context.Context, where string, names []string) (int, error)
+
+	// Add properties to every event sent afterwards, or remove one.
+	RegisterSuperProperties(props map[string]interface{})
+	UnregisterSuperProperty(key string)
+
+	// Read user profiles back from the profile query API.
+	QueryProfiles(ctx context.Context, q ProfileQuery) (*ProfilePage, error)
+	EachProfile(ctx context.Context, q ProfileQuery, fn func(Profile) error) error
+	PipelineStatus(name string) ([]PipelineRun, error)
+	PipelineStatusCtx(ctx context.Context, name string) ([]PipelineRun, error)
 }
 
 // The Mixapanel struct store the mixpanel endpoint and the project token
@@ -42,19 +278,87 @@ type mixpanel struct {
 	ApiKey    string
 	ApiSecret string
 	ApiURL    string
+	QueryURL  string
+	ExportURL string
+
+	logger         Logger
+	coercions      []Coercion
+	granularity    map[string]TimeGranularity
+	cohortProperty string
+	defaultProps   map[string]interface{}
+	superMu        sync.RWMutex
+	superProps     map[string]interface{}
+	defaultsWin    bool
+	cardinality    *cardinalityDetector
+
+	strict               bool
+	allowlists           map[string]map[string]bool
+	rejectUnlistedEvents bool
+	maxNameLength        int
+	maxProperties        int
+	requireProperties    bool
+	validator            EventValidator
+	validationFailure    ValidationFailureMode
+
+	contextExtractor func(context.Context) map[string]interface{}
+	allowSelfAlias   bool
+	errorRing        *errorRing
+	rawJSONBody      bool
+	queryData        bool
+	compress         bool
+	compressAbove    int
+	overflowBytes    int
+	serviceAccount   *serviceAccount
+	successStatuses  []string
+	sink             Sink
+	autoInsertId     bool
+	maxRetries       int
+	importAfter      time.Duration
+	retryStatuses    []int
+	retryStatusesSet bool
+	rateLimiter      *tokenBucket
+	requestSlots     chan struct{}
+	retryBackoff     Backoff
+	timeouts         map[string]time.Duration
+	defaultTimeout   time.Duration
+	middleware       []Middleware
+	tracer           Tracer
+	meter            Meter
+	breaker          *circuitBreaker
+	metrics          MetricsCollector
+	strictImport     bool
+	scrubber         Scrubber
+	enabled          func() bool
+	clock            Clock
 }
 
 // A mixpanel event
 type Event struct {
 	// IP-address of the user. Leave empty to use autodetect, or set to "0" to
-	// not specify an ip-address.
+	// not specify an ip-address. When set, it wins over "ip" and "$ip"
+	// properties, which are dropped, or rejected in strict mode.
 	IP string
 
-	// Timestamp. Set to nil to use the current time.
+	// Timestamp. Set to nil to use the current time. It is sent as Unix
+	// time, in seconds to /track and in milliseconds to /import, so its
+	// location does not matter: times in any time zone are sent as the
+	// same instant in UTC.
 	Timestamp *time.Time
 
-	// Custom properties. At least one must be specified.
+	// Geolocate sets whether Mixpanel geolocates the user, from IP if set or
+	// else from the address of the request. nil geolocates only when IP is
+	// empty.
+	Geolocate *bool
+
+	// Custom properties. May be nil or empty, in which case the event only
+	// carries the reserved properties, unless WithRequireProperties is used.
 	Properties map[string]interface{}
+
+	// InsertId is sent as the $insert_id of the event, which Mixpanel uses
+	// to drop duplicates of it, such as those sent by a retry. It wins over
+	// a $insert_id property and over the id of WithAutoInsertID. Empty means
+	// none.
+	InsertId string
 }
 
 // An update of a user in mixpanel
@@ -64,18 +368,66 @@ type Update struct {
 	IP string
 
 	// Timestamp. Set to nil to use the current time, or IgnoreTime to not use a
-	// timestamp.
+	// timestamp. It is sent as $time in Unix seconds, whatever its location.
 	Timestamp *time.Time
 
-	// Update operation such as "$set", "$update" etc.
+	// Geolocate sets whether Mixpanel geolocates the user, from IP if set or
+	// else from the address of the request. nil geolocates only when IP is
+	// empty.
+	Geolocate *bool
+
+	// Update operation such as OpSet or OpAdd.
 	Operation string
 
-	// Custom properties. At least one must be specified.
+	// Custom properties. Required by every operation but "$delete" and
+	// OpUnset: an update without any fails with ErrNoProperties.
 	Properties map[string]interface{}
+
+	// Unset is the list of property names removed by OpUnset, which takes
+	// them instead of Properties.
+	Unset []string
 }
 
-// Track create a events to current distinct id
-func (m *mixpanel) Alias(distinctId, newId string) error {
+// The profile update operations.
+const (
+	OpSet     = "$set"
+	OpSetOnce = "$set_once"
+	OpAdd     = "$add"
+	OpAppend  = "$append"
+	OpUnion   = "$union"
+	OpRemove  = "$remove"
+	OpUnset   = "$unset"
+)
+
+// isEmpty reports whether u has no operation or nothing for its operation to
+// apply.
+func (u *Update) isEmpty() bool {
+	switch u.Operation {
+	case "":
+		return true
+	case "$delete":
+		return false
+	case OpUnset:
+		return len(u.Unset) == 0
+	}
+	return len(u.Properties) == 0
+}
+
+// Alias newId to distinctId. Aliasing an id to itself is almost always a bug,
+// so it fails with ErrSelfAlias unless WithSelfAlias is used.
+//
+// Alias is deprecated by Mixpanel's ID merge; projects with it enabled should
+// use Merge or MergeIdentity instead.
+func (m *mixpanel) Alias(distinctId, newId string, opts ...CallOption) error {
+	return m.AliasCtx(context.Background(), distinctId, newId, opts...)
+}
+
+// AliasCtx is like Alias, but sends the request with ctx.
+func (m *mixpanel) AliasCtx(ctx context.Context, distinctId, newId string, opts ...CallOption) error {
+	if distinctId != "" && distinctId == newId && !m.allowSelfAlias {
+		return ErrSelfAlias
+	}
+
 	props := map[string]interface{}{
 		"token":       m.Token,
 		"distinct_id": distinctId,
@@ -87,11 +439,35 @@ func (m *mixpanel) Alias(distinctId, newId string) error {
 		"properties": props,
 	}
 
-	return m.send("track", params, false)
+	return m.send(ctx, "track", params, false, newCallOptions(opts))
 }
 
 // Merge distinct_ids together. Must have merge_ids enabled on Mixpanel organization
-func (m *mixpanel) Merge(distinctIds []string) error {
+//
+// The $merge API has no conflict resolution options: when the merged profiles
+// set the same property, Mixpanel keeps one of the values and does not
+// document which. Pass PreferProfile to make the outcome deterministic.
+func (m *mixpanel) Merge(distinctIds []string, opts ...CallOption) error {
+	return m.MergeCtx(context.Background(), distinctIds, opts...)
+}
+
+// MergeCtx is like Merge, but sends the requests with ctx.
+func (m *mixpanel) MergeCtx(ctx context.Context, distinctIds []string, opts ...CallOption) error {
+	if m.disabled() {
+		return nil
+	}
+
+	call := newCallOptions(opts)
+
+	var preferred map[string]interface{}
+	if call.preferProfile != "" {
+		var err error
+		preferred, err = m.profilePropertiesCtx(ctx, call.preferProfile)
+		if err != nil {
+			return err
+		}
+	}
+
 	props := map[string]interface{}{
 		"token":         m.Token,
 		"$distinct_ids": distinctIds,
@@ -102,14 +478,168 @@ func (m *mixpanel) Merge(distinctIds []string) error {
 		"properties": props,
 	}
 
-	return m.send("import", params, false)
+	if err := m.send(ctx, "import", params, false, call); err != nil {
+		return err
+	}
+
+	if len(preferred) == 0 {
+		return nil
+	}
+
+	return m.UpdateCtx(ctx, call.preferProfile, &Update{
+		IP:         "0",
+		Timestamp:  IgnoreTime,
+		Operation:  "$set",
+		Properties: preferred,
+	}, opts...)
+}
+
+// MergeIdentity merges anonId, such as a device id, into identifiedId, the id
+// of the user once known, with a $merge event. It is Merge of the two ids, in
+// that order, and is the preferred replacement of Alias.
+func (m *mixpanel) MergeIdentity(identifiedId, anonId string, opts ...CallOption) error {
+	return m.MergeIdentityCtx(context.Background(), identifiedId, anonId, opts...)
+}
+
+// MergeIdentityCtx is like MergeIdentity, but sends the requests with ctx.
+func (m *mixpanel) MergeIdentityCtx(ctx context.Context, identifiedId, anonId string, opts ...CallOption) error {
+	return m.MergeCtx(ctx, []string{identifiedId, anonId}, opts...)
+}
+
+// CreateIdentity links anonId, such as a device id, to identifiedId by sending
+// Mixpanel's $identify event to /track.
+//
+// Deprecated: Use Identify, which takes the ids the other way around, like
+// the $identify event.
+func (m *mixpanel) CreateIdentity(identifiedId, anonId string) error {
+	return m.Identify(anonId, identifiedId)
+}
+
+// Identify links anonId, such as a device id, to identifiedId, the id of the
+// user once known, by sending Mixpanel's $identify event to /track. It targets
+// projects using the Original ID Merge identity management mode, along with
+// Merge and MergeIdentity; projects on Simplified ID Merge link ids through
+// the $device_id and $user_id event properties instead, and legacy projects
+// use Alias. The request is authenticated with the project token alone.
+func (m *mixpanel) Identify(anonId, identifiedId string, opts ...CallOption) error {
+	return m.IdentifyCtx(context.Background(), anonId, identifiedId, opts...)
+}
+
+// IdentifyCtx is like Identify, but sends the request with ctx.
+func (m *mixpanel) IdentifyCtx(ctx context.Context, anonId, identifiedId string, opts ...CallOption) error {
+	props := map[string]interface{}{
+		"token":          m.Token,
+		"$identified_id": identifiedId,
+		"$anon_id":       anonId,
+	}
+
+	params := map[string]interface{}{
+		"event":      "$identify",
+		"properties": props,
+	}
+
+	return m.send(ctx, "track", params, false, newCallOptions(opts))
+}
+
+// Import sends an event to the /import endpoint, whatever its age,
+// authenticated with the API secret or the service account given to
+// WithServiceAccount. /import rejects events without a time, so an event
+// without a Timestamp is stamped with the current time of the client. Use
+// ImportBatch for many events.
+func (m *mixpanel) Import(distinctId, eventName string, e *Event, opts ...CallOption) error {
+	return m.ImportCtx(context.Background(), distinctId, eventName, e, opts...)
+}
+
+// ImportCtx is like Import, but adds the properties returned by the extractor
+// given to WithContextPropertyExtractor and sends the request with ctx, like
+// TrackCtx.
+func (m *mixpanel) ImportCtx(ctx context.Context, distinctId, eventName string, e *Event, opts ...CallOption) error {
+	return m.TrackCtx(ctx, distinctId, eventName, e, append(opts, ForceEndpoint(EndpointImport))...)
+}
+
+// WithImportThreshold sets the age from which Track sends events to /import
+// instead of /track, 5 days by default, the most /track accepts. Zero turns
+// the switch off: events then go to /track unless ForceEndpoint or Import
+// says otherwise.
+func WithImportThreshold(d time.Duration) Option {
+	return func(m *mixpanel) {
+		m.importAfter = d
+	}
 }
 
 // Track create a events to current distinct id
-func (m *mixpanel) Track(distinctId, eventName string, e *Event) error {
-	var (
-		eventType = "track"
-	)
+func (m *mixpanel) Track(distinctId, eventName string, e *Event, opts ...CallOption) error {
+	return m.TrackCtx(context.Background(), distinctId, eventName, e, opts...)
+}
+
+// TrackCtx is like Track, but adds the properties returned by the extractor
+// given to WithContextPropertyExtractor and sends the request with ctx.
+func (m *mixpanel) TrackCtx(ctx context.Context, distinctId, eventName string, e *Event, opts ...CallOption) error {
+	call := newCallOptions(opts)
+	e = withGroups(e, call)
+	eventType, stale := routeEvent(e, call, m.importAfter, m.now())
+
+	if stale || call.endpoint != "" {
+		fields := map[string]interface{}{
+			"eventName": eventName,
+			"threshold": m.importAfter.String(),
+			"endpoint":  eventType,
+			"forced":    call.endpoint != "",
+		}
+		if e.Timestamp != nil {
+			fields["timestamp"] = e.Timestamp.Unix()
+		}
+		m.logFields("mixpanel: routing event", fields)
+	}
+
+	params, err := m.eventParams(ctx, eventType, distinctId, eventName, e)
+	if err != nil {
+		return err
+	}
+
+	autoGeolocate := geolocate(e.IP, e.Geolocate)
+
+	if m.overflowBytes > 0 {
+		for _, part := range m.splitOverflow(eventName, params) {
+			if err := m.send(ctx, eventType, part, autoGeolocate, call); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return m.send(ctx, eventType, params, autoGeolocate, call)
+}
+
+// routeEvent returns the endpoint e is sent to, and whether it is older than
+// threshold at now, which is never the case for a threshold of zero.
+func routeEvent(e *Event, call *callOptions, threshold time.Duration, now time.Time) (string, bool) {
+	eventType := "track"
+
+	// If the event took place before the threshold, use the /import endpoint
+	stale := threshold > 0 && e.Timestamp != nil && e.Timestamp.Before(now.Add(-threshold))
+	if stale {
+		eventType = "import"
+	}
+	if call.endpoint != "" {
+		eventType = call.endpoint
+	}
+
+	return eventType, stale
+}
+
+// eventParams builds the payload of a single event sent to the eventType
+// endpoint.
+func (m *mixpanel) eventParams(ctx context.Context, eventType, distinctId, eventName string, e *Event) (map[string]interface{}, error) {
+	eventProps, err := m.validateEvent(ctx, eventName, m.withContextProperties(ctx, e.Properties))
+	if err != nil {
+		return nil, err
+	}
+
+	eventProps, err = m.resolveIP(eventName, e.IP, eventProps)
+	if err != nil {
+		return nil, err
+	}
 
 	props := map[string]interface{}{
 		"token":       m.Token,
@@ -118,129 +648,609 @@ func (m *mixpanel) Track(distinctId, eventName string, e *Event) error {
 	if e.IP != "" {
 		props["ip"] = e.IP
 	}
-	if e.Timestamp != nil {
-		props["time"] = e.Timestamp.Unix()
-		// If the event took place more than 5 days ago, use the /import endpoint
-		if e.Timestamp.Before(time.Now().Add(time.Hour * 24 * -5)) {
-			eventType = "import"
+
+	// /import rejects events without a time, so they are stamped with the
+	// current one.
+	timestamp := e.Timestamp
+	if timestamp == nil && eventType == "import" {
+		now := m.now()
+		timestamp = &now
+	}
+	if timestamp != nil {
+		if err := m.checkTimestamp(eventName, *timestamp); err != nil {
+			return nil, err
 		}
+		props["time"] = m.timestamp(eventType, *timestamp)
 	}
 
-	for key, value := range e.Properties {
-		props[key] = value
+	m.mergeProperties(props, eventProps)
+	props = m.scrubProperties(props)
+
+	if e.InsertId != "" {
+		props["$insert_id"] = e.InsertId
+	} else if m.autoInsertId {
+		m.addInsertId(eventType, props, distinctId, eventName, timestamp)
 	}
 
-	params := map[string]interface{}{
+	if m.cardinality != nil {
+		m.cardinality.observe(m.infof, eventName, eventProps)
+	}
+
+	return map[string]interface{}{
 		"event":      eventName,
 		"properties": props,
+	}, nil
+}
+
+// Updates a user in mixpanel. See
+// https://mixpanel.com/help/reference/http#people-analytics-updates
+func (m *mixpanel) Update(distinctId string, u *Update, opts ...CallOption) error {
+	return m.UpdateCtx(context.Background(), distinctId, u, opts...)
+}
+
+// UpdateCtx is like Update, but sends the request with ctx.
+func (m *mixpanel) UpdateCtx(ctx context.Context, distinctId string, u *Update, opts ...CallOption) error {
+	if u.isEmpty() {
+		return ErrNoProperties
 	}
 
-	autoGeolocate := e.IP == ""
+	params, err := m.updateParams(distinctId, u)
+	if err != nil {
+		return err
+	}
 
-	return m.send(eventType, params, autoGeolocate)
+	autoGeolocate := geolocate(u.IP, u.Geolocate)
+
+	return m.send(ctx, "engage", params, autoGeolocate, newCallOptions(opts))
 }
 
-// Updates a user in mixpanel. See
-// https://mixpanel.com/help/reference/http#people-analytics-updates
-func (m *mixpanel) Update(distinctId string, u *Update) error {
+// updateParams builds the engage record of a single profile update.
+func (m *mixpanel) updateParams(distinctId string, u *Update) (map[string]interface{}, error) {
+	if err := m.validateProfile(u.Properties); err != nil {
+		return nil, err
+	}
+
 	params := map[string]interface{}{
 		"$token":       m.Token,
 		"$distinct_id": distinctId,
 	}
 
 	if u.IP != "" {
-		params["$ip"] = u.IP
+		if ip, ok := m.scrub("$ip", u.IP); ok {
+			params["$ip"] = ip
+		}
 	}
 	if u.Timestamp == IgnoreTime {
 		params["$ignore_time"] = true
 	} else if u.Timestamp != nil {
-		params["$time"] = u.Timestamp.Unix()
+		if err := m.checkTimestamp("", *u.Timestamp); err != nil {
+			return nil, err
+		}
+		params["$time"] = m.timestamp("engage", *u.Timestamp)
 	}
 
-	params[u.Operation] = u.Properties
+	if u.Operation == OpUnset {
+		params[u.Operation] = u.Unset
+	} else if u.Operation != "" {
+		params[u.Operation] = m.scrubProperties(m.coerceProperties(u.Properties))
+	}
 
-	autoGeolocate := u.IP == ""
+	return params, nil
+}
 
-	return m.send("engage", params, autoGeolocate)
+// geolocate reports whether to ask Mixpanel to geolocate an event or update
+// with the given IP and Geolocate fields.
+func geolocate(ip string, g *bool) bool {
+	if g != nil {
+		return *g
+	}
+	return ip == ""
 }
 
 func (m *mixpanel) to64(data []byte) string {
 	return base64.StdEncoding.EncodeToString(data)
 }
 
-func (m *mixpanel) send(eventType string, params interface{}, autoGeolocate bool) error {
+func (m *mixpanel) send(ctx context.Context, eventType string, params interface{}, autoGeolocate bool, call *callOptions) error {
+	return m.sendWith(ctx, eventType, params, call, func(ctx context.Context, endpoint string, payload []byte) (*Response, error) {
+		return m.sendData(ctx, endpoint, payload, autoGeolocate, call)
+	})
+}
+
+// sendWith sends params, JSON-encoded, to the eventType endpoint with
+// transport, through everything the options of the client apply to the
+// ingestion calls: the breaker, timeouts, tracing, middleware, the Sink, which
+// replaces transport, rate limits, retries, metrics and the error ring. call
+// may be nil.
+func (m *mixpanel) sendWith(ctx context.Context, eventType string, params interface{}, call *callOptions, transport SendFunc) error {
 	data, err := json.Marshal(params)
 
 	if err != nil {
 		return err
 	}
 
-	reqUrl := m.ApiURL + "/" + eventType + "?data=" + m.to64(data)
+	if err := checkPayloadSize(data, m.payloadLimit(eventType)); err != nil {
+		return err
+	}
+
+	if m.disabled() {
+		return nil
+	}
+
+	if ok, err := m.breaker.allow(); !ok {
+		return err
+	}
+
+	ctx, cancel := m.withTimeout(ctx, eventType)
+	defer cancel()
+
+	events := eventCount(params)
+	ctx, span := m.startSpan(ctx, eventType, params, events)
+
+	sendFunc := m.chain(func(ctx context.Context, endpoint string, payload []byte) (*Response, error) {
+		if m.sink != nil {
+			return nil, m.sink.Send(ctx, endpoint, payload)
+		}
+		return transport(ctx, endpoint, payload)
+	})
+
+	var (
+		attempt int
+		resp    *Response
+	)
+	for ; ; attempt++ {
+		release, throttleErr := m.throttle(ctx, events)
+		if throttleErr != nil {
+			err = throttleErr
+			break
+		}
+
+		start := time.Now()
+		resp, err = sendFunc(ctx, eventType, data)
+		m.collectSend(eventType, events, start, err)
+		release()
+		if resp != nil {
+			call.recordResponse(resp)
+		}
+
+		if err == nil || attempt >= m.maxRetries || !m.shouldRetry(err) {
+			break
+		}
+
+		m.infof("mixpanel: retrying %s request (retry %d of %d): %v", eventType, attempt+1, m.maxRetries, err)
+
+		if waitErr := m.waitRetry(ctx, attempt, err); waitErr != nil {
+			err = waitErr
+			break
+		}
+	}
+
+	var mpErr *MixpanelError
+	if errors.As(err, &mpErr) && mpErr.Payload == nil {
+		mpErr.Payload = data
+	}
+
+	if err != nil && m.errorRing != nil {
+		m.errorRing.add(m.now(), eventType, data, err)
+	}
+
+	m.endCall(ctx, span, eventType, events, attempt, resp, err)
+	m.breaker.record(err)
+
+	return err
+}
+
+// sendData sends the JSON-encoded payload data to the eventType endpoint, and
+// returns the response of Mixpanel, if any. call may be nil.
+func (m *mixpanel) sendData(ctx context.Context, eventType string, data []byte, autoGeolocate bool, call *callOptions) (*Response, error) {
+	reqUrl := m.ApiURL + "/" + eventType + "?"
+	var (
+		reqBody     io.Reader
+		contentType string
+		compressed  bool
+	)
+
+	switch {
+	case m.rawJSONBody:
+		var err error
+		if data, compressed, err = m.compressBody(data); err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+		contentType = "application/json"
+	case m.queryData:
+		reqUrl += "data=" + m.to64(data) + "&"
+	default:
+		form := url.Values{"data": {m.to64(data)}}
+		reqBody = strings.NewReader(form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	}
 
 	if autoGeolocate {
-		reqUrl += "&ip=1"
+		reqUrl += "ip=1&"
+	}
+	if eventType == "import" {
+		reqUrl += m.importQuery()
 	}
 
 	// Add verbose debug
-	reqUrl += "&verbose=1"
+	reqUrl += "verbose=1"
 
-	wrapErr := func(err error) error {
-		return &MixpanelError{URL: reqUrl, Message: err.Error()}
+	req, err := http.NewRequestWithContext(ctx, endpointMethod(eventType), reqUrl, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	m.authenticate(req, eventType)
+
+	call.applyHeader(req)
+
+	m.debugf("mixpanel: %s %s/%s", req.Method, m.ApiURL, eventType)
+
+	status, header, body, err := m.roundTrip(req)
+
+	if err != nil {
+		return nil, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, reqUrl, nil)
+	response := &Response{HttpStatus: status}
+
+	// Some endpoints, and some proxies in front of Mixpanel, acknowledge a
+	// request with an empty body.
+	if len(body) == 0 && status >= 200 && status <= 299 {
+		return response, nil
+	}
 
-	req.SetBasicAuth(m.ApiSecret, "")
+	serverErr := &MixpanelError{
+		URL:        reqUrl,
+		HttpStatus: status,
+		RetryAfter: retryAfter(header),
+		Header:     header,
+	}
+	if eventType == "import" {
+		serverErr.FailedRecords = failedRecords(body)
+	}
+	var resp struct {
+		Status json.RawMessage `json:"status"`
+		Error  string          `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		serverErr.Message = err.Error()
+		serverErr.RawBody = rawBody(body)
+		return response, serverErr
+	}
+
+	// Without the verbose envelope, the HTTP status is all there is to go
+	// by.
+	if resp.Status == nil {
+		if status < 200 || status > 299 {
+			serverErr.Message = string(body)
+			serverErr.RawBody = rawBody(body)
+			return response, serverErr
+		}
+		m.logWarnings(eventType, body)
+		return response, nil
+	}
+
+	code := verboseStatus(resp.Status)
+	response.Status = code
+	response.Message = resp.Error
+
+	if !m.isSuccessStatus(code) {
+		serverErr.Code, _ = strconv.Atoi(code)
+		serverErr.Message = resp.Error
+		if status < 200 || status > 299 {
+			serverErr.RawBody = rawBody(body)
+		}
+		return response, serverErr
+	}
+
+	m.logWarnings(eventType, body)
+
+	return response, nil
+}
+
+// DefaultSuccessStatuses are the values of the "status" field of a verbose
+// response that mean success: 1 for /track and /engage, and "OK" for
+// /import, which reports failures with another status and an HTTP error.
+// Statuses are compared case-insensitively, and numbers and strings holding
+// them are equivalent. Override them with WithSuccessStatuses.
+var DefaultSuccessStatuses = []string{"1", "OK"}
+
+// WithSuccessStatuses replaces DefaultSuccessStatuses, for endpoints or
+// proxies reporting success with another status. Responses with no status
+// field at all are judged by their HTTP status instead.
+func WithSuccessStatuses(statuses ...string) Option {
+	return func(m *mixpanel) {
+		m.successStatuses = statuses
+	}
+}
+
+// verboseStatus returns the "status" field of a verbose response as a string,
+// whether it was sent as a number or a string.
+func verboseStatus(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+func (m *mixpanel) isSuccessStatus(code string) bool {
+	for _, success := range m.successStatuses {
+		if strings.EqualFold(code, success) {
+			return true
+		}
+	}
+	return false
+}
+
+// do performs req and returns the HTTP status and body of the response.
+func (m *mixpanel) do(req *http.Request) (int, []byte, error) {
+	status, _, body, err := m.roundTrip(req)
+	return status, body, err
+}
+
+// roundTrip is like do, but also returns the headers of the response.
+func (m *mixpanel) roundTrip(req *http.Request) (int, http.Header, []byte, error) {
+	wrapErr := func(err error) error {
+		return &MixpanelError{URL: req.URL.String(), Message: err.Error(), Err: err}
+	}
 
 	resp, err := m.Client.Do(req)
 
 	if err != nil {
-		return wrapErr(err)
+		return 0, nil, nil, wrapErr(err)
 	}
 
 	defer resp.Body.Close()
 
-	body, bodyErr := ioutil.ReadAll(resp.Body)
+	body, bodyErr := io.ReadAll(resp.Body)
 
 	if bodyErr != nil {
-		return wrapErr(bodyErr)
+		return resp.StatusCode, resp.Header, nil, wrapErr(bodyErr)
 	}
 
-	serverErr := &MixpanelError{
-		URL:        reqUrl,
-		HttpStatus: resp.StatusCode,
+	return resp.StatusCode, resp.Header, body, nil
+}
+
+// An Option configures optional behaviour of the client returned by New and
+// NewFromClient.
+type Option func(*mixpanel)
+
+// A CallOption configures a single call made through the client.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	endpoint      string
+	header        http.Header
+	preferProfile string
+	ignoreAlias   bool
+	groups        map[string][]string
+	response      *Response
+}
+
+func newCallOptions(opts []CallOption) *callOptions {
+	call := &callOptions{}
+	for _, opt := range opts {
+		opt(call)
 	}
-	if len(body) > 0 {
-		err := json.Unmarshal(body, serverErr)
-		if err != nil {
-			serverErr.Message = err.Error()
-		}
+	return call
+}
+
+// recordResponse stores resp for CaptureResponse. call may be nil.
+func (call *callOptions) recordResponse(resp *Response) {
+	if call == nil || call.response == nil {
+		return
+	}
+	*call.response = *resp
+}
+
+// applyHeader adds the headers given with RequestHeader to req. call may be
+// nil.
+func (call *callOptions) applyHeader(req *http.Request) {
+	if call == nil {
+		return
+	}
+	for key, values := range call.header {
+		req.Header[key] = values
+	}
+}
+
+// Endpoints that a call can be forced to with ForceEndpoint.
+const (
+	EndpointTrack  = "track"
+	EndpointImport = "import"
+)
+
+// ForceEndpoint sends the event to the given endpoint (EndpointTrack or
+// EndpointImport) regardless of its timestamp. By default Track sends events
+// older than 5 days to /import; forcing EndpointTrack sends them to /track
+// anyway, where Mixpanel will typically reject them.
+func ForceEndpoint(endpoint string) CallOption {
+	return func(call *callOptions) {
+		call.endpoint = endpoint
+	}
+}
+
+// PreferProfile makes the properties of the profile of distinctId win when
+// Merge merges it with profiles setting the same properties. The profile is
+// read before the merge and its properties are set again on the merged profile
+// afterwards, so it takes two more requests, the second authenticated with the
+// API secret, and values written to the profile in between are overwritten.
+// Only Merge uses this option.
+func PreferProfile(distinctId string) CallOption {
+	return func(call *callOptions) {
+		call.preferProfile = distinctId
+	}
+}
+
+// WithQueryStringData sends the base64-encoded payload of Track, Update, Alias
+// and the other ingestion calls in the data query parameter of the URL, as
+// older versions of this package did, instead of in a form-encoded request
+// body. Large payloads can then exceed the URL length limits of proxies and
+// of Mixpanel itself; use it only with collectors that read the query string.
+func WithQueryStringData() Option {
+	return func(m *mixpanel) {
+		m.queryData = true
+	}
+}
+
+// WithRawJSONBody sends the payload of Track, Update, Alias and the other
+// ingestion calls as raw JSON in the request body, with a Content-Type of
+// application/json, instead of base64-encoded in the data form parameter.
+// This saves the encoding overhead with collectors that accept it, such as a
+// self-hosted proxy. Keep the default with the real Mixpanel API, which only
+// documents the base64 data parameter for these endpoints.
+func WithRawJSONBody() Option {
+	return func(m *mixpanel) {
+		m.rawJSONBody = true
+	}
+}
+
+// A Response describes the answer of Mixpanel to an ingestion call, as
+// stored by CaptureResponse.
+type Response struct {
+	HttpStatus int
+
+	// Status is the "status" field of the verbose response, such as "1" or
+	// "OK", or empty if the response had none.
+	Status string
+
+	// Message is the "error" field of the verbose response, which may carry
+	// a message or warning even on success.
+	Message string
+}
+
+// CaptureResponse stores the response to the call in resp, whether the call
+// succeeds or not, for logging or debugging ingestion. resp is left as is if
+// the request got no response. A call that sends several requests, such as
+// UpdateBatch, stores the response to the last one.
+func CaptureResponse(resp *Response) CallOption {
+	return func(call *callOptions) {
+		call.response = resp
+	}
+}
+
+// IgnoreAlias makes PeopleDelete delete the profile of the given distinct id
+// only, rather than the profile it is an alias of. Only PeopleDelete uses this
+// option.
+func IgnoreAlias() CallOption {
+	return func(call *callOptions) {
+		call.ignoreAlias = true
 	}
-	if serverErr.Code != 1 {
-		return serverErr
+}
+
+// WithSelfAlias lets Alias alias a distinct id to itself instead of failing
+// with ErrSelfAlias.
+func WithSelfAlias() Option {
+	return func(m *mixpanel) {
+		m.allowSelfAlias = true
 	}
+}
 
-	return nil
+// RequestHeader adds a header to the request made by a single call, for
+// example to tag it for a proxy that multiplexes environments. With the batch
+// methods, the header is set on every request of the batch, for example an
+// X-Idempotency-Key to trace the batch through a pipeline. It may be given
+// several times.
+func RequestHeader(key, value string) CallOption {
+	return func(call *callOptions) {
+		if call.header == nil {
+			call.header = http.Header{}
+		}
+		call.header.Add(key, value)
+	}
 }
 
 // New returns the client instance. If apiURL is blank, the default will be used
-// ("https://api.mixpanel.com").
-func New(token, key, secret, apiURL string) Mixpanel {
-	return NewFromClient(http.DefaultClient, token, key, secret, apiURL)
+// ("https://api.mixpanel.com", or the host of the region given to WithRegion).
+func New(token, key, secret, apiURL string, opts ...Option) Mixpanel {
+	return NewFromClient(http.DefaultClient, token, key, secret, apiURL, opts...)
 }
 
-// Creates a client instance using the specified client instance. This is useful
-// when using a proxy.
-func NewFromClient(c *http.Client, token, key, secret, apiURL string) Mixpanel {
-	if apiURL == "" {
-		apiURL = "https://api.mixpanel.com"
+// NewWithRegion returns a client sending to the hosts of the data residency
+// region of the project.
+func NewWithRegion(token, secret string, region Region, opts ...Option) Mixpanel {
+	return New(token, "", secret, "", append([]Option{WithRegion(region)}, opts...)...)
+}
+
+// NewWithOptions returns a client for the project token, configured by opts
+// alone, such as WithAPISecret, WithAPIURL and WithHTTPClient, so that new
+// settings never change its signature. Without options it sends to the
+// default hosts with http.DefaultClient.
+func NewWithOptions(token string, opts ...Option) Mixpanel {
+	return NewFromClient(http.DefaultClient, token, "", "", "", opts...)
+}
+
+// WithHTTPClient sets the HTTP client requests are made with, for example one
+// going through a proxy.
+func WithHTTPClient(c *http.Client) Option {
+	return func(m *mixpanel) {
+		m.Client = c
 	}
+}
 
-	return &mixpanel{
+// WithAPIURL sets the base URL of the ingestion API, such as a proxy. It wins
+// over the host of a region given before it.
+func WithAPIURL(apiURL string) Option {
+	return func(m *mixpanel) {
+		m.ApiURL = apiURL
+	}
+}
+
+// WithAPIKey sets the API key of the project.
+func WithAPIKey(key string) Option {
+	return func(m *mixpanel) {
+		m.ApiKey = key
+	}
+}
+
+// WithAPISecret sets the API secret of the project, which authenticates
+// /import, the query APIs and Export.
+func WithAPISecret(secret string) Option {
+	return func(m *mixpanel) {
+		m.ApiSecret = secret
+	}
+}
+
+// Creates a client instance using the specified client instance. This is useful
+// when using a proxy.
+func NewFromClient(c *http.Client, token, key, secret, apiURL string, opts ...Option) Mixpanel {
+	m := &mixpanel{
 		Client:    c,
 		Token:     token,
 		ApiKey:    key,
 		ApiSecret: secret,
-		ApiURL:    apiURL,
+		logger:    nopLogger{},
+
+		cohortProperty:  DefaultCohortProperty,
+		maxNameLength:   MaxPropertyNameLength,
+		maxProperties:   MaxEventProperties,
+		successStatuses: DefaultSuccessStatuses,
+		importAfter:     importThreshold,
 	}
+
+	WithRegion(US)(m)
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	// An explicit API URL, such as a proxy, wins over the region.
+	if apiURL != "" {
+		m.ApiURL = apiURL
+	}
+
+	if m.breaker != nil && m.clock != nil {
+		m.breaker.now = m.clock.Now
+	}
+
+	return m
 }
@@ -1,12 +1,18 @@
 package mixpanel
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 var (
@@ -17,6 +23,7 @@ var (
 
 func setup() {
 	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
 		w.WriteHeader(200)
 		w.Write([]byte("1\n"))
 		LastRequest = r
@@ -29,9 +36,10 @@ func teardown() {
 	ts.Close()
 }
 
-func decodeURL(url string) string {
-	data := strings.Split(url, "data=")[1]
-	decoded, _ := base64.StdEncoding.DecodeString(data)
+// decodeData returns the decoded data parameter of r, from its form body or
+// its query string.
+func decodeData(r *http.Request) string {
+	decoded, _ := base64.StdEncoding.DecodeString(r.FormValue("data"))
 	return string(decoded[:])
 }
 
@@ -49,9 +57,9 @@ func TestTrack(t *testing.T) {
 
 	want := "{\"event\":\"Signed Up\",\"properties\":{\"Referred By\":\"Friend\",\"distinct_id\":\"13793\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
 
-	if !reflect.DeepEqual(decodeURL(LastRequest.URL.String()), want) {
+	if !reflect.DeepEqual(decodeData(LastRequest), want) {
 		t.Errorf("LastRequest.URL returned %+v, want %+v",
-			decodeURL(LastRequest.URL.String()), want)
+			decodeData(LastRequest), want)
 	}
 
 	want = "/track"
@@ -77,9 +85,9 @@ func TestPeopleOperations(t *testing.T) {
 
 	want := "{\"$distinct_id\":\"13793\",\"$set\":{\"Address\":\"1313 Mockingbird Lane\",\"Birthday\":\"1948-01-01\"},\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}"
 
-	if !reflect.DeepEqual(decodeURL(LastRequest.URL.String()), want) {
+	if !reflect.DeepEqual(decodeData(LastRequest), want) {
 		t.Errorf("LastRequest.URL returned %+v, want %+v",
-			decodeURL(LastRequest.URL.String()), want)
+			decodeData(LastRequest), want)
 	}
 
 	want = "/engage"
@@ -103,9 +111,9 @@ func TestPeopleTrack(t *testing.T) {
 
 	want := "{\"event\":\"Signed Up\",\"properties\":{\"Referred By\":\"Friend\",\"distinct_id\":\"13793\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
 
-	if !reflect.DeepEqual(decodeURL(LastRequest.URL.String()), want) {
+	if !reflect.DeepEqual(decodeData(LastRequest), want) {
 		t.Errorf("LastRequest.URL returned %+v, want %+v",
-			decodeURL(LastRequest.URL.String()), want)
+			decodeData(LastRequest), want)
 	}
 
 	want = "/track"
@@ -116,3 +124,663 @@ func TestPeopleTrack(t *testing.T) {
 			path, want)
 	}
 }
+
+func TestTrackForceEndpoint(t *testing.T) {
+	setup()
+	defer teardown()
+
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	client.Track("13793", "Signed Up", &Event{Timestamp: &old}, ForceEndpoint(EndpointTrack))
+
+	if got, want := LastRequest.URL.Path, "/track"; got != want {
+		t.Errorf("path returned %+v, want %+v", got, want)
+	}
+
+	recent := time.Now()
+	client.Track("13793", "Signed Up", &Event{Timestamp: &recent}, ForceEndpoint(EndpointImport))
+
+	if got, want := LastRequest.URL.Path, "/import"; got != want {
+		t.Errorf("path returned %+v, want %+v", got, want)
+	}
+}
+
+func TestCreateIdentity(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.CreateIdentity("13793", "$device:1843fcf8")
+
+	want := "{\"event\":\"$identify\",\"properties\":{\"$anon_id\":\"$device:1843fcf8\",\"$identified_id\":\"13793\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+
+	if !reflect.DeepEqual(decodeData(LastRequest), want) {
+		t.Errorf("LastRequest.URL returned %+v, want %+v",
+			decodeData(LastRequest), want)
+	}
+
+	if got, want := LastRequest.URL.Path, "/track"; got != want {
+		t.Errorf("path returned %+v, want %+v", got, want)
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.Identify("$device:1843fcf8", "13793", RequestHeader("X-Request-Id", "42"))
+
+	want := "{\"event\":\"$identify\",\"properties\":{\"$anon_id\":\"$device:1843fcf8\",\"$identified_id\":\"13793\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("data returned %+v, want %+v", got, want)
+	}
+	if got, want := LastRequest.Header.Get("X-Request-Id"), "42"; got != want {
+		t.Errorf("X-Request-Id returned %+v, want %+v", got, want)
+	}
+}
+
+func TestAliasSelf(t *testing.T) {
+	setup()
+	defer teardown()
+	LastRequest = nil
+
+	if err := client.Alias("13793", "13793"); err != ErrSelfAlias {
+		t.Errorf("Alias returned %v, want %v", err, ErrSelfAlias)
+	}
+	if LastRequest != nil {
+		t.Errorf("Alias sent a request for a self-alias")
+	}
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithSelfAlias())
+	client.Alias("13793", "13793")
+
+	if LastRequest == nil {
+		t.Errorf("Alias with WithSelfAlias sent no request")
+	}
+}
+
+func TestRequestHeader(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.Track("13793", "Signed Up", &Event{}, RequestHeader("X-Environment", "staging"))
+	if got := LastRequest.Header.Get("X-Environment"); got != "staging" {
+		t.Errorf("Track header returned %+v, want %+v", got, "staging")
+	}
+
+	client.Update("13793", &Update{Operation: "$set", Properties: map[string]interface{}{"Plan": "pro"}}, RequestHeader("X-Environment", "production"))
+	if got := LastRequest.Header.Get("X-Environment"); got != "production" {
+		t.Errorf("Update header returned %+v, want %+v", got, "production")
+	}
+
+	client.Alias("13793", "13794", RequestHeader("X-Environment", "test"))
+	if got := LastRequest.Header.Get("X-Environment"); got != "test" {
+		t.Errorf("Alias header returned %+v, want %+v", got, "test")
+	}
+
+	client.Track("13793", "Signed Up", &Event{})
+	if got := LastRequest.Header.Get("X-Environment"); got != "" {
+		t.Errorf("header returned %+v without RequestHeader", got)
+	}
+}
+
+func TestMergePreferProfile(t *testing.T) {
+	var paths []string
+	var engage string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/2.0/engage":
+			w.Write([]byte(`{"results":[{"$distinct_id":"13793","$properties":{"plan":"pro"}}]}`))
+			return
+		case "/engage":
+			engage = decodeData(r)
+		}
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL, WithQueryURL(ts.URL))
+
+	if err := client.Merge([]string{"13793", "13794"}, PreferProfile("13793")); err != nil {
+		t.Fatalf("Merge returned %v", err)
+	}
+
+	wantPaths := []string{"/2.0/engage", "/import", "/engage"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("Merge requested %+v, want %+v", paths, wantPaths)
+	}
+
+	want := "{\"$distinct_id\":\"13793\",\"$ignore_time\":true,\"$ip\":\"0\",\"$set\":{\"plan\":\"pro\"},\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}"
+	if engage != want {
+		t.Errorf("Merge set %+v, want %+v", engage, want)
+	}
+}
+
+func TestTrackEmptyBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+	if err := client.Track("13793", "Signed Up", &Event{}); err != nil {
+		t.Errorf("Track returned %v for an empty 200 response", err)
+	}
+}
+
+func TestTrackNoStatusField(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"accepted":true}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+	if err := client.Track("13793", "Signed Up", &Event{}); err != nil {
+		t.Errorf("Track returned %v for a 200 response without status", err)
+	}
+}
+
+func TestRawJSONBody(t *testing.T) {
+	var query, contentType, body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		contentType = r.Header.Get("Content-Type")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithRawJSONBody())
+	if err := client.Track("13793", "Signed Up", &Event{IP: "0"}); err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+
+	want := "{\"event\":\"Signed Up\",\"properties\":{\"distinct_id\":\"13793\",\"ip\":\"0\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+	if body != want {
+		t.Errorf("body returned %+v, want %+v", body, want)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type returned %+v, want %+v", contentType, "application/json")
+	}
+	if query != "verbose=1" {
+		t.Errorf("query returned %+v, want %+v", query, "verbose=1")
+	}
+}
+
+func TestFormBody(t *testing.T) {
+	var query, contentType string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		contentType = r.Header.Get("Content-Type")
+		r.ParseForm()
+		LastRequest = r
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+	if err := client.Track("13793", "Signed Up", &Event{IP: "0"}); err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+
+	want := "{\"event\":\"Signed Up\",\"properties\":{\"distinct_id\":\"13793\",\"ip\":\"0\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("data returned %+v, want %+v", got, want)
+	}
+	if contentType != "application/x-www-form-urlencoded" {
+		t.Errorf("Content-Type returned %+v, want %+v", contentType, "application/x-www-form-urlencoded")
+	}
+	if query != "verbose=1" {
+		t.Errorf("query returned %+v, want %+v", query, "verbose=1")
+	}
+
+	client = New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithQueryStringData())
+	if err := client.Track("13793", "Signed Up", &Event{IP: "0"}); err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+
+	if got := LastRequest.URL.Query().Get("data"); got == "" {
+		t.Errorf("data query parameter is empty with WithQueryStringData")
+	}
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("data returned %+v, want %+v", got, want)
+	}
+	if contentType != "" {
+		t.Errorf("Content-Type returned %+v, want none", contentType)
+	}
+}
+
+func TestSuccessStatuses(t *testing.T) {
+	var response string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(response))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+
+	for _, ok := range []string{
+		`{"status":1,"error":null}`,
+		`{"status":"1","error":null}`,
+		`{"code":200,"num_records_imported":1,"status":"OK"}`,
+	} {
+		response = ok
+		if err := client.Track("13793", "Signed Up", &Event{}); err != nil {
+			t.Errorf("Track returned %v for %s", err, ok)
+		}
+	}
+
+	response = `{"status":0,"error":"token, missing or empty"}`
+	err := client.Track("13793", "Signed Up", &Event{})
+	mpErr := err.(*MixpanelError)
+	want := &MixpanelError{URL: mpErr.URL, HttpStatus: 200, Code: 0, Message: "token, missing or empty", Header: mpErr.Header, Payload: mpErr.Payload}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("Track returned %+v, want %+v", err, want)
+	}
+
+	response = `{"status":"accepted"}`
+	client = New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithSuccessStatuses("accepted"))
+	if err := client.Track("13793", "Signed Up", &Event{}); err != nil {
+		t.Errorf("Track returned %v with a custom success status", err)
+	}
+
+	response = `{"status":1,"error":null}`
+	if err := client.Track("13793", "Signed Up", &Event{}); err == nil {
+		t.Errorf("Track returned no error for a status replaced by WithSuccessStatuses")
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL)
+	client.Track("13793", "Signed Up", &Event{})
+
+	// The API secret is the user name, with an empty password.
+	want := "Basic " + base64.StdEncoding.EncodeToString([]byte("secret:"))
+	if got := LastRequest.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization returned %+v, want %+v", got, want)
+	}
+}
+
+func TestMalformedURL(t *testing.T) {
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", "http://[::1")
+	if err := client.Track("13793", "Signed Up", &Event{}); err == nil {
+		t.Errorf("Track returned no error for a malformed API URL")
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := client.TrackCtx(ctx, "13793", "Signed Up", &Event{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("TrackCtx returned %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = client.UpdateCtx(canceled, "13793", &Update{Operation: "$set", Properties: map[string]interface{}{"Plan": "pro"}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("UpdateCtx returned %v, want %v", err, context.Canceled)
+	}
+
+	err = client.AliasCtx(canceled, "13793", "13794")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("AliasCtx returned %v, want %v", err, context.Canceled)
+	}
+
+	err = client.MergeCtx(canceled, []string{"13793", "13794"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("MergeCtx returned %v, want %v", err, context.Canceled)
+	}
+
+	err = client.MergeIdentityCtx(canceled, "13793", "13794")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("MergeIdentityCtx returned %v, want %v", err, context.Canceled)
+	}
+
+	err = client.PeopleSetCtx(canceled, "13793", map[string]interface{}{"Plan": "pro"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("PeopleSetCtx returned %v, want %v", err, context.Canceled)
+	}
+
+	err = client.PeopleDeleteCtx(canceled, "13793")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("PeopleDeleteCtx returned %v, want %v", err, context.Canceled)
+	}
+
+	err = client.GroupDeleteCtx(canceled, "company", "Acme")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("GroupDeleteCtx returned %v, want %v", err, context.Canceled)
+	}
+
+	err = client.UpdateBatchCtx(canceled, []BatchUpdate{{DistinctId: "13793", Update: Update{Operation: "$set", Properties: map[string]interface{}{"Plan": "pro"}}}})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("UpdateBatchCtx returned %v, want %v", err, context.Canceled)
+	}
+
+	client = New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithExportURL(ts.URL))
+	if _, err := client.ExportCtx(canceled, ExportParams{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("ExportCtx returned %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCaptureResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":1,"error":"property \"plan\" was truncated"}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+
+	var resp Response
+	if err := client.Track("13793", "Signed Up", &Event{IP: "0"}, CaptureResponse(&resp)); err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+
+	want := Response{HttpStatus: 200, Status: "1", Message: "property \"plan\" was truncated"}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("CaptureResponse returned %+v, want %+v", resp, want)
+	}
+}
+
+func TestUpdateNoProperties(t *testing.T) {
+	setup()
+	defer teardown()
+
+	for _, u := range []*Update{
+		{},
+		{Operation: "$set"},
+		{Operation: "$set", Properties: map[string]interface{}{}},
+	} {
+		LastRequest = nil
+		if err := client.Update("13793", u); err != ErrNoProperties {
+			t.Errorf("Update returned %v for %+v, want %v", err, u, ErrNoProperties)
+		}
+		if LastRequest != nil {
+			t.Errorf("Update sent a request for %+v", u)
+		}
+	}
+
+	client.Update("13793", &Update{Operation: "$delete"})
+	if LastRequest == nil {
+		t.Errorf("Update sent no request for $delete")
+	}
+
+	strict := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithRequireProperties())
+	if err := strict.Track("13793", "Signed Up", &Event{}); !errors.Is(err, ErrNoProperties) {
+		t.Errorf("Track returned %v, want it to match %v", err, ErrNoProperties)
+	}
+}
+
+func TestMergeIdentity(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.MergeIdentity("13793", "device-1")
+
+	want := "{\"event\":\"$merge\",\"properties\":{\"$distinct_ids\":[\"13793\",\"device-1\"],\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("LastRequest.URL returned %+v, want %+v", got, want)
+	}
+	if LastRequest.URL.Path != "/import" {
+		t.Errorf("path returned %+v, want %+v", LastRequest.URL.Path, "/import")
+	}
+}
+
+func TestGeolocate(t *testing.T) {
+	setup()
+	defer teardown()
+
+	yes, no := true, false
+	for _, tc := range []struct {
+		ip        string
+		geolocate *bool
+		want      string
+	}{
+		{"", nil, "1"},
+		{"", &yes, "1"},
+		{"", &no, ""},
+		{"127.0.0.1", nil, ""},
+		{"127.0.0.1", &yes, "1"},
+		{"127.0.0.1", &no, ""},
+	} {
+		client.Track("13793", "Signed Up", &Event{IP: tc.ip, Geolocate: tc.geolocate})
+		if got := LastRequest.URL.Query().Get("ip"); got != tc.want {
+			t.Errorf("Track ip with IP %q and Geolocate %v returned %+v, want %+v", tc.ip, tc.geolocate, got, tc.want)
+		}
+
+		client.Update("13793", &Update{
+			IP:         tc.ip,
+			Geolocate:  tc.geolocate,
+			Operation:  "$set",
+			Properties: map[string]interface{}{"Plan": "pro"},
+		})
+		if got := LastRequest.URL.Query().Get("ip"); got != tc.want {
+			t.Errorf("Update ip with IP %q and Geolocate %v returned %+v, want %+v", tc.ip, tc.geolocate, got, tc.want)
+		}
+	}
+}
+
+func TestRawBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("upstream connect error"))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+
+	err := client.Track("13793", "Signed Up", &Event{IP: "0"})
+
+	mpErr, ok := err.(*MixpanelError)
+	if !ok {
+		t.Fatalf("Track returned %v, want a *MixpanelError", err)
+	}
+	if mpErr.RawBody != "upstream connect error" {
+		t.Errorf("RawBody returned %+v, want %+v", mpErr.RawBody, "upstream connect error")
+	}
+	if !strings.Contains(err.Error(), "upstream connect error") {
+		t.Errorf("Error returned %+v, want it to contain the body", err.Error())
+	}
+}
+
+func TestRawBodyTruncated(t *testing.T) {
+	body := strings.Repeat("x", 2*maxRawBody)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+
+	err := client.Track("13793", "Signed Up", &Event{IP: "0"})
+	if got := err.(*MixpanelError).RawBody; got != body[:maxRawBody] {
+		t.Errorf("RawBody returned %d bytes, want %d", len(got), maxRawBody)
+	}
+}
+
+func TestErrorSentinels(t *testing.T) {
+	var (
+		status int
+		body   string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+
+	for _, tt := range []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusOK, `{"status":0,"error":"token, missing or empty"}`, ErrInvalidToken},
+		{http.StatusUnauthorized, `{"error":"Invalid credentials"}`, ErrInvalidToken},
+		{http.StatusTooManyRequests, `{"error":"rate limited"}`, ErrRateLimited},
+		{http.StatusRequestEntityTooLarge, `{"error":"request too large"}`, ErrPayloadTooLarge},
+	} {
+		status, body = tt.status, tt.body
+		err := client.Track("13793", "Signed Up", &Event{IP: "0"})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("Track returned %v for %d, want %v", err, tt.status, tt.want)
+		}
+		for _, other := range []error{ErrInvalidToken, ErrRateLimited, ErrPayloadTooLarge} {
+			if other != tt.want && errors.Is(err, other) {
+				t.Errorf("Track returned %v for %d, which matches %v", err, tt.status, other)
+			}
+		}
+
+		var mpErr *MixpanelError
+		if !errors.As(err, &mpErr) {
+			t.Fatalf("Track returned %v, want a *MixpanelError", err)
+		}
+		if got := mpErr.Header.Get("X-RateLimit-Remaining"); got != "0" {
+			t.Errorf("X-RateLimit-Remaining returned %+v, want %+v", got, "0")
+		}
+		if !strings.Contains(string(mpErr.Payload), `"event":"Signed Up"`) {
+			t.Errorf("Payload returned %s, want the tracked event", mpErr.Payload)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	setup()
+	defer teardown()
+
+	at := time.Now().Add(-time.Hour)
+	client.Import("13793", "Signed Up", &Event{Timestamp: &at})
+
+	if LastRequest.URL.Path != "/import" {
+		t.Errorf("path returned %+v, want %+v", LastRequest.URL.Path, "/import")
+	}
+}
+
+func TestImportWithoutTimestamp(t *testing.T) {
+	setup()
+	defer teardown()
+
+	now := time.Now().Truncate(time.Millisecond)
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithClock(fixedClock(now)))
+	client.Import("13793", "Signed Up", &Event{})
+
+	var e struct {
+		Properties struct {
+			Time int64 `json:"time"`
+		} `json:"properties"`
+	}
+	json.Unmarshal([]byte(decodeData(LastRequest)), &e)
+	if want := now.UnixNano() / int64(time.Millisecond); e.Properties.Time != want {
+		t.Errorf("time returned %+v, want %+v", e.Properties.Time, want)
+	}
+}
+
+func TestWithImportThreshold(t *testing.T) {
+	setup()
+	defer teardown()
+
+	old := time.Now().Add(-2 * time.Hour)
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithImportThreshold(time.Hour))
+	client.Track("13793", "Signed Up", &Event{Timestamp: &old})
+	if LastRequest.URL.Path != "/import" {
+		t.Errorf("path returned %+v, want %+v", LastRequest.URL.Path, "/import")
+	}
+
+	older := time.Now().Add(-30 * 24 * time.Hour)
+
+	client = New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithImportThreshold(0))
+	client.Track("13793", "Signed Up", &Event{Timestamp: &older})
+	if LastRequest.URL.Path != "/track" {
+		t.Errorf("path with no threshold returned %+v, want %+v", LastRequest.URL.Path, "/track")
+	}
+}
+
+func TestNewWithOptions(t *testing.T) {
+	setup()
+	defer teardown()
+
+	httpClient := &http.Client{}
+	client := NewWithOptions("e3bc4100330c35722740fb8c6f5abddc",
+		WithRegion(EU),
+		WithAPIURL(ts.URL),
+		WithAPIKey("key"),
+		WithAPISecret("secret"),
+		WithHTTPClient(httpClient))
+
+	m := client.(*mixpanel)
+	if m.ApiURL != ts.URL || m.ApiKey != "key" || m.ApiSecret != "secret" || m.Client != httpClient {
+		t.Errorf("NewWithOptions returned %+v, want the given settings", m)
+	}
+
+	client.Track("13793", "Signed Up", &Event{})
+	if user, _, _ := LastRequest.BasicAuth(); user != "secret" {
+		t.Errorf("basic auth user returned %+v, want %+v", user, "secret")
+	}
+}
+
+// TestConcurrentClient runs calls sharing their events and options from
+// several goroutines through a client with most options enabled, so that
+// go test -race catches shared state mutated without locking.
+func TestConcurrentClient(t *testing.T) {
+	client, recorded := NewTestClient(
+		WithDefaultProperties(map[string]interface{}{"app": "web"}),
+		WithAutoInsertID(),
+		WithCardinalityWarnings(1, 10),
+		WithErrorRing(10),
+		WithRateLimit(1e6, 1000),
+		WithMaxConcurrentRequests(4),
+		WithCoercions(),
+		WithStrictMode(),
+	)
+
+	at := time.Now().Add(-time.Minute)
+	props := map[string]interface{}{"plan": "pro", "tags": []string{"beta"}}
+	e := &Event{IP: "0", Timestamp: &at, Properties: props}
+	u := &Update{IP: "0", Operation: OpSet, Properties: props}
+	batch := []BatchEvent{{DistinctId: "13793", EventName: "Signed Up", Event: *e}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				client.Track("13793", "Signed Up", e)
+				client.TrackCtx(context.Background(), "13793", "Signed Up", e, InGroup("company", "acme"))
+				client.Update("13793", u)
+				client.Alias("13793", "13794")
+				client.TrackBatch(batch)
+				client.ImportBatch(batch)
+				client.RecentErrors()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(recorded.All()); got != 8*10*6 {
+		t.Errorf("requests returned %+v, want %+v", got, 8*10*6)
+	}
+}
@@ -1,62 +1,1374 @@
 package mixpanel
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
 	"time"
 )
 
 // Mocked version of Mixpanel which can be used in unit tests.
+//
+// Its methods and accessors are safe for concurrent use; reading People and
+// Groups directly is not while calls are in flight.
 type Mock struct {
 	// All People identified, mapped by distinctId
 	People map[string]*MockPeople
+
+	// Properties of all group profiles, mapped by group key and group id
+	Groups map[MockGroup]map[string]interface{}
+
+	// Aliases made with Alias and AliasCtx, in order
+	Aliases []MockAlias
+
+	mu           sync.Mutex
+	lastEndpoint string
+	lastUpdates  map[string]Update
+	calls        map[string]int
+	failures     map[string]error
+	failNext     []error
+	superProps   map[string]interface{}
+	clock        Clock
+}
+
+func NewMock() *Mock {
+	return &Mock{
+		People: map[string]*MockPeople{},
+		Groups: map[MockGroup]map[string]interface{}{},
+	}
+}
+
+// A MockGroup identifies a group profile in Mock.Groups.
+type MockGroup struct {
+	Key, Id string
+}
+
+// A MockAlias records a call to Alias in Mock.Aliases.
+type MockAlias struct {
+	DistinctId string `json:"distinct_id"`
+	NewId      string `json:"new_id"`
+}
+
+// MergeMocks returns a new Mock holding the combined state of mocks, for
+// tests spanning several components that each record into their own Mock.
+// Events of the same distinct id, and aliases, are concatenated in the order
+// of mocks; when several mocks set the same profile property, IP or time, the
+// last one wins. The given mocks are not modified.
+func MergeMocks(mocks ...*Mock) *Mock {
+	merged := NewMock()
+	for _, m := range mocks {
+		m.mu.Lock()
+		for distinctId, p := range m.People {
+			mp := merged.people(distinctId)
+			for key, value := range p.Properties {
+				mp.Properties[key] = value
+			}
+			if p.Time != nil {
+				mp.Time = p.Time
+			}
+			if p.IP != "" {
+				mp.IP = p.IP
+			}
+			mp.Events = append(mp.Events, p.Events...)
+		}
+		for group, props := range m.Groups {
+			mg := merged.group(group)
+			for key, value := range props {
+				mg[key] = value
+			}
+		}
+		merged.Aliases = append(merged.Aliases, m.Aliases...)
+		m.mu.Unlock()
+	}
+	return merged
+}
+
+// String describes the people of the mock, then its aliases if any, sorted
+// by distinct id and property name so that the output can be compared to a
+// golden file.
+func (m *Mock) String() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	str := ""
+	for _, id := range sortedPeople(m.People) {
+		str += id + ":\n" + m.People[id].String()
+	}
+	if len(m.Aliases) > 0 {
+		str += "aliases:\n"
+		for _, a := range m.Aliases {
+			str += fmt.Sprintf("  %s: %s\n", a.DistinctId, a.NewId)
+		}
+	}
+	return str
+}
+
+// DumpJSON returns the people, groups and aliases of the mock as indented
+// JSON, with object keys sorted and groups sorted by key and id, so that the
+// output can be compared to a golden file.
+func (m *Mock) DumpJSON() ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	type dumpedEvent struct {
+		Name       string                 `json:"name"`
+		Endpoint   string                 `json:"endpoint"`
+		IP         string                 `json:"ip,omitempty"`
+		Timestamp  *time.Time             `json:"timestamp,omitempty"`
+		InsertId   string                 `json:"insert_id,omitempty"`
+		Properties map[string]interface{} `json:"properties,omitempty"`
+	}
+	type dumpedPeople struct {
+		IP         string                 `json:"ip,omitempty"`
+		Time       *time.Time             `json:"time,omitempty"`
+		Properties map[string]interface{} `json:"properties"`
+		Events     []dumpedEvent          `json:"events"`
+	}
+	type dumpedGroup struct {
+		Key        string                 `json:"key"`
+		Id         string                 `json:"id"`
+		Properties map[string]interface{} `json:"properties"`
+	}
+	dump := struct {
+		People  map[string]dumpedPeople `json:"people"`
+		Groups  []dumpedGroup           `json:"groups"`
+		Aliases []MockAlias             `json:"aliases"`
+	}{
+		People:  make(map[string]dumpedPeople, len(m.People)),
+		Groups:  make([]dumpedGroup, 0, len(m.Groups)),
+		Aliases: append([]MockAlias{}, m.Aliases...),
+	}
+
+	for id, p := range m.People {
+		events := make([]dumpedEvent, 0, len(p.Events))
+		for _, e := range p.Events {
+			events = append(events, dumpedEvent{
+				Name:       e.Name,
+				Endpoint:   e.Endpoint,
+				IP:         e.IP,
+				Timestamp:  e.Timestamp,
+				InsertId:   e.InsertId,
+				Properties: e.Properties,
+			})
+		}
+		dump.People[id] = dumpedPeople{IP: p.IP, Time: p.Time, Properties: p.Properties, Events: events}
+	}
+	for group, props := range m.Groups {
+		dump.Groups = append(dump.Groups, dumpedGroup{Key: group.Key, Id: group.Id, Properties: props})
+	}
+	sort.Slice(dump.Groups, func(i, j int) bool {
+		if dump.Groups[i].Key != dump.Groups[j].Key {
+			return dump.Groups[i].Key < dump.Groups[j].Key
+		}
+		return dump.Groups[i].Id < dump.Groups[j].Id
+	})
+
+	return json.MarshalIndent(dump, "", "  ")
+}
+
+// sortedPeople returns the distinct ids of people, sorted.
+func sortedPeople(people map[string]*MockPeople) []string {
+	ids := make([]string, 0, len(people))
+	for id := range people {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// sortedProperties returns the names of props, sorted.
+func sortedProperties(props map[string]interface{}) []string {
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FailNext makes the next call to a method of the client, such as Track or
+// Update, fail with err without recording anything. Calling it several times
+// queues errors for the calls that follow, in order. The accessors, such as
+// Events and Calls, never fail.
+func (m *Mock) FailNext(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failNext = append(m.failNext, err)
+}
+
+// FailOn makes every call to the named method, such as "Track" or
+// "UpdateCtx", fail with err without recording anything, until FailOn is
+// called again for the method with a nil err. Errors queued by FailNext are
+// returned first.
+func (m *Mock) FailOn(method string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.failures == nil {
+		m.failures = map[string]error{}
+	}
+	if err == nil {
+		delete(m.failures, method)
+		return
+	}
+	m.failures[method] = err
+}
+
+// SetClock makes the mock read the current time from c instead of the system
+// clock, like a client given WithClock: to route events older than the import
+// threshold to "import" and to stamp the transactions of TrackCharge.
+func (m *Mock) SetClock(c Clock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clock = c
+}
+
+// now returns the current time, from the Clock given to SetClock if any.
+func (m *Mock) now() time.Time {
+	if m.clock != nil {
+		return m.clock.Now()
+	}
+	return time.Now()
+}
+
+// Calls returns the number of calls made to the named method, such as
+// "Track", including the calls that failed. Each method is counted under its
+// own name: a TrackCtx call is not counted as a Track call.
+func (m *Mock) Calls(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.calls[method]
+}
+
+// begin counts a call to method and locks the mock for it. It returns the
+// error injected for the call by FailNext or FailOn, if any, in which case
+// the mock is left unlocked; otherwise the caller must unlock it.
+func (m *Mock) begin(method string) error {
+	m.mu.Lock()
+
+	if m.calls == nil {
+		m.calls = map[string]int{}
+	}
+	m.calls[method]++
+
+	var err error
+	if len(m.failNext) > 0 {
+		err, m.failNext = m.failNext[0], m.failNext[1:]
+	} else {
+		err = m.failures[method]
+	}
+	if err != nil {
+		m.mu.Unlock()
+	}
+	return err
+}
+
+// Identifies a user. The user will be added to the People map.
+func (m *Mock) people(distinctId string) *MockPeople {
+	p := m.People[distinctId]
+	if p == nil {
+		p = &MockPeople{
+			Properties: map[string]interface{}{},
+		}
+		m.People[distinctId] = p
+	}
+
+	return p
+}
+
+func (m *Mock) group(group MockGroup) map[string]interface{} {
+	props := m.Groups[group]
+	if props == nil {
+		props = map[string]interface{}{}
+		m.Groups[group] = props
+	}
+
+	return props
+}
+
+// Events returns the events tracked or imported for distinctId, in order, or
+// nil if there are none.
+func (m *Mock) Events(distinctId string) []MockEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.People[distinctId]
+	if p == nil || len(p.Events) == 0 {
+		return nil
+	}
+	return append([]MockEvent{}, p.Events...)
+}
+
+// Profile returns a copy of the recorded state of the profile of distinctId,
+// or nil if nothing was recorded for it.
+func (m *Mock) Profile(distinctId string) *MockPeople {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.People[distinctId]
+	if p == nil {
+		return nil
+	}
+	return &MockPeople{
+		Properties: copyMap(p.Properties),
+		Time:       p.Time,
+		IP:         p.IP,
+		Events:     append([]MockEvent(nil), p.Events...),
+	}
+}
+
+// LastUpdate returns the last profile update applied to distinctId, by Update
+// or by any of the methods updating profiles, such as PeopleSet or
+// Increment, or nil if there was none.
+func (m *Mock) LastUpdate(distinctId string) *Update {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.lastUpdates[distinctId]
+	if !ok {
+		return nil
+	}
+	return &u
+}
+
+// LastEndpoint returns the endpoint the last call would have been sent to by
+// the real client, such as "track", "import" or "engage", or "" if there was
+// no call yet. Tracked events are routed exactly as by the real client.
+func (m *Mock) LastEndpoint() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.lastEndpoint
+}
+
+// recordUpdate records u as the last update of distinctId.
+func (m *Mock) recordUpdate(distinctId string, u *Update) {
+	if m.lastUpdates == nil {
+		m.lastUpdates = map[string]Update{}
+	}
+	recorded := *u
+	recorded.Properties = copyMap(u.Properties)
+	m.lastUpdates[distinctId] = recorded
+}
+
+func (m *Mock) Track(distinctId, eventName string, e *Event, opts ...CallOption) error {
+	if err := m.begin("Track"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	m.track(distinctId, eventName, e, opts...)
+	return nil
+}
+
+func (m *Mock) track(distinctId, eventName string, e *Event, opts ...CallOption) {
+	call := newCallOptions(opts)
+	e = withGroups(e, call)
+	e = m.withSuperProperties(e)
+	endpoint, _ := routeEvent(e, call, importThreshold, m.now())
+	m.lastEndpoint = endpoint
+
+	p := m.people(distinctId)
+	p.Events = append(p.Events, MockEvent{
+		Event:    *e,
+		Name:     eventName,
+		Endpoint: endpoint,
+	})
+}
+
+func (m *Mock) TrackCtx(ctx context.Context, distinctId, eventName string, e *Event, opts ...CallOption) error {
+	if err := m.begin("TrackCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.track(distinctId, eventName, e, opts...)
+	return nil
+}
+
+func (m *Mock) Import(distinctId, eventName string, e *Event, opts ...CallOption) error {
+	if err := m.begin("Import"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	m.importEvent(distinctId, eventName, e)
+	return nil
+}
+
+func (m *Mock) ImportCtx(ctx context.Context, distinctId, eventName string, e *Event, opts ...CallOption) error {
+	if err := m.begin("ImportCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.importEvent(distinctId, eventName, e)
+	return nil
+}
+
+func (m *Mock) importEvent(distinctId, eventName string, e *Event) {
+	m.lastEndpoint = "import"
+	e = m.withSuperProperties(e)
+
+	p := m.people(distinctId)
+	p.Events = append(p.Events, MockEvent{
+		Event:    *e,
+		Name:     eventName,
+		Endpoint: "import",
+	})
+}
+
+// RegisterSuperProperties adds props to the properties of the events recorded
+// afterwards. Properties of the events win over them.
+func (m *Mock) RegisterSuperProperties(props map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.superProps == nil {
+		m.superProps = map[string]interface{}{}
+	}
+	for key, value := range props {
+		m.superProps[key] = value
+	}
+}
+
+func (m *Mock) UnregisterSuperProperty(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.superProps, key)
+}
+
+// withSuperProperties returns e with the super properties added, or e itself
+// if there are none.
+func (m *Mock) withSuperProperties(e *Event) *Event {
+	if len(m.superProps) == 0 {
+		return e
+	}
+
+	merged := copyMap(m.superProps)
+	for key, value := range e.Properties {
+		merged[key] = value
+	}
+	withSuper := *e
+	withSuper.Properties = merged
+	return &withSuper
+}
+
+// Deprecated: Use Identify, which takes the ids the other way around.
+func (m *Mock) CreateIdentity(identifiedId, anonId string) error {
+	if err := m.begin("CreateIdentity"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	m.lastEndpoint = "track"
+	return nil
+}
+
+func (m *Mock) Identify(anonId, identifiedId string, opts ...CallOption) error {
+	if err := m.begin("Identify"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	m.lastEndpoint = "track"
+	return nil
+}
+
+func (m *Mock) IdentifyCtx(ctx context.Context, anonId, identifiedId string, opts ...CallOption) error {
+	if err := m.begin("IdentifyCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.lastEndpoint = "track"
+	return nil
+}
+
+func (m *Mock) ImportNDJSON(events []BatchEvent, opts ...CallOption) error {
+	if err := m.begin("ImportNDJSON"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	m.importEvents(events)
+	return nil
+}
+
+func (m *Mock) ImportNDJSONCtx(ctx context.Context, events []BatchEvent, opts ...CallOption) error {
+	if err := m.begin("ImportNDJSONCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.importEvents(events)
+	return nil
+}
+
+func (m *Mock) importEvents(events []BatchEvent) {
+	for i := range events {
+		m.importEvent(events[i].DistinctId, events[i].EventName, &events[i].Event)
+	}
+}
+
+func (m *Mock) ImportBatch(events []BatchEvent, opts ...CallOption) error {
+	if err := m.begin("ImportBatch"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	m.importEvents(events)
+	return nil
+}
+
+func (m *Mock) ImportBatchCtx(ctx context.Context, events []BatchEvent, opts ...CallOption) error {
+	if err := m.begin("ImportBatchCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.importEvents(events)
+	return nil
+}
+
+// TrackBatch records the events as tracked to /track, whatever their age.
+func (m *Mock) TrackBatch(events []BatchEvent, opts ...CallOption) error {
+	if err := m.begin("TrackBatch"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return m.trackBatch(events)
+}
+
+func (m *Mock) trackBatch(events []BatchEvent) error {
+	for i := range events {
+		m.track(events[i].DistinctId, events[i].EventName, &events[i].Event, ForceEndpoint(EndpointTrack))
+	}
+	return nil
+}
+
+func (m *Mock) TrackBatchCtx(ctx context.Context, events []BatchEvent, opts ...CallOption) error {
+	if err := m.begin("TrackBatchCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.trackBatch(events)
+}
+
+func (m *Mock) ReconcileCohorts(distinctId string, desired []string) error {
+	if err := m.begin("ReconcileCohorts"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	p := m.people(distinctId)
+	p.Properties[DefaultCohortProperty] = append([]string{}, desired...)
+	return nil
+}
+
+func (m *Mock) ReconcileCohortsCtx(ctx context.Context, distinctId string, desired []string) error {
+	if err := m.begin("ReconcileCohortsCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p := m.people(distinctId)
+	p.Properties[DefaultCohortProperty] = append([]string{}, desired...)
+	return nil
+}
+
+// Export returns the events tracked so far whose name is in p.Events, or all of
+// them if p.Events is empty. The other export parameters are ignored.
+func (m *Mock) Export(p ExportParams) ([]ExportedEvent, error) {
+	if err := m.begin("Export"); err != nil {
+		return nil, err
+	}
+	defer m.mu.Unlock()
+
+	return m.export(p), nil
+}
+
+func (m *Mock) export(p ExportParams) []ExportedEvent {
+	names := map[string]bool{}
+	for _, name := range p.Events {
+		names[name] = true
+	}
+
+	var events []ExportedEvent
+	for distinctId, people := range m.People {
+		for _, e := range people.Events {
+			if len(names) > 0 && !names[e.Name] {
+				continue
+			}
+			props := map[string]interface{}{"distinct_id": distinctId}
+			for key, value := range e.Properties {
+				props[key] = value
+			}
+			events = append(events, ExportedEvent{Event: e.Name, Properties: props})
+		}
+	}
+	return events
+}
+
+func (m *Mock) EnsureCreated(distinctId string, t time.Time) error {
+	if err := m.begin("EnsureCreated"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return m.update(distinctId, createdUpdate(t))
+}
+
+func (m *Mock) EnsureCreatedCtx(ctx context.Context, distinctId string, t time.Time) error {
+	if err := m.begin("EnsureCreatedCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.update(distinctId, createdUpdate(t))
+}
+
+// Increment adds by to the property of the profile, which must be a float64
+// if it is set.
+func (m *Mock) Increment(distinctId, property string, by float64) error {
+	if err := m.begin("Increment"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	m.increment(distinctId, property, by)
+	return nil
+}
+
+func (m *Mock) IncrementCtx(ctx context.Context, distinctId, property string, by float64) error {
+	if err := m.begin("IncrementCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.increment(distinctId, property, by)
+	return nil
+}
+
+func (m *Mock) increment(distinctId, property string, by float64) {
+	m.lastEndpoint = "engage"
+
+	p := m.people(distinctId)
+	current, _ := p.Properties[property].(float64)
+	p.Properties[property] = current + by
+	m.recordUpdate(distinctId, incrementUpdate(property, by))
+}
+
+func (m *Mock) PeopleSet(distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	if err := m.begin("PeopleSet"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return m.update(distinctId, &Update{Operation: OpSet, Properties: props})
+}
+
+func (m *Mock) PeopleSetCtx(ctx context.Context, distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	if err := m.begin("PeopleSetCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.update(distinctId, &Update{Operation: OpSet, Properties: props})
+}
+
+func (m *Mock) PeopleIncrement(distinctId string, by map[string]float64, opts ...CallOption) error {
+	if err := m.begin("PeopleIncrement"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return m.peopleIncrement(distinctId, by)
+}
+
+func (m *Mock) peopleIncrement(distinctId string, by map[string]float64) error {
+	props := make(map[string]interface{}, len(by))
+	for property, amount := range by {
+		props[property] = amount
+	}
+	if err := checkOperationValues(OpAdd, props); err != nil {
+		return err
+	}
+
+	for property, amount := range by {
+		m.increment(distinctId, property, amount)
+	}
+	if len(by) > 0 {
+		m.recordUpdate(distinctId, &Update{Operation: OpAdd, Properties: props})
+	}
+	return nil
+}
+
+func (m *Mock) PeopleIncrementCtx(ctx context.Context, distinctId string, by map[string]float64, opts ...CallOption) error {
+	if err := m.begin("PeopleIncrementCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.peopleIncrement(distinctId, by)
+}
+
+// PeopleAppend appends the values of props to the list properties of the
+// profile.
+func (m *Mock) PeopleAppend(distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	if err := m.begin("PeopleAppend"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return m.peopleAppend(distinctId, props)
+}
+
+func (m *Mock) peopleAppend(distinctId string, props map[string]interface{}) error {
+	if err := checkOperationValues(OpAppend, props); err != nil {
+		return err
+	}
+	m.lastEndpoint = "engage"
+
+	p := m.people(distinctId)
+	for key, value := range props {
+		list, _ := p.Properties[key].([]interface{})
+		p.Properties[key] = append(list, value)
+	}
+	m.recordUpdate(distinctId, &Update{Operation: OpAppend, Properties: props})
+	return nil
+}
+
+func (m *Mock) PeopleAppendCtx(ctx context.Context, distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	if err := m.begin("PeopleAppendCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.peopleAppend(distinctId, props)
+}
+
+// PeopleUnion adds the values of lists missing from the list properties of
+// the profile.
+func (m *Mock) PeopleUnion(distinctId string, lists map[string][]interface{}, opts ...CallOption) error {
+	if err := m.begin("PeopleUnion"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return m.peopleUnion(distinctId, lists)
+}
+
+func (m *Mock) peopleUnion(distinctId string, lists map[string][]interface{}) error {
+	props := make(map[string]interface{}, len(lists))
+	for key, values := range lists {
+		props[key] = values
+	}
+	if err := checkOperationValues(OpUnion, props); err != nil {
+		return err
+	}
+	m.lastEndpoint = "engage"
+
+	p := m.people(distinctId)
+	for key, values := range lists {
+		list, _ := p.Properties[key].([]interface{})
+		for _, value := range values {
+			if indexOf(list, value) < 0 {
+				list = append(list, value)
+			}
+		}
+		p.Properties[key] = list
+	}
+	m.recordUpdate(distinctId, &Update{Operation: OpUnion, Properties: props})
+	return nil
+}
+
+func (m *Mock) PeopleUnionCtx(ctx context.Context, distinctId string, lists map[string][]interface{}, opts ...CallOption) error {
+	if err := m.begin("PeopleUnionCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.peopleUnion(distinctId, lists)
+}
+
+// PeopleRemove removes the values of props from the list properties of the
+// profile.
+func (m *Mock) PeopleRemove(distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	if err := m.begin("PeopleRemove"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return m.peopleRemove(distinctId, props)
+}
+
+func (m *Mock) peopleRemove(distinctId string, props map[string]interface{}) error {
+	if err := checkOperationValues(OpRemove, props); err != nil {
+		return err
+	}
+	m.lastEndpoint = "engage"
+
+	p := m.people(distinctId)
+	for key, value := range props {
+		list, _ := p.Properties[key].([]interface{})
+		if i := indexOf(list, value); i >= 0 {
+			p.Properties[key] = append(list[:i:i], list[i+1:]...)
+		}
+	}
+	m.recordUpdate(distinctId, &Update{Operation: OpRemove, Properties: props})
+	return nil
+}
+
+func (m *Mock) PeopleRemoveCtx(ctx context.Context, distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	if err := m.begin("PeopleRemoveCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.peopleRemove(distinctId, props)
+}
+
+func indexOf(list []interface{}, value interface{}) int {
+	for i, v := range list {
+		if reflect.DeepEqual(v, value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// PeopleDelete removes the profile and the events of distinctId.
+func (m *Mock) PeopleDelete(distinctId string, opts ...CallOption) error {
+	if err := m.begin("PeopleDelete"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return m.peopleDelete(distinctId)
+}
+
+func (m *Mock) peopleDelete(distinctId string) error {
+	m.lastEndpoint = "engage"
+	delete(m.People, distinctId)
+	delete(m.lastUpdates, distinctId)
+	return nil
+}
+
+func (m *Mock) PeopleDeleteCtx(ctx context.Context, distinctId string, opts ...CallOption) error {
+	if err := m.begin("PeopleDeleteCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.peopleDelete(distinctId)
+}
+
+// TrackCharge appends the transaction to the $transactions property of the
+// profile.
+func (m *Mock) TrackCharge(distinctId string, amount float64, properties map[string]interface{}) error {
+	if err := m.begin("TrackCharge"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return m.trackCharge(distinctId, amount, properties)
+}
+
+func (m *Mock) trackCharge(distinctId string, amount float64, properties map[string]interface{}) error {
+	m.lastEndpoint = "engage"
+
+	u := chargeUpdate(amount, properties, m.now())
+	p := m.people(distinctId)
+	transactions, _ := p.Properties["$transactions"].([]interface{})
+	p.Properties["$transactions"] = append(transactions, u.Properties["$transactions"])
+	m.recordUpdate(distinctId, u)
+	return nil
 }
 
-func NewMock() *Mock {
-	return &Mock{
-		People: map[string]*MockPeople{},
+func (m *Mock) TrackChargeCtx(ctx context.Context, distinctId string, amount float64, properties map[string]interface{}) error {
+	if err := m.begin("TrackChargeCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
 	}
+	return m.trackCharge(distinctId, amount, properties)
 }
 
-func (m *Mock) String() string {
-	str := ""
-	for id, p := range m.People {
-		str += id + ":\n" + p.String()
+// ClearCharges empties the $transactions property of the profile.
+func (m *Mock) ClearCharges(distinctId string) error {
+	if err := m.begin("ClearCharges"); err != nil {
+		return err
 	}
-	return str
+	defer m.mu.Unlock()
+
+	return m.update(distinctId, clearChargesUpdate())
 }
 
-// Identifies a user. The user will be added to the People map.
-func (m *Mock) people(distinctId string) *MockPeople {
-	p := m.People[distinctId]
-	if p == nil {
-		p = &MockPeople{
-			Properties: map[string]interface{}{},
+func (m *Mock) ClearChargesCtx(ctx context.Context, distinctId string) error {
+	if err := m.begin("ClearChargesCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.update(distinctId, clearChargesUpdate())
+}
+
+// GroupUpdate applies the $set, $set_once and $unset operations to the
+// properties of the group profile.
+func (m *Mock) GroupUpdate(groupKey, groupID string, g *GroupUpdateRequest, opts ...CallOption) error {
+	if err := m.begin("GroupUpdate"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return m.groupUpdate(groupKey, groupID, g)
+}
+
+func (m *Mock) groupUpdate(groupKey, groupID string, g *GroupUpdateRequest) error {
+	m.lastEndpoint = "groups"
+
+	props := m.group(MockGroup{Key: groupKey, Id: groupID})
+
+	switch g.Operation {
+	case "$set":
+		for key, val := range g.Properties {
+			props[key] = val
 		}
-		m.People[distinctId] = p
+	case "$set_once":
+		for key, val := range g.Properties {
+			if _, ok := props[key]; !ok {
+				props[key] = val
+			}
+		}
+	case "$unset":
+		for _, key := range g.Unset {
+			delete(props, key)
+		}
+	default:
+		return errors.New("mixpanel.Mock only supports the $set, $set_once and $unset group operations")
 	}
+	return nil
+}
 
-	return p
+func (m *Mock) GroupUpdateCtx(ctx context.Context, groupKey, groupID string, g *GroupUpdateRequest, opts ...CallOption) error {
+	if err := m.begin("GroupUpdateCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.groupUpdate(groupKey, groupID, g)
 }
 
-func (m *Mock) Track(distinctId, eventName string, e *Event) error {
-	p := m.people(distinctId)
-	p.Events = append(p.Events, MockEvent{
-		Event: *e,
-		Name:  eventName,
-	})
+func (m *Mock) GroupDelete(groupKey, groupID string, opts ...CallOption) error {
+	if err := m.begin("GroupDelete"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	m.lastEndpoint = "groups"
+	delete(m.Groups, MockGroup{Key: groupKey, Id: groupID})
 	return nil
 }
 
-func (m *Mock) Import(distinctId, eventName string, e *Event) error {
-	p := m.people(distinctId)
-	p.Events = append(p.Events, MockEvent{
-		Event: *e,
-		Name:  eventName,
+func (m *Mock) GroupDeleteCtx(ctx context.Context, groupKey, groupID string, opts ...CallOption) error {
+	if err := m.begin("GroupDeleteCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.lastEndpoint = "groups"
+	delete(m.Groups, MockGroup{Key: groupKey, Id: groupID})
+	return nil
+}
+
+func (m *Mock) Bump(distinctId, property string) error {
+	if err := m.begin("Bump"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	m.increment(distinctId, property, 1)
+	return nil
+}
+
+func (m *Mock) BumpCtx(ctx context.Context, distinctId, property string) error {
+	if err := m.begin("BumpCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.increment(distinctId, property, 1)
+	return nil
+}
+
+func (m *Mock) UpdateBatch(updates []BatchUpdate, opts ...CallOption) error {
+	if err := m.begin("UpdateBatch"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return m.updateBatch(updates)
+}
+
+func (m *Mock) updateBatch(updates []BatchUpdate) error {
+	for i := range updates {
+		u := updates[i].Update
+		if u.Operation != "" {
+			if err := m.update(updates[i].DistinctId, &u); err != nil {
+				return err
+			}
+		}
+		for op, value := range updates[i].Operations {
+			props, _ := value.(map[string]interface{})
+			err := m.update(updates[i].DistinctId, &Update{
+				IP:         u.IP,
+				Timestamp:  u.Timestamp,
+				Operation:  op,
+				Properties: props,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ExportEach calls fn for each event Export returns for p.
+func (m *Mock) ExportEach(ctx context.Context, p ExportParams, fn func(ExportedEvent) error) error {
+	if err := m.begin("ExportEach"); err != nil {
+		return err
+	}
+	events := m.export(p)
+	m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, e := range events {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyEvents imports the events Export returns for from into dest.
+func (m *Mock) CopyEvents(ctx context.Context, from ExportParams, dest Mixpanel) error {
+	if err := m.begin("CopyEvents"); err != nil {
+		return err
+	}
+	events := m.export(from)
+	m.mu.Unlock()
+
+	return copyEvents(ctx, dest, func(fn func(ExportedEvent) error) error {
+		for _, e := range events {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
+}
+
+// QueryProfiles returns the profiles of the mock in a single page, sorted by
+// distinct id. It supports DistinctIds and OutputProperties, but not Where.
+func (m *Mock) QueryProfiles(ctx context.Context, q ProfileQuery) (*ProfilePage, error) {
+	if err := m.begin("QueryProfiles"); err != nil {
+		return nil, err
+	}
+	defer m.mu.Unlock()
+
+	return m.queryProfiles(q)
+}
+
+func (m *Mock) queryProfiles(q ProfileQuery) (*ProfilePage, error) {
+	if q.Where != "" {
+		return nil, errors.New("mixpanel.Mock does not support Where in QueryProfiles")
+	}
+	ids := append([]string(nil), q.DistinctIds...)
+	if len(ids) == 0 {
+		for distinctId := range m.People {
+			ids = append(ids, distinctId)
+		}
+	}
+	sort.Strings(ids)
+
+	page := &ProfilePage{}
+	for _, distinctId := range ids {
+		p, ok := m.People[distinctId]
+		if !ok {
+			continue
+		}
+		props := copyMap(p.Properties)
+		if len(q.OutputProperties) > 0 {
+			props = map[string]interface{}{}
+			for _, key := range q.OutputProperties {
+				if value, ok := p.Properties[key]; ok {
+					props[key] = value
+				}
+			}
+		}
+		page.Profiles = append(page.Profiles, Profile{DistinctId: distinctId, Properties: props})
+	}
+	page.PageSize = len(page.Profiles)
+	page.Total = len(page.Profiles)
+
+	return page, nil
+}
+
+// EachProfile calls fn for each profile QueryProfiles returns for q.
+func (m *Mock) EachProfile(ctx context.Context, q ProfileQuery, fn func(Profile) error) error {
+	if err := m.begin("EachProfile"); err != nil {
+		return err
+	}
+	page, err := m.queryProfiles(q)
+	m.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for _, profile := range page.Profiles {
+		if err := fn(profile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Mock) UnsetPropertyWhere(ctx context.Context, where string, names []string) (int, error) {
+	if err := m.begin("UnsetPropertyWhere"); err != nil {
+		return 0, err
+	}
+	defer m.mu.Unlock()
+
+	return 0, errors.New("mixpanel.Mock does not support UnsetPropertyWhere")
+}
+
+func (m *Mock) Flows(event string, from, to time.Time, opts FlowsOpts) (*FlowsResult, error) {
+	if err := m.begin("Flows"); err != nil {
+		return nil, err
+	}
+	defer m.mu.Unlock()
+
+	return nil, errors.New("mixpanel.Mock does not support Flows")
+}
+
+func (m *Mock) FlowsCtx(ctx context.Context, event string, from, to time.Time, opts FlowsOpts) (*FlowsResult, error) {
+	if err := m.begin("FlowsCtx"); err != nil {
+		return nil, err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("mixpanel.Mock does not support FlowsCtx")
+}
+
+func (m *Mock) StreamJQL(ctx context.Context, script string, params interface{}, w io.Writer) error {
+	if err := m.begin("StreamJQL"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return errors.New("mixpanel.Mock does not support StreamJQL")
+}
+
+func (m *Mock) ValidateRegion(ctx context.Context) error {
+	if err := m.begin("ValidateRegion"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return nil
+}
+
+func (m *Mock) ConnectorStatus(connectorId string) (*ConnectorStatus, error) {
+	if err := m.begin("ConnectorStatus"); err != nil {
+		return nil, err
+	}
+	defer m.mu.Unlock()
+
+	return nil, errors.New("mixpanel.Mock does not support ConnectorStatus")
+}
+
+func (m *Mock) ConnectorStatusCtx(ctx context.Context, connectorId string) (*ConnectorStatus, error) {
+	if err := m.begin("ConnectorStatusCtx"); err != nil {
+		return nil, err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("mixpanel.Mock does not support ConnectorStatusCtx")
+}
+
+func (m *Mock) UploadSchemas(ctx context.Context, entries []SchemaEntry) error {
+	if err := m.begin("UploadSchemas"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return errors.New("mixpanel.Mock does not support UploadSchemas")
+}
+
+func (m *Mock) ListSchemas(ctx context.Context, entityType string) ([]SchemaEntry, error) {
+	if err := m.begin("ListSchemas"); err != nil {
+		return nil, err
+	}
+	defer m.mu.Unlock()
+
+	return nil, errors.New("mixpanel.Mock does not support ListSchemas")
+}
+
+func (m *Mock) CreateAnnotation(ctx context.Context, a Annotation) (*Annotation, error) {
+	if err := m.begin("CreateAnnotation"); err != nil {
+		return nil, err
+	}
+	defer m.mu.Unlock()
+
+	return nil, errors.New("mixpanel.Mock does not support CreateAnnotation")
+}
+
+func (m *Mock) ListAnnotations(ctx context.Context, from, to time.Time) ([]Annotation, error) {
+	if err := m.begin("ListAnnotations"); err != nil {
+		return nil, err
+	}
+	defer m.mu.Unlock()
+
+	return nil, errors.New("mixpanel.Mock does not support ListAnnotations")
+}
+
+func (m *Mock) CreatePipeline(p PipelineParams) ([]string, error) {
+	if err := m.begin("CreatePipeline"); err != nil {
+		return nil, err
+	}
+	defer m.mu.Unlock()
+
+	return nil, nil
+}
+
+func (m *Mock) CreatePipelineCtx(ctx context.Context, p PipelineParams) ([]string, error) {
+	if err := m.begin("CreatePipelineCtx"); err != nil {
+		return nil, err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (m *Mock) PipelineStatus(name string) ([]PipelineRun, error) {
+	if err := m.begin("PipelineStatus"); err != nil {
+		return nil, err
+	}
+	defer m.mu.Unlock()
+
+	return nil, nil
+}
+
+func (m *Mock) PipelineStatusCtx(ctx context.Context, name string) ([]PipelineRun, error) {
+	if err := m.begin("PipelineStatusCtx"); err != nil {
+		return nil, err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// RecentErrors returns nil, since the Mock sends no requests; failures given
+// to FailNext and FailOn are only returned to the caller.
+func (m *Mock) RecentErrors() []FailedRequest {
 	return nil
 }
 
+// Health reports a closed circuit breaker: the Mock has none.
+func (m *Mock) Health() Health {
+	return Health{State: BreakerClosed}
+}
+
 type MockPeople struct {
 	Properties map[string]interface{}
 	Time       *time.Time
@@ -72,8 +1384,8 @@ func (mp *MockPeople) String() string {
 
 	str := fmt.Sprintf("  ip: %s\n  time: %s\n", mp.IP, timeStr)
 	str += "  properties:\n"
-	for key, val := range mp.Properties {
-		str += fmt.Sprintf("    %s: %v\n", key, val)
+	for _, key := range sortedProperties(mp.Properties) {
+		str += fmt.Sprintf("    %s: %v\n", key, mp.Properties[key])
 	}
 	str += "  events:\n"
 	for _, event := range mp.Events {
@@ -86,14 +1398,29 @@ func (mp *MockPeople) String() string {
 		} else {
 			str += "      Timestamp:\n"
 		}
-		for key, val := range event.Properties {
-			str += fmt.Sprintf("      %s: %v\n", key, val)
+		for _, key := range sortedProperties(event.Properties) {
+			str += fmt.Sprintf("      %s: %v\n", key, event.Properties[key])
 		}
 	}
 	return str
 }
 
-func (m *Mock) Update(distinctId string, u *Update) error {
+func (m *Mock) Update(distinctId string, u *Update, opts ...CallOption) error {
+	if err := m.begin("Update"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return m.update(distinctId, u)
+}
+
+func (m *Mock) update(distinctId string, u *Update) error {
+	if u.isEmpty() {
+		return ErrNoProperties
+	}
+
+	m.lastEndpoint = "engage"
+
 	p := m.people(distinctId)
 
 	if u.IP != "" {
@@ -104,22 +1431,187 @@ func (m *Mock) Update(distinctId string, u *Update) error {
 	}
 
 	switch u.Operation {
-	case "$set":
+	case OpSet:
 		for key, val := range u.Properties {
 			p.Properties[key] = val
 		}
+	case OpSetOnce:
+		for key, val := range u.Properties {
+			if _, ok := p.Properties[key]; !ok {
+				p.Properties[key] = val
+			}
+		}
+	case OpUnset:
+		for _, key := range u.Unset {
+			delete(p.Properties, key)
+		}
 	default:
-		return errors.New("mixpanel.Mock only supports the $set operation")
+		return errors.New("mixpanel.Mock only supports the $set, $set_once and $unset operations")
+	}
+
+	m.recordUpdate(distinctId, u)
+	return nil
+}
+
+func (m *Mock) SetOnce(distinctId string, props map[string]interface{}) error {
+	if err := m.begin("SetOnce"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return m.update(distinctId, &Update{Operation: OpSetOnce, Properties: props})
+}
+
+func (m *Mock) SetOnceCtx(ctx context.Context, distinctId string, props map[string]interface{}) error {
+	if err := m.begin("SetOnceCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.update(distinctId, &Update{Operation: OpSetOnce, Properties: props})
+}
+
+func (m *Mock) Unset(distinctId string, keys []string) error {
+	if err := m.begin("Unset"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return m.update(distinctId, &Update{Operation: OpUnset, Unset: keys})
+}
+
+func (m *Mock) UnsetCtx(ctx context.Context, distinctId string, keys []string) error {
+	if err := m.begin("UnsetCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.update(distinctId, &Update{Operation: OpUnset, Unset: keys})
+}
+
+func (m *Mock) UpdateCtx(ctx context.Context, distinctId string, u *Update, opts ...CallOption) error {
+	if err := m.begin("UpdateCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.update(distinctId, u)
+}
+
+func (m *Mock) AliasCtx(ctx context.Context, distinctId, newId string, opts ...CallOption) error {
+	if err := m.begin("AliasCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.alias(distinctId, newId)
+}
+
+func (m *Mock) Alias(distinctId, newId string, opts ...CallOption) error {
+	if err := m.begin("Alias"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	return m.alias(distinctId, newId)
+}
+
+func (m *Mock) alias(distinctId, newId string) error {
+	if distinctId != "" && distinctId == newId {
+		return ErrSelfAlias
+	}
+	m.Aliases = append(m.Aliases, MockAlias{DistinctId: distinctId, NewId: newId})
+	m.lastEndpoint = "track"
+	return nil
+}
+
+func (m *Mock) Merge(distinctIds []string, opts ...CallOption) error {
+	if err := m.begin("Merge"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	m.lastEndpoint = "import"
+	return nil
+}
+
+func (m *Mock) MergeCtx(ctx context.Context, distinctIds []string, opts ...CallOption) error {
+	if err := m.begin("MergeCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.lastEndpoint = "import"
+	return nil
+}
+
+func (m *Mock) UpdateBatchCtx(ctx context.Context, updates []BatchUpdate, opts ...CallOption) error {
+	if err := m.begin("UpdateBatchCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.updateBatch(updates)
+}
+
+func (m *Mock) ExportCtx(ctx context.Context, p ExportParams) ([]ExportedEvent, error) {
+	if err := m.begin("ExportCtx"); err != nil {
+		return nil, err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.export(p), nil
+}
+
+func (m *Mock) MergeIdentity(identifiedId, anonId string, opts ...CallOption) error {
+	if err := m.begin("MergeIdentity"); err != nil {
+		return err
 	}
+	defer m.mu.Unlock()
 
+	m.lastEndpoint = "import"
 	return nil
 }
 
-func (m *Mock) Alias(distinctId, newId string) error {
+func (m *Mock) MergeIdentityCtx(ctx context.Context, identifiedId, anonId string, opts ...CallOption) error {
+	if err := m.begin("MergeIdentityCtx"); err != nil {
+		return err
+	}
+	defer m.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.lastEndpoint = "import"
 	return nil
 }
 
 type MockEvent struct {
 	Event
 	Name string
+
+	// Endpoint is the endpoint the real client would have sent the event
+	// to, "track" or "import".
+	Endpoint string
 }
@@ -1,7 +1,12 @@
 package mixpanel
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"sync"
+	"testing"
 	"time"
 )
 
@@ -42,3 +47,415 @@ func ExampleMock() {
 	//       Timestamp:
 	//       from: email
 }
+
+func TestMockEndpoint(t *testing.T) {
+	client := NewMock()
+
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	client.Track("13793", "Signed Up", &Event{Timestamp: &old})
+	if got := client.LastEndpoint(); got != "import" {
+		t.Errorf("LastEndpoint returned %+v, want %+v", got, "import")
+	}
+
+	client.Track("13793", "Viewed Pricing", &Event{})
+	client.Update("13793", &Update{Operation: "$set", Properties: map[string]interface{}{"Plan": "pro"}})
+	if got := client.LastEndpoint(); got != "engage" {
+		t.Errorf("LastEndpoint returned %+v, want %+v", got, "engage")
+	}
+
+	events := client.People["13793"].Events
+	if events[0].Endpoint != "import" || events[1].Endpoint != "track" {
+		t.Errorf("events recorded endpoints %+v and %+v, want import and track", events[0].Endpoint, events[1].Endpoint)
+	}
+}
+
+func TestMergeMocks(t *testing.T) {
+	web := NewMock()
+	web.Track("13793", "Viewed Pricing", &Event{})
+	web.Update("13793", &Update{Operation: "$set", Properties: map[string]interface{}{"plan": "free"}})
+
+	billing := NewMock()
+	billing.Track("13793", "Subscribed", &Event{})
+	billing.Track("13794", "Subscribed", &Event{})
+	billing.Update("13793", &Update{Operation: "$set", Properties: map[string]interface{}{"plan": "pro"}})
+
+	merged := MergeMocks(web, billing)
+
+	p := merged.People["13793"]
+	var names []string
+	for _, e := range p.Events {
+		names = append(names, e.Name)
+	}
+	if want := []string{"Viewed Pricing", "Subscribed"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("merged events returned %+v, want %+v", names, want)
+	}
+	if got := p.Properties["plan"]; got != "pro" {
+		t.Errorf("merged plan returned %+v, want %+v", got, "pro")
+	}
+	if len(merged.People) != 2 {
+		t.Errorf("merged %d people, want 2", len(merged.People))
+	}
+	if len(web.People["13793"].Events) != 1 {
+		t.Errorf("MergeMocks modified its input")
+	}
+}
+
+func TestMockAccessors(t *testing.T) {
+	client := NewMock()
+
+	if client.Events("13793") != nil || client.Profile("13793") != nil {
+		t.Errorf("accessors returned state for an unknown distinct id")
+	}
+
+	client.Track("13793", "Signed Up", &Event{})
+	client.Update("13793", &Update{Operation: "$set", Properties: map[string]interface{}{"plan": "pro"}})
+
+	events := client.Events("13793")
+	if len(events) != 1 || events[0].Name != "Signed Up" {
+		t.Errorf("Events returned %+v", events)
+	}
+	if got := client.Profile("13793").Properties["plan"]; got != "pro" {
+		t.Errorf("Profile plan returned %+v, want %+v", got, "pro")
+	}
+}
+
+func TestMockQueryProfiles(t *testing.T) {
+	m := NewMock()
+	m.PeopleSet("13794", map[string]interface{}{"plan": "free", "$email": "jane@example.com"})
+	m.PeopleSet("13793", map[string]interface{}{"plan": "pro"})
+
+	page, err := m.QueryProfiles(context.Background(), ProfileQuery{OutputProperties: []string{"plan"}})
+	if err != nil {
+		t.Fatalf("QueryProfiles returned %v", err)
+	}
+
+	want := []Profile{
+		{DistinctId: "13793", Properties: map[string]interface{}{"plan": "pro"}},
+		{DistinctId: "13794", Properties: map[string]interface{}{"plan": "free"}},
+	}
+	if !reflect.DeepEqual(page.Profiles, want) {
+		t.Errorf("QueryProfiles returned %+v, want %+v", page.Profiles, want)
+	}
+
+	var ids []string
+	m.EachProfile(context.Background(), ProfileQuery{DistinctIds: []string{"13794", "404"}}, func(p Profile) error {
+		ids = append(ids, p.DistinctId)
+		return nil
+	})
+	if want := []string{"13794"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("EachProfile returned %+v, want %+v", ids, want)
+	}
+
+	if _, err := m.QueryProfiles(context.Background(), ProfileQuery{Where: "true"}); err == nil {
+		t.Errorf("QueryProfiles returned no error for a Where expression")
+	}
+}
+
+func TestMockGroups(t *testing.T) {
+	m := NewMock()
+	group := MockGroup{Key: "company_id", Id: "acme"}
+
+	m.GroupUpdate("company_id", "acme", &GroupUpdateRequest{
+		Operation:  "$set",
+		Properties: map[string]interface{}{"Plan": "pro", "Seats": 10},
+	})
+	m.GroupUpdate("company_id", "acme", &GroupUpdateRequest{
+		Operation: "$unset",
+		Unset:     []string{"Seats"},
+	})
+
+	want := map[string]interface{}{"Plan": "pro"}
+	if !reflect.DeepEqual(m.Groups[group], want) {
+		t.Errorf("Groups returned %+v, want %+v", m.Groups[group], want)
+	}
+	if m.LastEndpoint() != "groups" {
+		t.Errorf("LastEndpoint returned %+v, want %+v", m.LastEndpoint(), "groups")
+	}
+
+	m.GroupDelete("company_id", "acme")
+
+	if _, ok := m.Groups[group]; ok {
+		t.Errorf("Groups returned %+v after GroupDelete, want it removed", m.Groups[group])
+	}
+}
+
+func TestMockTimestampResolution(t *testing.T) {
+	client := NewMock()
+
+	at := time.Date(2016, 3, 3, 15, 17, 53, 250*int(time.Millisecond), time.UTC)
+	client.Track("13793", "Signed Up", &Event{Timestamp: &at})
+
+	event := client.Events("13793")[0]
+	if event.Endpoint != "import" || !event.Timestamp.Equal(at) {
+		t.Errorf("Track recorded %v to %+v, want %v to import", event.Timestamp, event.Endpoint, at)
+	}
+}
+
+func TestMockContextCancellation(t *testing.T) {
+	m := NewMock()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := m.TrackCtx(canceled, "13793", "Signed Up", &Event{}); err != context.Canceled {
+		t.Errorf("TrackCtx returned %v, want %v", err, context.Canceled)
+	}
+	if err := m.UpdateCtx(canceled, "13793", &Update{Operation: OpSet, Properties: map[string]interface{}{"Plan": "pro"}}); err != context.Canceled {
+		t.Errorf("UpdateCtx returned %v, want %v", err, context.Canceled)
+	}
+	if err := m.AliasCtx(canceled, "13793", "13794"); err != context.Canceled {
+		t.Errorf("AliasCtx returned %v, want %v", err, context.Canceled)
+	}
+
+	events := []BatchEvent{{DistinctId: "13793", EventName: "Signed Up"}}
+	calls := map[string]func() error{
+		"ImportCtx":           func() error { return m.ImportCtx(canceled, "13793", "Signed Up", &Event{}) },
+		"ImportNDJSONCtx":     func() error { return m.ImportNDJSONCtx(canceled, events) },
+		"ImportBatchCtx":      func() error { return m.ImportBatchCtx(canceled, events) },
+		"TrackBatchCtx":       func() error { return m.TrackBatchCtx(canceled, events) },
+		"ReconcileCohortsCtx": func() error { return m.ReconcileCohortsCtx(canceled, "13793", []string{"beta"}) },
+		"EnsureCreatedCtx":    func() error { return m.EnsureCreatedCtx(canceled, "13793", time.Now()) },
+		"IncrementCtx":        func() error { return m.IncrementCtx(canceled, "13793", "Logins", 1) },
+		"BumpCtx":             func() error { return m.BumpCtx(canceled, "13793", "Logins") },
+		"TrackChargeCtx":      func() error { return m.TrackChargeCtx(canceled, "13793", 9.99, nil) },
+		"ClearChargesCtx":     func() error { return m.ClearChargesCtx(canceled, "13793") },
+		"SetOnceCtx":          func() error { return m.SetOnceCtx(canceled, "13793", map[string]interface{}{"Plan": "pro"}) },
+		"UnsetCtx":            func() error { return m.UnsetCtx(canceled, "13793", []string{"Plan"}) },
+		"PeopleSetCtx":        func() error { return m.PeopleSetCtx(canceled, "13793", map[string]interface{}{"Plan": "pro"}) },
+		"PeopleIncrementCtx":  func() error { return m.PeopleIncrementCtx(canceled, "13793", map[string]float64{"Logins": 1}) },
+		"PeopleAppendCtx":     func() error { return m.PeopleAppendCtx(canceled, "13793", map[string]interface{}{"Tags": "beta"}) },
+		"PeopleUnionCtx":      func() error { return m.PeopleUnionCtx(canceled, "13793", map[string][]interface{}{"Tags": {"beta"}}) },
+		"PeopleRemoveCtx":     func() error { return m.PeopleRemoveCtx(canceled, "13793", map[string]interface{}{"Tags": "beta"}) },
+		"GroupUpdateCtx": func() error {
+			return m.GroupUpdateCtx(canceled, "company", "Acme", &GroupUpdateRequest{Operation: "$set", Properties: map[string]interface{}{"Plan": "pro"}})
+		},
+	}
+	for name, call := range calls {
+		if err := call(); err != context.Canceled {
+			t.Errorf("%s returned %v, want %v", name, err, context.Canceled)
+		}
+	}
+	if len(m.People) != 0 || len(m.Groups) != 0 {
+		t.Errorf("People returned %+v and Groups %+v, want nothing recorded", m.People, m.Groups)
+	}
+}
+
+func TestMockFailNext(t *testing.T) {
+	m := NewMock()
+	first, second := errors.New("connection reset"), errors.New("rate limited")
+
+	m.FailNext(first)
+	m.FailNext(second)
+
+	if err := m.Track("13793", "Signed Up", &Event{}); err != first {
+		t.Errorf("Track returned %v, want %v", err, first)
+	}
+	if err := m.Update("13793", &Update{Operation: OpSet, Properties: map[string]interface{}{"plan": "pro"}}); err != second {
+		t.Errorf("Update returned %v, want %v", err, second)
+	}
+	if m.Profile("13793") != nil {
+		t.Errorf("Profile returned %+v, want nothing recorded by failed calls", m.Profile("13793"))
+	}
+
+	if err := m.Track("13793", "Signed Up", &Event{}); err != nil {
+		t.Errorf("Track returned %v once the queued errors were used", err)
+	}
+}
+
+func TestMockFailOn(t *testing.T) {
+	m := NewMock()
+	down := errors.New("mixpanel is down")
+
+	m.FailOn("Track", down)
+	for i := 0; i < 2; i++ {
+		if err := m.Track("13793", "Signed Up", &Event{}); err != down {
+			t.Errorf("Track returned %v, want %v", err, down)
+		}
+	}
+	if err := m.Alias("13793", "13794"); err != nil {
+		t.Errorf("Alias returned %v, want no error", err)
+	}
+
+	m.FailOn("Track", nil)
+	if err := m.Track("13793", "Signed Up", &Event{}); err != nil {
+		t.Errorf("Track returned %v after FailOn with nil", err)
+	}
+	if got := len(m.Events("13793")); got != 1 {
+		t.Errorf("Events returned %d events, want %d", got, 1)
+	}
+}
+
+func TestMockCalls(t *testing.T) {
+	m := NewMock()
+
+	m.Track("13793", "Signed Up", &Event{})
+	m.Track("13793", "Logged In", &Event{})
+	m.TrackCtx(context.Background(), "13793", "Logged In", &Event{})
+	m.FailNext(errors.New("failed"))
+	m.Bump("13793", "logins")
+
+	for method, want := range map[string]int{"Track": 2, "TrackCtx": 1, "Bump": 1, "Increment": 0} {
+		if got := m.Calls(method); got != want {
+			t.Errorf("Calls(%q) returned %+v, want %+v", method, got, want)
+		}
+	}
+}
+
+func TestMockLastUpdate(t *testing.T) {
+	m := NewMock()
+
+	if m.LastUpdate("13793") != nil {
+		t.Errorf("LastUpdate returned %+v, want nil", m.LastUpdate("13793"))
+	}
+
+	m.PeopleSet("13793", map[string]interface{}{"plan": "pro"})
+	want := &Update{Operation: OpSet, Properties: map[string]interface{}{"plan": "pro"}}
+	if got := m.LastUpdate("13793"); !reflect.DeepEqual(got, want) {
+		t.Errorf("LastUpdate returned %+v, want %+v", got, want)
+	}
+
+	m.Increment("13793", "credits", 2)
+	want = &Update{Operation: OpAdd, Properties: map[string]interface{}{"credits": 2.0}}
+	if got := m.LastUpdate("13793"); !reflect.DeepEqual(got, want) {
+		t.Errorf("LastUpdate returned %+v, want %+v", got, want)
+	}
+}
+
+func TestMockConcurrent(t *testing.T) {
+	m := NewMock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				m.Track("13793", "Signed Up", &Event{})
+				m.Increment("13793", "credits", 1)
+				m.Events("13793")
+				m.LastUpdate("13793")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(m.Events("13793")); got != 100 {
+		t.Errorf("Events returned %d events, want %d", got, 100)
+	}
+	if got := m.Profile("13793").Properties["credits"]; got != 100.0 {
+		t.Errorf("credits returned %+v, want %+v", got, 100.0)
+	}
+}
+
+func TestMockSuperProperties(t *testing.T) {
+	m := NewMock()
+
+	m.RegisterSuperProperties(map[string]interface{}{"app_version": "1.0", "plan": "free"})
+	m.Track("13793", "Signed Up", &Event{Properties: map[string]interface{}{"plan": "pro"}})
+	m.UnregisterSuperProperty("app_version")
+	m.Track("13793", "Logged In", &Event{})
+
+	events := m.Events("13793")
+	if want := map[string]interface{}{"app_version": "1.0", "plan": "pro"}; !reflect.DeepEqual(events[0].Properties, want) {
+		t.Errorf("Properties returned %+v, want %+v", events[0].Properties, want)
+	}
+	if want := map[string]interface{}{"plan": "free"}; !reflect.DeepEqual(events[1].Properties, want) {
+		t.Errorf("Properties returned %+v, want %+v", events[1].Properties, want)
+	}
+}
+
+func TestMockString(t *testing.T) {
+	client := NewMock()
+	client.Track("13794", "Signed Up", &Event{Properties: map[string]interface{}{"b": 2, "a": 1}})
+	client.Update("13793", &Update{Operation: OpSet, Properties: map[string]interface{}{"Plan": "pro", "$email": "john@example.com"}})
+	client.Alias("13793", "john")
+
+	want := "13793:\n" +
+		"  ip: \n  time: \n" +
+		"  properties:\n    $email: john@example.com\n    Plan: pro\n" +
+		"  events:\n" +
+		"13794:\n" +
+		"  ip: \n  time: \n" +
+		"  properties:\n" +
+		"  events:\n    Signed Up:\n      IP: \n      Timestamp:\n      a: 1\n      b: 2\n" +
+		"aliases:\n  13793: john\n"
+	for i := 0; i < 10; i++ {
+		if got := client.String(); got != want {
+			t.Fatalf("String returned %q, want %q", got, want)
+		}
+	}
+}
+
+func TestMockDumpJSON(t *testing.T) {
+	client := NewMock()
+	client.Track("13793", "Signed Up", &Event{Properties: map[string]interface{}{"b": 2, "a": 1}})
+	client.GroupUpdate("company_id", "2", &GroupUpdateRequest{Operation: OpSet, Properties: map[string]interface{}{"Name": "Acme"}})
+	client.GroupUpdate("company_id", "1", &GroupUpdateRequest{Operation: OpSet, Properties: map[string]interface{}{"Name": "Initech"}})
+	client.Alias("13793", "john")
+
+	got, err := client.DumpJSON()
+	if err != nil {
+		t.Fatalf("DumpJSON returned %v", err)
+	}
+
+	want := `{
+  "people": {
+    "13793": {
+      "properties": {},
+      "events": [
+        {
+          "name": "Signed Up",
+          "endpoint": "track",
+          "properties": {
+            "a": 1,
+            "b": 2
+          }
+        }
+      ]
+    }
+  },
+  "groups": [
+    {
+      "key": "company_id",
+      "id": "1",
+      "properties": {
+        "Name": "Initech"
+      }
+    },
+    {
+      "key": "company_id",
+      "id": "2",
+      "properties": {
+        "Name": "Acme"
+      }
+    }
+  ],
+  "aliases": [
+    {
+      "distinct_id": "13793",
+      "new_id": "john"
+    }
+  ]
+}`
+	if string(got) != want {
+		t.Errorf("DumpJSON returned %s, want %s", got, want)
+	}
+}
+
+func TestMockSetClock(t *testing.T) {
+	now := time.Date(2020, 1, 10, 12, 0, 0, 0, time.UTC)
+	m := NewMock()
+	m.SetClock(fixedClock(now))
+
+	recent := now.Add(-24 * time.Hour)
+	m.Track("13793", "Signed Up", &Event{Timestamp: &recent})
+	if got := m.LastEndpoint(); got != "track" {
+		t.Errorf("LastEndpoint returned %+v for an event a day old, want %+v", got, "track")
+	}
+
+	m.TrackCharge("13793", 9.99, nil)
+	transactions := m.Profile("13793").Properties["$transactions"].([]interface{})
+	if got, want := transactions[0].(map[string]interface{})["$time"], "2020-01-10T12:00:00"; got != want {
+		t.Errorf("$time returned %+v, want %+v", got, want)
+	}
+}
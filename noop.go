@@ -0,0 +1,335 @@
+package mixpanel
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// NewNoOp returns a client that discards everything: the ingestion calls
+// send nothing and return nil, and the query calls return empty results.
+// Use it where analytics are turned off, such as in development, instead of
+// checking before every call. A client that is only turned off some of the
+// time is better served by WithEnabledFunc.
+func NewNoOp() Mixpanel {
+	return noOp{}
+}
+
+// noOp is the client returned by NewNoOp.
+type noOp struct{}
+
+func (noOp) Track(distinctId, eventName string, e *Event, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) TrackCtx(ctx context.Context, distinctId, eventName string, e *Event, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) Update(distinctId string, u *Update, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) UpdateCtx(ctx context.Context, distinctId string, u *Update, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) Alias(distinctId, newId string, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) AliasCtx(ctx context.Context, distinctId, newId string, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) Import(distinctId, eventName string, e *Event, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) ImportCtx(ctx context.Context, distinctId, eventName string, e *Event, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) Merge(distinctIds []string, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) MergeCtx(ctx context.Context, distinctIds []string, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) MergeIdentity(identifiedId, anonId string, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) MergeIdentityCtx(ctx context.Context, identifiedId, anonId string, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) CreateIdentity(identifiedId, anonId string) error {
+	return nil
+}
+
+func (noOp) Identify(anonId, identifiedId string, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) IdentifyCtx(ctx context.Context, anonId, identifiedId string, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) ImportNDJSON(events []BatchEvent, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) ImportNDJSONCtx(ctx context.Context, events []BatchEvent, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) ImportBatch(events []BatchEvent, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) ImportBatchCtx(ctx context.Context, events []BatchEvent, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) TrackBatch(events []BatchEvent, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) TrackBatchCtx(ctx context.Context, events []BatchEvent, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) ReconcileCohorts(distinctId string, desired []string) error {
+	return nil
+}
+
+func (noOp) ReconcileCohortsCtx(ctx context.Context, distinctId string, desired []string) error {
+	return nil
+}
+
+func (noOp) EnsureCreated(distinctId string, t time.Time) error {
+	return nil
+}
+
+func (noOp) EnsureCreatedCtx(ctx context.Context, distinctId string, t time.Time) error {
+	return nil
+}
+
+func (noOp) Increment(distinctId, property string, by float64) error {
+	return nil
+}
+
+func (noOp) IncrementCtx(ctx context.Context, distinctId, property string, by float64) error {
+	return nil
+}
+
+func (noOp) PeopleDelete(distinctId string, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) PeopleDeleteCtx(ctx context.Context, distinctId string, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) TrackCharge(distinctId string, amount float64, properties map[string]interface{}) error {
+	return nil
+}
+
+func (noOp) TrackChargeCtx(ctx context.Context, distinctId string, amount float64, properties map[string]interface{}) error {
+	return nil
+}
+
+func (noOp) ClearCharges(distinctId string) error {
+	return nil
+}
+
+func (noOp) ClearChargesCtx(ctx context.Context, distinctId string) error {
+	return nil
+}
+
+func (noOp) SetOnce(distinctId string, props map[string]interface{}) error {
+	return nil
+}
+
+func (noOp) SetOnceCtx(ctx context.Context, distinctId string, props map[string]interface{}) error {
+	return nil
+}
+
+func (noOp) PeopleSet(distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) PeopleSetCtx(ctx context.Context, distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) PeopleIncrement(distinctId string, by map[string]float64, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) PeopleIncrementCtx(ctx context.Context, distinctId string, by map[string]float64, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) PeopleAppend(distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) PeopleAppendCtx(ctx context.Context, distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) PeopleUnion(distinctId string, lists map[string][]interface{}, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) PeopleUnionCtx(ctx context.Context, distinctId string, lists map[string][]interface{}, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) PeopleRemove(distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) PeopleRemoveCtx(ctx context.Context, distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) Unset(distinctId string, keys []string) error {
+	return nil
+}
+
+func (noOp) UnsetCtx(ctx context.Context, distinctId string, keys []string) error {
+	return nil
+}
+
+func (noOp) GroupUpdate(groupKey, groupID string, g *GroupUpdateRequest, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) GroupUpdateCtx(ctx context.Context, groupKey, groupID string, g *GroupUpdateRequest, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) GroupDelete(groupKey, groupID string, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) GroupDeleteCtx(ctx context.Context, groupKey, groupID string, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) Bump(distinctId, property string) error {
+	return nil
+}
+
+func (noOp) BumpCtx(ctx context.Context, distinctId, property string) error {
+	return nil
+}
+
+func (noOp) UpdateBatch(updates []BatchUpdate, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) UpdateBatchCtx(ctx context.Context, updates []BatchUpdate, opts ...CallOption) error {
+	return nil
+}
+
+func (noOp) Export(p ExportParams) ([]ExportedEvent, error) {
+	return nil, nil
+}
+
+func (noOp) ExportCtx(ctx context.Context, p ExportParams) ([]ExportedEvent, error) {
+	return nil, nil
+}
+
+func (noOp) ExportEach(ctx context.Context, p ExportParams, fn func(ExportedEvent) error) error {
+	return nil
+}
+
+func (noOp) RecentErrors() []FailedRequest {
+	return nil
+}
+
+func (noOp) Health() Health {
+	return Health{State: BreakerClosed}
+}
+
+func (noOp) CopyEvents(ctx context.Context, from ExportParams, dest Mixpanel) error {
+	return nil
+}
+
+func (noOp) CreatePipeline(p PipelineParams) ([]string, error) {
+	return nil, nil
+}
+
+func (noOp) CreatePipelineCtx(ctx context.Context, p PipelineParams) ([]string, error) {
+	return nil, nil
+}
+
+func (noOp) ConnectorStatus(connectorId string) (*ConnectorStatus, error) {
+	return &ConnectorStatus{}, nil
+}
+
+func (noOp) ConnectorStatusCtx(ctx context.Context, connectorId string) (*ConnectorStatus, error) {
+	return &ConnectorStatus{}, nil
+}
+
+func (noOp) UploadSchemas(ctx context.Context, entries []SchemaEntry) error {
+	return nil
+}
+
+func (noOp) ListSchemas(ctx context.Context, entityType string) ([]SchemaEntry, error) {
+	return nil, nil
+}
+
+func (noOp) CreateAnnotation(ctx context.Context, a Annotation) (*Annotation, error) {
+	return &a, nil
+}
+
+func (noOp) ListAnnotations(ctx context.Context, from, to time.Time) ([]Annotation, error) {
+	return nil, nil
+}
+
+func (noOp) ValidateRegion(ctx context.Context) error {
+	return nil
+}
+
+func (noOp) Flows(event string, from, to time.Time, opts FlowsOpts) (*FlowsResult, error) {
+	return &FlowsResult{}, nil
+}
+
+func (noOp) FlowsCtx(ctx context.Context, event string, from, to time.Time, opts FlowsOpts) (*FlowsResult, error) {
+	return &FlowsResult{}, nil
+}
+
+func (noOp) StreamJQL(ctx context.Context, script string, params interface{}, w io.Writer) error {
+	return nil
+}
+
+func (noOp) UnsetPropertyWhere(ctx context.Context, where string, names []string) (int, error) {
+	return 0, nil
+}
+
+func (noOp) RegisterSuperProperties(props map[string]interface{}) {}
+
+func (noOp) UnregisterSuperProperty(key string) {}
+
+func (noOp) QueryProfiles(ctx context.Context, q ProfileQuery) (*ProfilePage, error) {
+	return &ProfilePage{}, nil
+}
+
+func (noOp) EachProfile(ctx context.Context, q ProfileQuery, fn func(Profile) error) error {
+	return nil
+}
+
+func (noOp) PipelineStatus(name string) ([]PipelineRun, error) {
+	return nil, nil
+}
+
+func (noOp) PipelineStatusCtx(ctx context.Context, name string) ([]PipelineRun, error) {
+	return nil, nil
+}
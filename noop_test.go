@@ -0,0 +1,31 @@
+package mixpanel
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNoOp(t *testing.T) {
+	client := NewNoOp()
+
+	if err := client.Track("13793", "Signed Up", &Event{}); err != nil {
+		t.Errorf("Track returned %v", err)
+	}
+	if err := client.PeopleSet("13793", map[string]interface{}{"Plan": "pro"}); err != nil {
+		t.Errorf("PeopleSet returned %v", err)
+	}
+
+	page, err := client.QueryProfiles(context.Background(), ProfileQuery{})
+	if err != nil || len(page.Profiles) != 0 {
+		t.Errorf("QueryProfiles returned %+v, %v, want an empty page", page, err)
+	}
+
+	var seen int
+	err = client.EachProfile(context.Background(), ProfileQuery{}, func(Profile) error {
+		seen++
+		return nil
+	})
+	if err != nil || seen != 0 {
+		t.Errorf("EachProfile returned %v after %d profiles, want none", err, seen)
+	}
+}
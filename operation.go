@@ -0,0 +1,175 @@
+package mixpanel
+
+import (
+	"fmt"
+	"time"
+)
+
+// OperationType identifies the client method an Operation replays.
+type OperationType string
+
+const (
+	OperationTrack  OperationType = "track"
+	OperationUpdate OperationType = "update"
+	OperationAlias  OperationType = "alias"
+)
+
+// An Operation records a single call to Track, Update or Alias so that it can
+// be persisted, for example as an audit log, and replayed later with
+// ApplyOperations. Operations round-trip through encoding/json; property
+// values come back as the types encoding/json decodes them to.
+type Operation struct {
+	Type       OperationType `json:"type"`
+	DistinctId string        `json:"distinct_id"`
+
+	// Event name of a Track operation.
+	EventName string `json:"event,omitempty"`
+
+	// Update operation, such as "$set", of an Update operation.
+	Operation string `json:"operation,omitempty"`
+
+	// New id of an Alias operation.
+	NewId string `json:"new_id,omitempty"`
+
+	IP         string                 `json:"ip,omitempty"`
+	Timestamp  *time.Time             `json:"timestamp,omitempty"`
+	IgnoreTime bool                   `json:"ignore_time,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+
+	// Property names removed by an OpUnset Update operation.
+	Unset []string `json:"unset,omitempty"`
+
+	// Geolocate of the Event or Update.
+	Geolocate *bool `json:"geolocate,omitempty"`
+
+	// InsertId of the Event of a Track operation.
+	InsertId string `json:"insert_id,omitempty"`
+}
+
+// TrackOperation records a call to Track.
+func TrackOperation(distinctId, eventName string, e *Event) Operation {
+	return Operation{
+		Type:       OperationTrack,
+		DistinctId: distinctId,
+		EventName:  eventName,
+		IP:         e.IP,
+		Timestamp:  e.Timestamp,
+		Properties: e.Properties,
+		Geolocate:  e.Geolocate,
+		InsertId:   e.InsertId,
+	}
+}
+
+// UpdateOperation records a call to Update.
+func UpdateOperation(distinctId string, u *Update) Operation {
+	op := Operation{
+		Type:       OperationUpdate,
+		DistinctId: distinctId,
+		Operation:  u.Operation,
+		IP:         u.IP,
+		Timestamp:  u.Timestamp,
+		Properties: u.Properties,
+		Unset:      u.Unset,
+		Geolocate:  u.Geolocate,
+	}
+	if u.Timestamp == IgnoreTime {
+		op.Timestamp = nil
+		op.IgnoreTime = true
+	}
+	return op
+}
+
+// AliasOperation records a call to Alias.
+func AliasOperation(distinctId, newId string) Operation {
+	return Operation{
+		Type:       OperationAlias,
+		DistinctId: distinctId,
+		NewId:      newId,
+	}
+}
+
+// An OperationError is returned by ApplyOperations when an operation fails.
+type OperationError struct {
+	// Index of the failed operation. Operations before it were applied.
+	Index int
+
+	// Operation is the failed operation, for example to keep it aside.
+	Operation Operation
+
+	Err error
+}
+
+func (err *OperationError) Error() string {
+	return fmt.Sprintf("mixpanel: operation %d: %v", err.Index, err.Err)
+}
+
+func (err *OperationError) Unwrap() error {
+	return err.Err
+}
+
+// ApplyOperations replays ops against client in order. It stops at the first
+// operation that fails and returns an *OperationError identifying it.
+func ApplyOperations(client Mixpanel, ops []Operation) error {
+	for i, op := range ops {
+		if err := applyOperation(client, op); err != nil {
+			return &OperationError{Index: i, Operation: op, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// ApplyOperationsAliasesFirst is like ApplyOperations, but sends every alias
+// operation, in order, before the other operations, which follow in order.
+// Events tracked for a new id in the same batch as the alias creating it are
+// thus attributed to the aliased profile instead of a new one. Each operation
+// is its own request, so an alias has been accepted by Mixpanel before any
+// event depending on it is sent.
+//
+// The Index of a returned *OperationError refers to ops. Since operations are
+// reordered, operations after it in ops may have been applied: all aliases if
+// a later operation failed.
+func ApplyOperationsAliasesFirst(client Mixpanel, ops []Operation) error {
+	for _, aliases := range []bool{true, false} {
+		for i, op := range ops {
+			if (op.Type == OperationAlias) != aliases {
+				continue
+			}
+			if err := applyOperation(client, op); err != nil {
+				return &OperationError{Index: i, Operation: op, Err: err}
+			}
+		}
+	}
+
+	return nil
+}
+
+func applyOperation(client Mixpanel, op Operation) error {
+	switch op.Type {
+	case OperationTrack:
+		return client.Track(op.DistinctId, op.EventName, &Event{
+			IP:         op.IP,
+			Timestamp:  op.Timestamp,
+			Geolocate:  op.Geolocate,
+			Properties: op.Properties,
+			InsertId:   op.InsertId,
+		})
+	case OperationUpdate:
+		u := &Update{
+			Operation:  op.Operation,
+			IP:         op.IP,
+			Timestamp:  op.Timestamp,
+			Geolocate:  op.Geolocate,
+			Properties: op.Properties,
+			Unset:      op.Unset,
+		}
+		if op.IgnoreTime {
+			u.Timestamp = IgnoreTime
+		}
+		return client.Update(op.DistinctId, u)
+	case OperationAlias:
+		return client.Alias(op.DistinctId, op.NewId)
+	}
+
+	return fmt.Errorf("mixpanel: unknown operation type %q", op.Type)
+}
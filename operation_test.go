@@ -0,0 +1,128 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestApplyOperations(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path+" "+decodeData(r))
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	at := time.Date(2016, 3, 3, 15, 17, 53, 0, time.UTC)
+	ops := []Operation{
+		AliasOperation("anon", "13793"),
+		TrackOperation("13793", "Signed Up", &Event{
+			IP:         "0",
+			Timestamp:  &at,
+			Properties: map[string]interface{}{"Referred By": "Friend"},
+		}),
+		UpdateOperation("13793", &Update{
+			Operation:  "$set",
+			Timestamp:  IgnoreTime,
+			Properties: map[string]interface{}{"Plan": "pro"},
+		}),
+	}
+
+	data, err := json.Marshal(ops)
+	if err != nil {
+		t.Fatalf("marshaling operations: %v", err)
+	}
+
+	var replay []Operation
+	if err := json.Unmarshal(data, &replay); err != nil {
+		t.Fatalf("unmarshaling operations: %v", err)
+	}
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+	if err := ApplyOperations(client, replay); err != nil {
+		t.Fatalf("ApplyOperations returned %v", err)
+	}
+
+	want := []string{
+		"/track {\"event\":\"$create_alias\",\"properties\":{\"alias\":\"13793\",\"distinct_id\":\"anon\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}",
+		"/import {\"event\":\"Signed Up\",\"properties\":{\"Referred By\":\"Friend\",\"distinct_id\":\"13793\",\"ip\":\"0\",\"time\":1457018273000,\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}",
+		"/engage {\"$distinct_id\":\"13793\",\"$ignore_time\":true,\"$set\":{\"Plan\":\"pro\"},\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}",
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests returned %+v, want %+v", requests, want)
+	}
+}
+
+func TestApplyOperationsError(t *testing.T) {
+	client := NewMock()
+
+	err := ApplyOperations(client, []Operation{
+		TrackOperation("13793", "Signed Up", &Event{}),
+		UpdateOperation("13793", &Update{Operation: "$add"}),
+	})
+
+	opErr, ok := err.(*OperationError)
+	if !ok || opErr.Index != 1 {
+		t.Errorf("ApplyOperations returned %+v, want an *OperationError for index 1", err)
+	}
+}
+
+func TestApplyOperationsAliasesFirst(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path+" "+decodeData(r))
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+	err := ApplyOperationsAliasesFirst(client, []Operation{
+		TrackOperation("13793", "Signed Up", &Event{}),
+		AliasOperation("anon", "13793"),
+	})
+	if err != nil {
+		t.Fatalf("ApplyOperationsAliasesFirst returned %v", err)
+	}
+
+	want := []string{
+		"/track {\"event\":\"$create_alias\",\"properties\":{\"alias\":\"13793\",\"distinct_id\":\"anon\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}",
+		"/track {\"event\":\"Signed Up\",\"properties\":{\"distinct_id\":\"13793\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}",
+	}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests returned %+v, want %+v", requests, want)
+	}
+}
+
+func TestOperationRoundTrip(t *testing.T) {
+	geolocate := false
+	ops := []Operation{
+		TrackOperation("13793", "Signed Up", &Event{Geolocate: &geolocate, InsertId: "signup-13793"}),
+		UpdateOperation("13793", &Update{Operation: OpSet, Geolocate: &geolocate, Properties: map[string]interface{}{"Plan": "pro"}}),
+	}
+
+	data, err := json.Marshal(ops)
+	if err != nil {
+		t.Fatalf("marshaling operations: %v", err)
+	}
+	var replay []Operation
+	if err := json.Unmarshal(data, &replay); err != nil {
+		t.Fatalf("unmarshaling operations: %v", err)
+	}
+
+	client := NewMock()
+	if err := ApplyOperations(client, replay); err != nil {
+		t.Fatalf("ApplyOperations returned %v", err)
+	}
+
+	e := client.Events("13793")[0]
+	if e.InsertId != "signup-13793" || e.Geolocate == nil || *e.Geolocate {
+		t.Errorf("replayed event returned %+v, want its insert id and geolocation kept", e.Event)
+	}
+	if u := client.LastUpdate("13793"); u.Geolocate == nil || *u.Geolocate {
+		t.Errorf("replayed update returned %+v, want its geolocation kept", u)
+	}
+}
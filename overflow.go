@@ -0,0 +1,117 @@
+package mixpanel
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+)
+
+// OverflowIdProperty is the property linking an event split by
+// WithOverflowEvents to its overflow events.
+const OverflowIdProperty = "overflow_id"
+
+// overflowSuffix is appended to the name of overflow events.
+const overflowSuffix = " (overflow)"
+
+// WithOverflowEvents makes Track split events whose JSON encoding is larger
+// than maxBytes instead of sending them whole, for payloads too variable to
+// keep under Mixpanel's size limit. The event keeps as many properties as fit
+// and the others are moved to follow-up events named after it with an
+// " (overflow)" suffix, such as "Signed Up (overflow)". The event and its
+// overflow events all carry the same random OverflowIdProperty, as well as
+// the distinct id, ip and time of the event, so they can be joined back
+// together. Events that fit are sent unchanged.
+//
+// Splitting happens after validation, so the property count limit and any
+// allowlist apply to the event as a whole.
+func WithOverflowEvents(maxBytes int) Option {
+	return func(m *mixpanel) {
+		m.overflowBytes = maxBytes
+	}
+}
+
+// overflowBase lists the properties copied to every part of a split event.
+var overflowBase = []string{"token", "distinct_id", "ip", "time"}
+
+// splitOverflow splits the event payload params into parts of at most
+// m.overflowBytes, as described in WithOverflowEvents. A single property too
+// large on its own gets an overflow event of its own.
+func (m *mixpanel) splitOverflow(eventName string, params map[string]interface{}) []map[string]interface{} {
+	data, err := json.Marshal(params)
+	if err != nil || len(data) <= m.overflowBytes {
+		return []map[string]interface{}{params}
+	}
+
+	props := params["properties"].(map[string]interface{})
+
+	base := map[string]interface{}{OverflowIdProperty: newOverflowId()}
+	for _, key := range overflowBase {
+		if value, ok := props[key]; ok {
+			base[key] = value
+		}
+	}
+
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		if _, ok := base[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return []map[string]interface{}{params}
+	}
+	sort.Strings(keys)
+
+	baseSize := encodedSize(map[string]interface{}{
+		"event":      eventName + overflowSuffix,
+		"properties": base,
+	})
+
+	var (
+		parts   []map[string]interface{}
+		current map[string]interface{}
+		size    int
+	)
+	for _, key := range keys {
+		// The key, the value, a colon and a comma.
+		pairSize := encodedSize(key) + encodedSize(props[key]) + 2
+
+		if current == nil || (len(current) > len(base) && size+pairSize > m.overflowBytes) {
+			name := eventName
+			if current != nil {
+				name += overflowSuffix
+			}
+			current = copyMap(base)
+			size = baseSize
+			parts = append(parts, map[string]interface{}{
+				"event":      name,
+				"properties": current,
+			})
+		}
+
+		current[key] = props[key]
+		size += pairSize
+	}
+
+	return parts
+}
+
+func encodedSize(v interface{}) int {
+	data, _ := json.Marshal(v)
+	return len(data)
+}
+
+func copyMap(src map[string]interface{}) map[string]interface{} {
+	dst := make(map[string]interface{}, len(src))
+	for key, value := range src {
+		dst[key] = value
+	}
+	return dst
+}
+
+func newOverflowId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,66 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOverflowEvents(t *testing.T) {
+	var events []ExportedEvent
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e ExportedEvent
+		json.Unmarshal([]byte(decodeData(r)), &e)
+		events = append(events, e)
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithOverflowEvents(300))
+
+	err := client.Track("13793", "Report Generated", &Event{
+		Properties: map[string]interface{}{
+			"a": strings.Repeat("a", 150),
+			"b": strings.Repeat("b", 150),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Track sent %d events, want 2", len(events))
+	}
+	if events[0].Event != "Report Generated" || events[1].Event != "Report Generated (overflow)" {
+		t.Errorf("Track sent events %q and %q", events[0].Event, events[1].Event)
+	}
+
+	id := events[0].Properties[OverflowIdProperty]
+	if id == nil || id != events[1].Properties[OverflowIdProperty] {
+		t.Errorf("events have overflow ids %v and %v, want the same", id, events[1].Properties[OverflowIdProperty])
+	}
+
+	for i, key := range []string{"a", "b"} {
+		if _, ok := events[i].Properties[key]; !ok {
+			t.Errorf("event %d is missing property %q", i, key)
+		}
+		if got := events[i].Properties["distinct_id"]; got != "13793" {
+			t.Errorf("event %d has distinct_id %v", i, got)
+		}
+	}
+}
+
+func TestOverflowEventsFits(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithOverflowEvents(1000))
+	client.Track("13793", "Signed Up", &Event{})
+
+	want := "{\"event\":\"Signed Up\",\"properties\":{\"distinct_id\":\"13793\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("LastRequest.URL returned %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,305 @@
+package mixpanel
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// EnsureCreated stamps the reserved $created property of a profile with t,
+// using $set_once so that only the first call for a profile has any effect.
+// Mixpanel shows $created as the profile's creation date and uses it for
+// cohorts such as "users created in the last 7 days"; it expects the value
+// as an ISO 8601 date and time in UTC without a timezone suffix, which is the
+// format sent here.
+func (m *mixpanel) EnsureCreated(distinctId string, t time.Time) error {
+	return m.EnsureCreatedCtx(context.Background(), distinctId, t)
+}
+
+// EnsureCreatedCtx is like EnsureCreated, but sends the request with ctx.
+func (m *mixpanel) EnsureCreatedCtx(ctx context.Context, distinctId string, t time.Time) error {
+	return m.UpdateCtx(ctx, distinctId, createdUpdate(t))
+}
+
+// SetOnce sets the properties props of a profile that it does not have yet,
+// with $set_once.
+func (m *mixpanel) SetOnce(distinctId string, props map[string]interface{}) error {
+	return m.SetOnceCtx(context.Background(), distinctId, props)
+}
+
+// SetOnceCtx is like SetOnce, but sends the request with ctx.
+func (m *mixpanel) SetOnceCtx(ctx context.Context, distinctId string, props map[string]interface{}) error {
+	return m.UpdateCtx(ctx, distinctId, &Update{Operation: OpSetOnce, Properties: props})
+}
+
+// Unset removes the properties keys from a profile, with $unset.
+func (m *mixpanel) Unset(distinctId string, keys []string) error {
+	return m.UnsetCtx(context.Background(), distinctId, keys)
+}
+
+// UnsetCtx is like Unset, but sends the request with ctx.
+func (m *mixpanel) UnsetCtx(ctx context.Context, distinctId string, keys []string) error {
+	return m.UpdateCtx(ctx, distinctId, &Update{Operation: OpUnset, Unset: keys})
+}
+
+// PeopleSet sets the properties props of a profile, with $set. opts are
+// those of Update.
+func (m *mixpanel) PeopleSet(distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	return m.PeopleSetCtx(context.Background(), distinctId, props, opts...)
+}
+
+// PeopleSetCtx is like PeopleSet, but sends the request with ctx.
+func (m *mixpanel) PeopleSetCtx(ctx context.Context, distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	return m.UpdateCtx(ctx, distinctId, &Update{Operation: OpSet, Properties: props}, opts...)
+}
+
+// PeopleIncrement adds the amounts of by, which may be negative, to the
+// numeric properties of a profile, with $add. Amounts that are not finite,
+// which JSON cannot encode, fail with a *ValidationError.
+func (m *mixpanel) PeopleIncrement(distinctId string, by map[string]float64, opts ...CallOption) error {
+	return m.PeopleIncrementCtx(context.Background(), distinctId, by, opts...)
+}
+
+// PeopleIncrementCtx is like PeopleIncrement, but sends the request with ctx.
+func (m *mixpanel) PeopleIncrementCtx(ctx context.Context, distinctId string, by map[string]float64, opts ...CallOption) error {
+	props := make(map[string]interface{}, len(by))
+	for key, value := range by {
+		props[key] = value
+	}
+	if err := checkOperationValues(OpAdd, props); err != nil {
+		return err
+	}
+	return m.UpdateCtx(ctx, distinctId, &Update{Operation: OpAdd, Properties: props}, opts...)
+}
+
+// PeopleAppend appends each value of props to the list property of the same
+// name, with $append. A nil value fails with a *ValidationError.
+func (m *mixpanel) PeopleAppend(distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	return m.PeopleAppendCtx(context.Background(), distinctId, props, opts...)
+}
+
+// PeopleAppendCtx is like PeopleAppend, but sends the request with ctx.
+func (m *mixpanel) PeopleAppendCtx(ctx context.Context, distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	if err := checkOperationValues(OpAppend, props); err != nil {
+		return err
+	}
+	return m.UpdateCtx(ctx, distinctId, &Update{Operation: OpAppend, Properties: props}, opts...)
+}
+
+// PeopleUnion adds the values of lists missing from the list properties of
+// the same names, with $union. A nil list fails with a *ValidationError.
+func (m *mixpanel) PeopleUnion(distinctId string, lists map[string][]interface{}, opts ...CallOption) error {
+	return m.PeopleUnionCtx(context.Background(), distinctId, lists, opts...)
+}
+
+// PeopleUnionCtx is like PeopleUnion, but sends the request with ctx.
+func (m *mixpanel) PeopleUnionCtx(ctx context.Context, distinctId string, lists map[string][]interface{}, opts ...CallOption) error {
+	props := make(map[string]interface{}, len(lists))
+	for key, values := range lists {
+		props[key] = values
+	}
+	if err := checkOperationValues(OpUnion, props); err != nil {
+		return err
+	}
+	return m.UpdateCtx(ctx, distinctId, &Update{Operation: OpUnion, Properties: props}, opts...)
+}
+
+// PeopleRemove removes each value of props from the list property of the same
+// name, with $remove. A nil value fails with a *ValidationError.
+func (m *mixpanel) PeopleRemove(distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	return m.PeopleRemoveCtx(context.Background(), distinctId, props, opts...)
+}
+
+// PeopleRemoveCtx is like PeopleRemove, but sends the request with ctx.
+func (m *mixpanel) PeopleRemoveCtx(ctx context.Context, distinctId string, props map[string]interface{}, opts ...CallOption) error {
+	if err := checkOperationValues(OpRemove, props); err != nil {
+		return err
+	}
+	return m.UpdateCtx(ctx, distinctId, &Update{Operation: OpRemove, Properties: props}, opts...)
+}
+
+// checkOperationValues checks that the values of props have the shape op
+// takes: a finite number for $add, a list for $union, and a single value,
+// not null, to add to or remove from a list for $append and $remove. Other
+// operations take any value.
+func checkOperationValues(op string, props map[string]interface{}) error {
+	for key, value := range props {
+		var reason string
+		switch op {
+		case OpAdd:
+			if f, ok := value.(float64); !ok || math.IsNaN(f) || math.IsInf(f, 0) {
+				reason = "must be a finite number for " + op
+			}
+		case OpUnion:
+			if list, ok := value.([]interface{}); !ok || list == nil {
+				reason = "must be a list for " + op
+			}
+		case OpAppend, OpRemove:
+			if value == nil {
+				reason = "must not be null for " + op
+			}
+		}
+		if reason != "" {
+			return &ValidationError{Property: key, Reason: reason}
+		}
+	}
+	return nil
+}
+
+// Increment adds by, which may be negative, to the numeric property of a
+// profile with $add. A property the profile does not have yet starts at zero.
+func (m *mixpanel) Increment(distinctId, property string, by float64) error {
+	return m.IncrementCtx(context.Background(), distinctId, property, by)
+}
+
+// IncrementCtx is like Increment, but sends the request with ctx.
+func (m *mixpanel) IncrementCtx(ctx context.Context, distinctId, property string, by float64) error {
+	return m.UpdateCtx(ctx, distinctId, incrementUpdate(property, by))
+}
+
+// PeopleDelete deletes the profile of distinctId with the $delete operation.
+// With IgnoreAlias, an alias is deleted as a profile of its own instead of
+// deleting the profile it points to. Events are not deleted; that takes a
+// GDPR deletion request.
+func (m *mixpanel) PeopleDelete(distinctId string, opts ...CallOption) error {
+	return m.PeopleDeleteCtx(context.Background(), distinctId, opts...)
+}
+
+// PeopleDeleteCtx is like PeopleDelete, but sends the request with ctx.
+func (m *mixpanel) PeopleDeleteCtx(ctx context.Context, distinctId string, opts ...CallOption) error {
+	call := newCallOptions(opts)
+
+	params := map[string]interface{}{
+		"$token":       m.Token,
+		"$distinct_id": distinctId,
+		"$delete":      "",
+	}
+	if call.ignoreAlias {
+		params["$ignore_alias"] = true
+	}
+
+	return m.send(ctx, "engage", params, false, call)
+}
+
+// Bump increments property by one, typically to count occurrences such as
+// logins.
+func (m *mixpanel) Bump(distinctId, property string) error {
+	return m.BumpCtx(context.Background(), distinctId, property)
+}
+
+// BumpCtx is like Bump, but sends the request with ctx.
+func (m *mixpanel) BumpCtx(ctx context.Context, distinctId, property string) error {
+	return m.IncrementCtx(ctx, distinctId, property, 1)
+}
+
+// TrackCharge records a revenue transaction of amount on the profile of
+// distinctId, appending it to $transactions with the current time. properties,
+// which may be nil, are stored on the transaction too, for example a product
+// or a currency.
+func (m *mixpanel) TrackCharge(distinctId string, amount float64, properties map[string]interface{}) error {
+	return m.TrackChargeCtx(context.Background(), distinctId, amount, properties)
+}
+
+// TrackChargeCtx is like TrackCharge, but sends the request with ctx.
+func (m *mixpanel) TrackChargeCtx(ctx context.Context, distinctId string, amount float64, properties map[string]interface{}) error {
+	return m.UpdateCtx(ctx, distinctId, chargeUpdate(amount, properties, m.now()))
+}
+
+// ClearCharges removes every transaction recorded by TrackCharge from the
+// profile of distinctId, setting $transactions to an empty list.
+func (m *mixpanel) ClearCharges(distinctId string) error {
+	return m.ClearChargesCtx(context.Background(), distinctId)
+}
+
+// ClearChargesCtx is like ClearCharges, but sends the request with ctx.
+func (m *mixpanel) ClearChargesCtx(ctx context.Context, distinctId string) error {
+	return m.UpdateCtx(ctx, distinctId, clearChargesUpdate())
+}
+
+func clearChargesUpdate() *Update {
+	return &Update{
+		Operation:  OpSet,
+		Properties: map[string]interface{}{"$transactions": []interface{}{}},
+	}
+}
+
+func chargeUpdate(amount float64, properties map[string]interface{}, t time.Time) *Update {
+	transaction := map[string]interface{}{}
+	for key, value := range properties {
+		transaction[key] = value
+	}
+	transaction["$amount"] = amount
+	transaction["$time"] = t.UTC().Format(ProfileTimeLayout)
+
+	return &Update{
+		Operation:  "$append",
+		Properties: map[string]interface{}{"$transactions": transaction},
+	}
+}
+
+func incrementUpdate(property string, by float64) *Update {
+	return &Update{
+		Operation:  "$add",
+		Properties: map[string]interface{}{property: by},
+	}
+}
+
+func createdUpdate(t time.Time) *Update {
+	return &Update{
+		Operation: "$set_once",
+		Properties: map[string]interface{}{
+			"$created": t.UTC().Format(ProfileTimeLayout),
+		},
+	}
+}
+
+// UnsetPropertyWhere removes the properties names from every profile matching
+// the segmentation expression where, such as `defined(properties["legacy"])`,
+// and returns the number of profiles updated. Matching profiles are read from
+// the profile query API a page at a time and unset in batches of
+// MaxBatchUpdates, so the count is accurate even when it stops early, because
+// of a failed batch or because ctx is done.
+func (m *mixpanel) UnsetPropertyWhere(ctx context.Context, where string, names []string) (int, error) {
+	if m.disabled() {
+		return 0, nil
+	}
+
+	var updated int
+
+	q := ProfileQuery{Where: where}
+	for {
+		if err := ctx.Err(); err != nil {
+			return updated, err
+		}
+
+		page, err := m.QueryProfiles(ctx, q)
+		if err != nil {
+			return updated, err
+		}
+
+		updates := make([]BatchUpdate, len(page.Profiles))
+		for i, profile := range page.Profiles {
+			updates[i] = BatchUpdate{
+				DistinctId: profile.DistinctId,
+				Update:     Update{IP: "0", Timestamp: IgnoreTime},
+				Operations: map[string]interface{}{"$unset": names},
+			}
+		}
+
+		for start := 0; start < len(updates); start += MaxBatchUpdates {
+			end := start + MaxBatchUpdates
+			if end > len(updates) {
+				end = len(updates)
+			}
+			if err := m.updateBatch(ctx, updates[start:end], nil); err != nil {
+				return updated, err
+			}
+			updated += end - start
+		}
+
+		var more bool
+		if q, more = page.Next(q); !more {
+			return updated, nil
+		}
+	}
+}
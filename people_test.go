@@ -0,0 +1,346 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnsureCreated(t *testing.T) {
+	setup()
+	defer teardown()
+
+	at := time.Date(2016, 3, 3, 15, 17, 53, 0, time.FixedZone("CET", 3600))
+	client.EnsureCreated("13793", at)
+
+	want := "{\"$distinct_id\":\"13793\",\"$set_once\":{\"$created\":\"2016-03-03T14:17:53\"},\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}"
+
+	if !reflect.DeepEqual(decodeData(LastRequest), want) {
+		t.Errorf("LastRequest.URL returned %+v, want %+v",
+			decodeData(LastRequest), want)
+	}
+}
+
+func TestMockEnsureCreated(t *testing.T) {
+	mock := NewMock()
+
+	mock.EnsureCreated("13793", time.Date(2016, 3, 3, 0, 0, 0, 0, time.UTC))
+	mock.EnsureCreated("13793", time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	if got := mock.People["13793"].Properties["$created"]; got != "2016-03-03T00:00:00" {
+		t.Errorf("$created returned %+v, want %+v", got, "2016-03-03T00:00:00")
+	}
+}
+
+func TestUnsetPropertyWhere(t *testing.T) {
+	var (
+		pages   []string
+		unsets  int
+		batches int
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2.0/engage":
+			r.ParseForm()
+			if got := r.PostForm.Get("where"); got != `defined(properties["legacy"])` {
+				t.Errorf("where returned %q", got)
+			}
+			page := r.PostForm.Get("page")
+			pages = append(pages, page)
+
+			// Two pages of 60 profiles, then an empty one.
+			var results []string
+			if page == "" || page == "1" {
+				for i := 0; i < 60; i++ {
+					results = append(results, `{"$distinct_id":"`+page+"-"+strconv.Itoa(i)+`"}`)
+				}
+			}
+			fmt.Fprintf(w, `{"session_id":"1234","page_size":60,"results":[%s]}`, strings.Join(results, ","))
+		case "/engage":
+			var records []map[string]interface{}
+			json.Unmarshal([]byte(decodeData(r)), &records)
+			for _, record := range records {
+				if !reflect.DeepEqual(record["$unset"], []interface{}{"legacy"}) {
+					t.Errorf("record returned %+v", record)
+				}
+			}
+			batches++
+			unsets += len(records)
+			w.Write([]byte(`{"status":1,"error":null}`))
+		}
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL, WithQueryURL(ts.URL))
+
+	n, err := client.UnsetPropertyWhere(context.Background(), `defined(properties["legacy"])`, []string{"legacy"})
+	if err != nil {
+		t.Fatalf("UnsetPropertyWhere returned %v", err)
+	}
+	if n != 120 || unsets != 120 {
+		t.Errorf("UnsetPropertyWhere returned %d, sent %d, want 120", n, unsets)
+	}
+	if batches != 4 {
+		t.Errorf("UnsetPropertyWhere sent %d batches, want 4", batches)
+	}
+	if want := []string{"", "1", "2"}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("UnsetPropertyWhere requested pages %+v, want %+v", pages, want)
+	}
+}
+
+func TestUnsetPropertyWhereCanceled(t *testing.T) {
+	setup()
+	defer teardown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	n, err := client.UnsetPropertyWhere(ctx, `defined(properties["legacy"])`, []string{"legacy"})
+	if n != 0 || !errors.Is(err, context.Canceled) {
+		t.Errorf("UnsetPropertyWhere returned %d, %v, want 0, %v", n, err, context.Canceled)
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.Increment("13793", "credits", -2.5)
+
+	want := "{\"$add\":{\"credits\":-2.5},\"$distinct_id\":\"13793\",\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("LastRequest.URL returned %+v, want %+v", got, want)
+	}
+
+	client.Bump("13793", "login_count")
+
+	want = "{\"$add\":{\"login_count\":1},\"$distinct_id\":\"13793\",\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("LastRequest.URL returned %+v, want %+v", got, want)
+	}
+}
+
+func TestMockIncrement(t *testing.T) {
+	mock := NewMock()
+
+	mock.Bump("13793", "login_count")
+	mock.Bump("13793", "login_count")
+	mock.Increment("13793", "login_count", 3)
+
+	if got := mock.People["13793"].Properties["login_count"]; got != 5.0 {
+		t.Errorf("login_count returned %+v, want %+v", got, 5.0)
+	}
+}
+
+func TestPeopleDelete(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.PeopleDelete("13793")
+
+	want := "{\"$delete\":\"\",\"$distinct_id\":\"13793\",\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("LastRequest.URL returned %+v, want %+v", got, want)
+	}
+	if LastRequest.URL.Path != "/engage" {
+		t.Errorf("path returned %+v, want %+v", LastRequest.URL.Path, "/engage")
+	}
+
+	client.PeopleDelete("13793", IgnoreAlias())
+
+	want = "{\"$delete\":\"\",\"$distinct_id\":\"13793\",\"$ignore_alias\":true,\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("LastRequest.URL returned %+v, want %+v", got, want)
+	}
+}
+
+func TestTrackCharge(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.TrackCharge("13793", 29.99, map[string]interface{}{"currency": "EUR"})
+
+	payload := decodePayload(t, LastRequest)
+	transaction := payload["$append"].(map[string]interface{})["$transactions"].(map[string]interface{})
+
+	if got := transaction["$amount"].(json.Number).String(); got != "29.99" {
+		t.Errorf("$amount returned %+v, want %+v", got, "29.99")
+	}
+	if got := transaction["currency"]; got != "EUR" {
+		t.Errorf("currency returned %+v, want %+v", got, "EUR")
+	}
+	if _, err := time.Parse("2006-01-02T15:04:05", transaction["$time"].(string)); err != nil {
+		t.Errorf("$time returned %+v, want a timestamp: %v", transaction["$time"], err)
+	}
+}
+
+func TestClearCharges(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.ClearCharges("13793")
+
+	want := "{\"$distinct_id\":\"13793\",\"$set\":{\"$transactions\":[]},\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("data returned %+v, want %+v", got, want)
+	}
+}
+
+func TestSetOnceAndUnset(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.SetOnce("13793", map[string]interface{}{"First Seen": "2016-03-03"})
+
+	want := "{\"$distinct_id\":\"13793\",\"$set_once\":{\"First Seen\":\"2016-03-03\"},\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("LastRequest.URL returned %+v, want %+v", got, want)
+	}
+
+	client.Unset("13793", []string{"Plan", "Seats"})
+
+	want = "{\"$distinct_id\":\"13793\",\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\",\"$unset\":[\"Plan\",\"Seats\"]}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("LastRequest.URL returned %+v, want %+v", got, want)
+	}
+
+	LastRequest = nil
+	if err := client.Unset("13793", nil); err != ErrNoProperties {
+		t.Errorf("Unset returned %v for no keys, want %v", err, ErrNoProperties)
+	}
+	if LastRequest != nil {
+		t.Errorf("Unset sent a request for no keys")
+	}
+}
+
+func TestMockUnset(t *testing.T) {
+	mock := NewMock()
+
+	mock.Update("13793", &Update{Operation: OpSet, Properties: map[string]interface{}{"Plan": "pro", "Seats": 10}})
+	mock.Unset("13793", []string{"Seats"})
+	mock.SetOnce("13793", map[string]interface{}{"Plan": "free"})
+
+	want := map[string]interface{}{"Plan": "pro"}
+	if got := mock.People["13793"].Properties; !reflect.DeepEqual(got, want) {
+		t.Errorf("Properties returned %+v, want %+v", got, want)
+	}
+}
+
+func TestPeopleHelpers(t *testing.T) {
+	setup()
+	defer teardown()
+
+	for _, tc := range []struct {
+		call func() error
+		want string
+	}{
+		{
+			func() error { return client.PeopleSet("13793", map[string]interface{}{"Plan": "pro"}) },
+			"{\"$distinct_id\":\"13793\",\"$set\":{\"Plan\":\"pro\"},\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}",
+		},
+		{
+			func() error { return client.PeopleIncrement("13793", map[string]float64{"Logins": 1}) },
+			"{\"$add\":{\"Logins\":1},\"$distinct_id\":\"13793\",\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}",
+		},
+		{
+			func() error { return client.PeopleAppend("13793", map[string]interface{}{"Pages": "/pricing"}) },
+			"{\"$append\":{\"Pages\":\"/pricing\"},\"$distinct_id\":\"13793\",\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}",
+		},
+		{
+			func() error {
+				return client.PeopleUnion("13793", map[string][]interface{}{"Tags": {"beta", "admin"}})
+			},
+			"{\"$distinct_id\":\"13793\",\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\",\"$union\":{\"Tags\":[\"beta\",\"admin\"]}}",
+		},
+		{
+			func() error { return client.PeopleRemove("13793", map[string]interface{}{"Tags": "beta"}) },
+			"{\"$distinct_id\":\"13793\",\"$remove\":{\"Tags\":\"beta\"},\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}",
+		},
+	} {
+		tc.call()
+		if got := decodeData(LastRequest); got != tc.want {
+			t.Errorf("LastRequest.URL returned %+v, want %+v", got, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		call func(client Mixpanel) error
+		want *ValidationError
+	}{
+		{
+			"PeopleAppend",
+			func(client Mixpanel) error { return client.PeopleAppend("13793", map[string]interface{}{"Tags": nil}) },
+			&ValidationError{Property: "Tags", Reason: "must not be null for $append"},
+		},
+		{
+			"PeopleRemove",
+			func(client Mixpanel) error { return client.PeopleRemove("13793", map[string]interface{}{"Tags": nil}) },
+			&ValidationError{Property: "Tags", Reason: "must not be null for $remove"},
+		},
+		{
+			"PeopleIncrement",
+			func(client Mixpanel) error {
+				return client.PeopleIncrement("13793", map[string]float64{"Logins": math.NaN()})
+			},
+			&ValidationError{Property: "Logins", Reason: "must be a finite number for $add"},
+		},
+		{
+			"PeopleUnion",
+			func(client Mixpanel) error { return client.PeopleUnion("13793", map[string][]interface{}{"Tags": nil}) },
+			&ValidationError{Property: "Tags", Reason: "must be a list for $union"},
+		},
+	} {
+		LastRequest = nil
+		if err := tc.call(client); !reflect.DeepEqual(err, tc.want) {
+			t.Errorf("%s returned %+v, want %+v", tc.name, err, tc.want)
+		}
+		if LastRequest != nil {
+			t.Errorf("%s sent a request for an invalid value", tc.name)
+		}
+		if err := tc.call(NewMock()); !reflect.DeepEqual(err, tc.want) {
+			t.Errorf("Mock.%s returned %+v, want %+v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestPeopleHelpersOptions(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client.PeopleSet("13793", map[string]interface{}{"Plan": "pro"}, RequestHeader("X-Request-Id", "abc"))
+	if got := LastRequest.Header.Get("X-Request-Id"); got != "abc" {
+		t.Errorf("X-Request-Id returned %+v, want %+v", got, "abc")
+	}
+}
+
+func TestMockPeopleHelpers(t *testing.T) {
+	mock := NewMock()
+
+	mock.PeopleSet("13793", map[string]interface{}{"Plan": "pro"})
+	mock.PeopleIncrement("13793", map[string]float64{"Logins": 2})
+	mock.PeopleAppend("13793", map[string]interface{}{"Pages": "/pricing"})
+	mock.PeopleAppend("13793", map[string]interface{}{"Pages": "/signup"})
+	mock.PeopleUnion("13793", map[string][]interface{}{"Tags": {"beta", "admin"}})
+	mock.PeopleUnion("13793", map[string][]interface{}{"Tags": {"admin", "ops"}})
+	mock.PeopleRemove("13793", map[string]interface{}{"Tags": "beta"})
+
+	want := map[string]interface{}{
+		"Plan":   "pro",
+		"Logins": 2.0,
+		"Pages":  []interface{}{"/pricing", "/signup"},
+		"Tags":   []interface{}{"admin", "ops"},
+	}
+	if got := mock.People["13793"].Properties; !reflect.DeepEqual(got, want) {
+		t.Errorf("Properties returned %+v, want %+v", got, want)
+	}
+}
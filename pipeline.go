@@ -0,0 +1,130 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"time"
+)
+
+// PipelineParams describes a data pipeline, a job run by Mixpanel that
+// exports raw events straight to a cloud storage bucket instead of through
+// Export. Pipelines are created on the export API host and authenticated with
+// the API secret; the project must have the Data Pipelines add-on.
+//
+// The destination bucket must grant Mixpanel write access beforehand: for S3,
+// an IAM role Mixpanel can assume, given as S3Role; for GCS, write access for
+// Mixpanel's service account. See
+// https://developer.mixpanel.com/reference/create-warehouse-pipeline for the
+// required setup.
+type PipelineParams struct {
+	// Type is the export format, "raw" or "schematized". The default is
+	// "raw".
+	Type string
+
+	// From is the first day to export. To is the last, or zero to keep
+	// exporting new data as it arrives.
+	From, To time.Time
+
+	// Frequency is "hourly" or "daily". The default is "daily".
+	Frequency string
+
+	// Events and Where filter the exported events as in ExportParams.
+	Events []string
+	Where  string
+
+	// Trial creates a pipeline that only runs for one day of data.
+	Trial bool
+
+	// S3Bucket, S3Region and S3Role select an S3 destination.
+	S3Bucket, S3Region, S3Role string
+
+	// GCSBucket and GCSRegion select a Google Cloud Storage destination.
+	GCSBucket, GCSRegion string
+}
+
+func (p PipelineParams) values() url.Values {
+	v := url.Values{
+		"type":      {"raw"},
+		"from_date": {p.From.Format("2006-01-02")},
+	}
+	if p.Type != "" {
+		v.Set("type", p.Type)
+	}
+	if !p.To.IsZero() {
+		v.Set("to_date", p.To.Format("2006-01-02"))
+	}
+	if p.Frequency != "" {
+		v.Set("frequency", p.Frequency)
+	}
+	if len(p.Events) > 0 {
+		events, _ := json.Marshal(p.Events)
+		v.Set("events", string(events))
+	}
+	if p.Where != "" {
+		v.Set("where", p.Where)
+	}
+	if p.Trial {
+		v.Set("trial", "true")
+	}
+
+	set := func(key, value string) {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	set("s3_bucket", p.S3Bucket)
+	set("s3_region", p.S3Region)
+	set("s3_role", p.S3Role)
+	set("gcs_bucket", p.GCSBucket)
+	set("gcs_region", p.GCSRegion)
+
+	return v
+}
+
+// A PipelineRun is the status of one run of a data pipeline.
+type PipelineRun struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+
+	// Date is the day of data the run exports.
+	Date string `json:"date"`
+}
+
+// CreatePipeline creates the pipeline described by p and returns the names of
+// the jobs Mixpanel created for it, to be passed to PipelineStatus.
+func (m *mixpanel) CreatePipeline(p PipelineParams) ([]string, error) {
+	return m.CreatePipelineCtx(context.Background(), p)
+}
+
+// CreatePipelineCtx is like CreatePipeline, but sends the request with ctx.
+func (m *mixpanel) CreatePipelineCtx(ctx context.Context, p PipelineParams) ([]string, error) {
+	var resp struct {
+		Names []string `json:"pipeline_names"`
+	}
+
+	if err := m.queryAt(ctx, m.ExportURL, "/2.0/nessie/pipeline/create", p.values(), &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Names, nil
+}
+
+// PipelineStatus returns the runs of the pipeline job name.
+func (m *mixpanel) PipelineStatus(name string) ([]PipelineRun, error) {
+	return m.PipelineStatusCtx(context.Background(), name)
+}
+
+// PipelineStatusCtx is like PipelineStatus, but sends the request with ctx.
+func (m *mixpanel) PipelineStatusCtx(ctx context.Context, name string) ([]PipelineRun, error) {
+	var resp struct {
+		Runs []PipelineRun `json:"status"`
+	}
+
+	form := url.Values{"name": {name}}
+	if err := m.queryAt(ctx, m.ExportURL, "/2.0/nessie/pipeline/status", form, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Runs, nil
+}
@@ -0,0 +1,70 @@
+package mixpanel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCreatePipeline(t *testing.T) {
+	var form map[string][]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, _, _ := r.BasicAuth(); user != "secret" {
+			t.Errorf("basic auth user returned %q, want %q", user, "secret")
+		}
+		switch r.URL.Path {
+		case "/2.0/nessie/pipeline/create":
+			r.ParseForm()
+			form = r.PostForm
+			w.Write([]byte(`{"pipeline_names":["trial-raw-s3-123-events"]}`))
+		case "/2.0/nessie/pipeline/status":
+			if got := r.URL.Query().Get("name"); got != "trial-raw-s3-123-events" {
+				t.Errorf("status name returned %q", got)
+			}
+			w.Write([]byte(`{"status":[{"name":"trial-raw-s3-123-events","state":"succeeded","date":"2016-03-03"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", "", WithExportURL(ts.URL))
+
+	names, err := client.CreatePipeline(PipelineParams{
+		From:     time.Date(2016, 3, 3, 0, 0, 0, 0, time.UTC),
+		Trial:    true,
+		S3Bucket: "exports",
+		S3Region: "us-east-1",
+		S3Role:   "arn:aws:iam::123:role/mixpanel",
+	})
+	if err != nil {
+		t.Fatalf("CreatePipeline returned %v", err)
+	}
+	if want := []string{"trial-raw-s3-123-events"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("CreatePipeline returned %+v, want %+v", names, want)
+	}
+
+	wantForm := map[string][]string{
+		"type":      {"raw"},
+		"from_date": {"2016-03-03"},
+		"trial":     {"true"},
+		"s3_bucket": {"exports"},
+		"s3_region": {"us-east-1"},
+		"s3_role":   {"arn:aws:iam::123:role/mixpanel"},
+	}
+	if !reflect.DeepEqual(form, wantForm) {
+		t.Errorf("CreatePipeline sent %+v, want %+v", form, wantForm)
+	}
+
+	runs, err := client.PipelineStatus(names[0])
+	if err != nil {
+		t.Fatalf("PipelineStatus returned %v", err)
+	}
+
+	want := []PipelineRun{{Name: "trial-raw-s3-123-events", State: "succeeded", Date: "2016-03-03"}}
+	if !reflect.DeepEqual(runs, want) {
+		t.Errorf("PipelineStatus returned %+v, want %+v", runs, want)
+	}
+}
@@ -0,0 +1,67 @@
+package mixpanel
+
+import "time"
+
+// ProfileTimeLayout is the layout Mixpanel expects for dates in profile
+// properties, such as $created: ISO 8601 in UTC without a timezone suffix.
+const ProfileTimeLayout = "2006-01-02T15:04:05"
+
+// ProfileProperties holds the reserved profile properties under their Go
+// names, so that their keys cannot be misspelled:
+//
+//	client.PeopleSet("13793", mixpanel.ProfileProperties{
+//		Email:   "john@example.com",
+//		Created: signedUpAt,
+//	}.Map())
+//
+// Empty fields are left out.
+type ProfileProperties struct {
+	Name        string // $name
+	FirstName   string // $first_name
+	LastName    string // $last_name
+	Email       string // $email
+	Phone       string // $phone
+	Avatar      string // $avatar, the URL of a picture
+	City        string // $city
+	Region      string // $region
+	CountryCode string // $country_code, such as "US"
+	Timezone    string // $timezone, such as "Europe/Paris"
+
+	// Created is sent as $created, formatted with ProfileTimeLayout.
+	Created time.Time
+
+	// Custom holds the other properties. The fields above win over reserved
+	// properties set in it.
+	Custom map[string]interface{}
+}
+
+// Map returns the properties keyed by their Mixpanel names, ready for
+// PeopleSet, SetOnce or an Update.
+func (p ProfileProperties) Map() map[string]interface{} {
+	props := make(map[string]interface{}, len(p.Custom)+11)
+	for key, value := range p.Custom {
+		props[key] = value
+	}
+
+	for key, value := range map[string]string{
+		"$name":         p.Name,
+		"$first_name":   p.FirstName,
+		"$last_name":    p.LastName,
+		"$email":        p.Email,
+		"$phone":        p.Phone,
+		"$avatar":       p.Avatar,
+		"$city":         p.City,
+		"$region":       p.Region,
+		"$country_code": p.CountryCode,
+		"$timezone":     p.Timezone,
+	} {
+		if value != "" {
+			props[key] = value
+		}
+	}
+	if !p.Created.IsZero() {
+		props["$created"] = p.Created.UTC().Format(ProfileTimeLayout)
+	}
+
+	return props
+}
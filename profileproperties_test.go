@@ -0,0 +1,32 @@
+package mixpanel
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProfileProperties(t *testing.T) {
+	created := time.Date(2016, 3, 3, 12, 30, 0, 0, time.FixedZone("CET", 3600))
+
+	got := ProfileProperties{
+		Name:    "John Doe",
+		Email:   "john@example.com",
+		Created: created,
+		Custom:  map[string]interface{}{"Plan": "pro", "$email": "old@example.com"},
+	}.Map()
+
+	want := map[string]interface{}{
+		"$name":    "John Doe",
+		"$email":   "john@example.com",
+		"$created": "2016-03-03T11:30:00",
+		"Plan":     "pro",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Map returned %+v, want %+v", got, want)
+	}
+
+	if got := (ProfileProperties{}).Map(); len(got) != 0 {
+		t.Errorf("Map returned %+v, want no properties", got)
+	}
+}
@@ -0,0 +1,125 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+)
+
+// ProfileQuery selects the profiles returned by QueryProfiles, which reads
+// them from the profile query API, authenticated with the API secret.
+type ProfileQuery struct {
+	// Where is a segmentation expression profiles must match, for example
+	// `properties["plan"] == "pro"`. Empty means all profiles.
+	Where string
+
+	// DistinctIds limits the query to the given profiles.
+	DistinctIds []string
+
+	// OutputProperties limits the properties returned for each profile.
+	// Empty means all properties.
+	OutputProperties []string
+
+	// SessionId and Page select a page after the first one, as returned in
+	// the previous ProfilePage; ProfilePage.Next sets them.
+	SessionId string
+	Page      int
+}
+
+// A Profile is a user profile returned by QueryProfiles.
+type Profile struct {
+	DistinctId string                 `json:"$distinct_id"`
+	Properties map[string]interface{} `json:"$properties"`
+}
+
+// A ProfilePage is a page of the profiles matching a ProfileQuery.
+type ProfilePage struct {
+	Profiles []Profile `json:"results"`
+
+	// SessionId identifies the query for the following pages.
+	SessionId string `json:"session_id"`
+
+	// Page is the index of the page, counting from zero.
+	Page int `json:"page"`
+
+	// PageSize is the largest number of profiles in a page.
+	PageSize int `json:"page_size"`
+
+	// Total is the number of profiles matching the query. Mixpanel only
+	// reports it on the first page.
+	Total int `json:"total"`
+}
+
+// Next returns the query for the page after p, q being the query that
+// returned p, and false if p is the last page.
+func (p *ProfilePage) Next(q ProfileQuery) (ProfileQuery, bool) {
+	if len(p.Profiles) == 0 || len(p.Profiles) < p.PageSize || p.SessionId == "" {
+		return q, false
+	}
+	q.SessionId = p.SessionId
+	q.Page++
+	return q, true
+}
+
+func (q ProfileQuery) values() url.Values {
+	v := url.Values{}
+	if q.Where != "" {
+		v.Set("where", q.Where)
+	}
+	if len(q.DistinctIds) > 0 {
+		ids, _ := json.Marshal(q.DistinctIds)
+		v.Set("distinct_ids", string(ids))
+	}
+	if len(q.OutputProperties) > 0 {
+		props, _ := json.Marshal(q.OutputProperties)
+		v.Set("output_properties", string(props))
+	}
+	if q.SessionId != "" {
+		v.Set("session_id", q.SessionId)
+		v.Set("page", strconv.Itoa(q.Page))
+	}
+	return v
+}
+
+// QueryProfiles returns a page of the profiles selected by q. Use
+// ProfilePage.Next to get the following pages, or EachProfile to go through
+// all of them.
+func (m *mixpanel) QueryProfiles(ctx context.Context, q ProfileQuery) (*ProfilePage, error) {
+	var page ProfilePage
+	if err := m.queryAt(ctx, m.QueryURL, "/2.0/engage", q.values(), &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// EachProfile calls fn for each profile selected by q, reading them a page at
+// a time. It stops at the first error returned by fn, and before the next
+// page once ctx is done.
+func (m *mixpanel) EachProfile(ctx context.Context, q ProfileQuery, fn func(Profile) error) error {
+	return eachProfile(ctx, m, q, fn)
+}
+
+// eachProfile is EachProfile for any client.
+func eachProfile(ctx context.Context, client Mixpanel, q ProfileQuery, fn func(Profile) error) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		page, err := client.QueryProfiles(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, profile := range page.Profiles {
+			if err := fn(profile); err != nil {
+				return err
+			}
+		}
+
+		var more bool
+		if q, more = page.Next(q); !more {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestQueryProfiles(t *testing.T) {
+	var form map[string][]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, _, _ := r.BasicAuth(); user != "secret" {
+			t.Errorf("basic auth user returned %q, want %q", user, "secret")
+		}
+		r.ParseForm()
+		form = r.PostForm
+		w.Write([]byte(`{"page":0,"page_size":1000,"session_id":"1234","status":"ok","total":1,
+			"results":[{"$distinct_id":"13793","$properties":{"$email":"john@example.com","plan":"pro"}}]}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL, WithQueryURL(ts.URL))
+
+	page, err := client.QueryProfiles(context.Background(), ProfileQuery{
+		Where:            `properties["plan"] == "pro"`,
+		DistinctIds:      []string{"13793", "13794"},
+		OutputProperties: []string{"$email", "plan"},
+	})
+	if err != nil {
+		t.Fatalf("QueryProfiles returned %v", err)
+	}
+
+	want := &ProfilePage{
+		Profiles: []Profile{{
+			DistinctId: "13793",
+			Properties: map[string]interface{}{"$email": "john@example.com", "plan": "pro"},
+		}},
+		SessionId: "1234",
+		PageSize:  1000,
+		Total:     1,
+	}
+	if !reflect.DeepEqual(page, want) {
+		t.Errorf("QueryProfiles returned %+v, want %+v", page, want)
+	}
+
+	wantForm := map[string][]string{
+		"where":             {`properties["plan"] == "pro"`},
+		"distinct_ids":      {`["13793","13794"]`},
+		"output_properties": {`["$email","plan"]`},
+	}
+	if !reflect.DeepEqual(form, wantForm) {
+		t.Errorf("form returned %+v, want %+v", form, wantForm)
+	}
+}
+
+func TestEachProfile(t *testing.T) {
+	var pages []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		page := r.PostForm.Get("page")
+		pages = append(pages, page)
+		if page != "" && r.PostForm.Get("session_id") != "1234" {
+			t.Errorf("session_id returned %q, want %q", r.PostForm.Get("session_id"), "1234")
+		}
+
+		// Two full pages of 3 profiles, then a short one.
+		n := 3
+		if page == "2" {
+			n = 1
+		}
+		var results []string
+		for i := 0; i < n; i++ {
+			results = append(results, `{"$distinct_id":"`+page+"-"+strconv.Itoa(i)+`"}`)
+		}
+		fmt.Fprintf(w, `{"session_id":"1234","page_size":3,"results":[%s]}`, strings.Join(results, ","))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL, WithQueryURL(ts.URL))
+
+	var ids []string
+	err := client.EachProfile(context.Background(), ProfileQuery{}, func(p Profile) error {
+		ids = append(ids, p.DistinctId)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("EachProfile returned %v", err)
+	}
+
+	if want := []string{"-0", "-1", "-2", "1-0", "1-1", "1-2", "2-0"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("EachProfile returned %+v, want %+v", ids, want)
+	}
+	if want := []string{"", "1", "2"}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("EachProfile requested pages %+v, want %+v", pages, want)
+	}
+}
@@ -0,0 +1,130 @@
+package mixpanel
+
+import (
+	"context"
+	"os"
+)
+
+// WithDefaultProperties adds props to the properties of every event sent by
+// the client. When an event sets a property with the same key, the event's
+// value wins; use WithDefaultPropertiesPrecedence to make the defaults win
+// instead. Keys are compared case-sensitively, like Mixpanel does, so "Plan"
+// and "plan" are two different properties and both are sent.
+func WithDefaultProperties(props map[string]interface{}) Option {
+	return func(m *mixpanel) {
+		if m.defaultProps == nil {
+			m.defaultProps = map[string]interface{}{}
+		}
+		for key, value := range props {
+			m.defaultProps[key] = value
+		}
+	}
+}
+
+// WithDefaultPropertiesPrecedence makes the properties given to
+// WithDefaultProperties override event properties with the same key, for
+// cases where the defaults are authoritative.
+func WithDefaultPropertiesPrecedence() Option {
+	return func(m *mixpanel) {
+		m.defaultsWin = true
+	}
+}
+
+// WithHostnameProperty tags every event with the hostname of the machine,
+// under key, to tell which instance sent it. The hostname is resolved once,
+// when the client is created, and added as a default property, so event
+// properties with the same key win as described in WithDefaultProperties. No
+// property is added if the hostname cannot be resolved.
+func WithHostnameProperty(key string) Option {
+	return func(m *mixpanel) {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return
+		}
+		WithDefaultProperties(map[string]interface{}{key: hostname})(m)
+	}
+}
+
+// RegisterSuperProperties adds props to the super properties of the client,
+// which are added to every event sent afterwards, replacing the values of
+// super properties already registered with the same keys. Super properties
+// are registered at runtime but otherwise behave like default properties:
+// they win over the properties given to WithDefaultProperties, and the
+// properties of an event override them for that event, unless
+// WithDefaultPropertiesPrecedence is used, in which case they win over the
+// event too.
+func (m *mixpanel) RegisterSuperProperties(props map[string]interface{}) {
+	m.superMu.Lock()
+	defer m.superMu.Unlock()
+
+	if m.superProps == nil {
+		m.superProps = map[string]interface{}{}
+	}
+	for key, value := range props {
+		m.superProps[key] = value
+	}
+}
+
+// UnregisterSuperProperty removes the super property key.
+func (m *mixpanel) UnregisterSuperProperty(key string) {
+	m.superMu.Lock()
+	defer m.superMu.Unlock()
+
+	delete(m.superProps, key)
+}
+
+// mergeProperties copies the default, super and event properties into props,
+// honouring the configured precedence.
+func (m *mixpanel) mergeProperties(props, eventProps map[string]interface{}) {
+	m.superMu.RLock()
+	defer m.superMu.RUnlock()
+
+	if m.defaultsWin {
+		m.copyProperties(props, eventProps)
+		m.copyProperties(props, m.defaultProps)
+		m.copyProperties(props, m.superProps)
+	} else {
+		m.copyProperties(props, m.defaultProps)
+		m.copyProperties(props, m.superProps)
+		m.copyProperties(props, eventProps)
+	}
+}
+
+func (m *mixpanel) copyProperties(dst, src map[string]interface{}) {
+	for key, value := range src {
+		dst[key] = m.coerce(value)
+	}
+}
+
+// WithContextPropertyExtractor registers extract to pull properties such as a
+// trace or request id out of the context given to TrackCtx. The extracted
+// properties are added to the event; properties set on the event itself win
+// over them.
+func WithContextPropertyExtractor(extract func(context.Context) map[string]interface{}) Option {
+	return func(m *mixpanel) {
+		m.contextExtractor = extract
+	}
+}
+
+// withContextProperties returns props with the properties extracted from ctx
+// added.
+func (m *mixpanel) withContextProperties(ctx context.Context, props map[string]interface{}) map[string]interface{} {
+	if m.contextExtractor == nil {
+		return props
+	}
+
+	extracted := m.contextExtractor(ctx)
+	if len(extracted) == 0 {
+		return props
+	}
+
+	merged := make(map[string]interface{}, len(extracted)+len(props))
+	for key, value := range extracted {
+		merged[key] = value
+	}
+	for key, value := range props {
+		merged[key] = value
+	}
+
+	return merged
+}
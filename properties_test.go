@@ -0,0 +1,136 @@
+package mixpanel
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestDefaultPropertiesEventWins(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithDefaultProperties(map[string]interface{}{
+		"app_version": "1.0",
+		"plan":        "free",
+	}))
+
+	client.Track("13793", "Signed Up", &Event{
+		Properties: map[string]interface{}{
+			"plan": "pro",
+			"Plan": "Pro",
+		},
+	})
+
+	want := "{\"event\":\"Signed Up\",\"properties\":{\"Plan\":\"Pro\",\"app_version\":\"1.0\",\"distinct_id\":\"13793\",\"plan\":\"pro\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+
+	if !reflect.DeepEqual(decodeData(LastRequest), want) {
+		t.Errorf("LastRequest.URL returned %+v, want %+v",
+			decodeData(LastRequest), want)
+	}
+}
+
+func TestRegisterSuperProperties(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithDefaultProperties(map[string]interface{}{
+		"app_version": "1.0",
+		"environment": "staging",
+	}))
+
+	client.RegisterSuperProperties(map[string]interface{}{
+		"environment":   "production",
+		"server_region": "eu",
+	})
+	client.Track("13793", "Signed Up", &Event{
+		Properties: map[string]interface{}{"server_region": "us"},
+	})
+
+	want := "{\"event\":\"Signed Up\",\"properties\":{\"app_version\":\"1.0\",\"distinct_id\":\"13793\",\"environment\":\"production\",\"server_region\":\"us\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("LastRequest.URL returned %+v, want %+v", got, want)
+	}
+
+	client.UnregisterSuperProperty("environment")
+	client.Track("13793", "Signed Up", &Event{})
+
+	want = "{\"event\":\"Signed Up\",\"properties\":{\"app_version\":\"1.0\",\"distinct_id\":\"13793\",\"environment\":\"staging\",\"server_region\":\"eu\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("LastRequest.URL returned %+v, want %+v", got, want)
+	}
+}
+
+func TestDefaultPropertiesDefaultsWin(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithDefaultProperties(map[string]interface{}{
+		"app_version": "1.0",
+		"plan":        "free",
+	}), WithDefaultPropertiesPrecedence())
+
+	client.Track("13793", "Signed Up", &Event{
+		Properties: map[string]interface{}{
+			"plan": "pro",
+			"Plan": "Pro",
+		},
+	})
+
+	want := "{\"event\":\"Signed Up\",\"properties\":{\"Plan\":\"Pro\",\"app_version\":\"1.0\",\"distinct_id\":\"13793\",\"plan\":\"free\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+
+	if !reflect.DeepEqual(decodeData(LastRequest), want) {
+		t.Errorf("LastRequest.URL returned %+v, want %+v",
+			decodeData(LastRequest), want)
+	}
+}
+
+type traceKey struct{}
+
+func TestContextPropertyExtractor(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithContextPropertyExtractor(
+		func(ctx context.Context) map[string]interface{} {
+			id, _ := ctx.Value(traceKey{}).(string)
+			return map[string]interface{}{"trace_id": id, "request_id": "from-context"}
+		},
+	))
+
+	ctx := context.WithValue(context.Background(), traceKey{}, "4bf92f35")
+	client.TrackCtx(ctx, "13793", "Signed Up", &Event{
+		Properties: map[string]interface{}{
+			"request_id": "from-event",
+		},
+	})
+
+	want := "{\"event\":\"Signed Up\",\"properties\":{\"distinct_id\":\"13793\",\"request_id\":\"from-event\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\",\"trace_id\":\"4bf92f35\"}}"
+
+	if !reflect.DeepEqual(decodeData(LastRequest), want) {
+		t.Errorf("LastRequest.URL returned %+v, want %+v",
+			decodeData(LastRequest), want)
+	}
+}
+
+func TestHostnameProperty(t *testing.T) {
+	setup()
+	defer teardown()
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skipf("os.Hostname returned %v", err)
+	}
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithHostnameProperty("server"))
+	client.Track("13793", "Signed Up", &Event{})
+
+	want := "{\"event\":\"Signed Up\",\"properties\":{\"distinct_id\":\"13793\",\"server\":" + strconv.Quote(hostname) + ",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+
+	if !reflect.DeepEqual(decodeData(LastRequest), want) {
+		t.Errorf("LastRequest.URL returned %+v, want %+v",
+			decodeData(LastRequest), want)
+	}
+}
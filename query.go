@@ -0,0 +1,125 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WithQueryURL sets the base URL of the query API, used by the methods that
+// read data back from Mixpanel. The default is "https://mixpanel.com/api".
+func WithQueryURL(queryURL string) Option {
+	return func(m *mixpanel) {
+		m.QueryURL = queryURL
+	}
+}
+
+// query sends form to path on the query API, authenticated with the API
+// secret, and decodes the JSON response into v. The form goes in the request
+// body or in the URL depending on the method of the endpoint.
+func (m *mixpanel) query(path string, form url.Values, v interface{}) error {
+	return m.queryAt(context.Background(), m.QueryURL, path, form, v)
+}
+
+// queryAt is like query, but sends form to path under baseURL, for the APIs
+// served by another host than the query API, and sends the request with ctx.
+func (m *mixpanel) queryAt(ctx context.Context, baseURL, path string, form url.Values, v interface{}) error {
+	ctx, cancel := m.withTimeout(ctx, "query")
+	defer cancel()
+
+	var (
+		reqUrl = baseURL + path
+		method = endpointMethod(path)
+		body   io.Reader
+	)
+
+	if method == http.MethodGet {
+		reqUrl += "?" + form.Encode()
+	} else {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl, body)
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	m.authenticate(req, "query")
+
+	status, respBody, err := m.do(req)
+
+	if err != nil {
+		return err
+	}
+
+	if status < 200 || status > 299 {
+		return apiError(reqUrl, status, respBody)
+	}
+
+	if err := json.Unmarshal(respBody, v); err != nil {
+		return &MixpanelError{URL: reqUrl, HttpStatus: status, Message: err.Error()}
+	}
+
+	return nil
+}
+
+// apiError builds the error for a failed request to an API that reports
+// failures as a JSON object with an "error" message, such as the query API and
+// /import, along with the failed_records of /import in strict mode.
+func apiError(reqUrl string, status int, body []byte) error {
+	serverErr := &MixpanelError{
+		URL:        reqUrl,
+		HttpStatus: status,
+		Code:       status,
+		RawBody:    rawBody(body),
+	}
+
+	var resp struct {
+		Error         string              `json:"error"`
+		FailedRecords []ImportRecordError `json:"failed_records"`
+	}
+	if json.Unmarshal(body, &resp) == nil && resp.Error != "" {
+		serverErr.Message = resp.Error
+	} else {
+		serverErr.Message = string(body)
+	}
+	serverErr.FailedRecords = resp.FailedRecords
+
+	return serverErr
+}
+
+// profileProperties returns the properties of the profile with the given
+// distinct id, or nil if there is no such profile.
+func (m *mixpanel) profileProperties(distinctId string) (map[string]interface{}, error) {
+	return m.profilePropertiesCtx(context.Background(), distinctId)
+}
+
+// profilePropertiesCtx is like profileProperties, but sends the query with
+// ctx.
+func (m *mixpanel) profilePropertiesCtx(ctx context.Context, distinctId string) (map[string]interface{}, error) {
+	var resp struct {
+		Results []struct {
+			DistinctId string                 `json:"$distinct_id"`
+			Properties map[string]interface{} `json:"$properties"`
+		} `json:"results"`
+	}
+
+	form := url.Values{"distinct_id": {distinctId}}
+	if err := m.queryAt(ctx, m.QueryURL, "/2.0/engage", form, &resp); err != nil {
+		return nil, err
+	}
+
+	for _, profile := range resp.Results {
+		if profile.DistinctId == distinctId {
+			return profile.Properties, nil
+		}
+	}
+
+	return nil, nil
+}
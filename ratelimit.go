@@ -0,0 +1,125 @@
+package mixpanel
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WithRateLimit limits the ingestion calls, such as Track, Update and
+// ImportBatch, to eventsPerSecond events on average, with bursts of up to
+// burst events, so that bursts are smoothed out before Mixpanel answers them
+// with 429s. A request counts as many events as it carries, and calls wait
+// for their turn until their context is done. A batch larger than burst is
+// let through once the bucket is full, and delays the requests after it.
+// Retries count against the limit too.
+//
+// Requests still rejected with HTTP 429 fail with an error matching
+// ErrRateLimited. An eventsPerSecond that is not positive turns the limit
+// off.
+func WithRateLimit(eventsPerSecond float64, burst int) Option {
+	return func(m *mixpanel) {
+		if !(eventsPerSecond > 0) {
+			m.rateLimiter = nil
+			return
+		}
+		if burst < 1 {
+			burst = 1
+		}
+		m.rateLimiter = &tokenBucket{
+			rate:   eventsPerSecond,
+			burst:  float64(burst),
+			tokens: float64(burst),
+			last:   time.Now(),
+		}
+	}
+}
+
+// WithMaxConcurrentRequests limits the number of ingestion requests in flight
+// at the same time to n. Further calls wait for a request to finish until
+// their context is done.
+func WithMaxConcurrentRequests(n int) Option {
+	return func(m *mixpanel) {
+		m.requestSlots = make(chan struct{}, n)
+	}
+}
+
+// tokenBucket is a token-bucket rate limiter. It is safe for concurrent use.
+type tokenBucket struct {
+	rate, burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// wait blocks until n tokens are taken from the bucket, or until ctx is done.
+// The bucket may go into debt for n larger than its size, so that large
+// requests are delayed rather than refused.
+func (b *tokenBucket) wait(ctx context.Context, n int) error {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	need := float64(n)
+	if need > b.burst {
+		need = b.burst
+	}
+	var delay time.Duration
+	if b.tokens < need {
+		delay = time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+	}
+	b.tokens -= float64(n)
+	b.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		b.tokens += float64(n)
+		b.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// throttle waits for the rate limit and a request slot, if the client has
+// them, before a request carrying n events. The returned function releases
+// the slot and must be called once the request is done.
+func (m *mixpanel) throttle(ctx context.Context, n int) (func(), error) {
+	if m.rateLimiter != nil {
+		if err := m.rateLimiter.wait(ctx, n); err != nil {
+			return nil, err
+		}
+	}
+
+	if m.requestSlots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case m.requestSlots <- struct{}{}:
+		return func() { <-m.requestSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// eventCount returns the number of events or records in the payload params.
+func eventCount(params interface{}) int {
+	if records, ok := params.([]map[string]interface{}); ok {
+		return len(records)
+	}
+	return 1
+}
@@ -0,0 +1,109 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithRateLimit(20, 1))
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		client.Track("13793", "Signed Up", &Event{})
+	}
+
+	// The first request takes the burst, the next three wait 50ms each.
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("4 requests at 20 events/s took %v, want at least 140ms", elapsed)
+	}
+}
+
+func TestRateLimitNonPositive(t *testing.T) {
+	for _, rate := range []float64{0, -1, math.NaN()} {
+		client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", "", WithRateLimit(rate, 1)).(*mixpanel)
+		if client.rateLimiter != nil {
+			t.Errorf("WithRateLimit(%v, 1) set a rate limiter, want none", rate)
+		}
+	}
+}
+
+func TestRateLimitContext(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithRateLimit(1, 1))
+	client.Track("13793", "Signed Up", &Event{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := client.TrackCtx(ctx, "13793", "Signed Up", &Event{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("TrackCtx returned %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestTokenBucketBatch(t *testing.T) {
+	b := &tokenBucket{rate: 100, burst: 10, tokens: 10, last: time.Now()}
+
+	// A batch larger than the bucket goes through when the bucket is full,
+	// and the next request waits for the debt to be paid back.
+	start := time.Now()
+	if err := b.wait(context.Background(), 15); err != nil {
+		t.Fatalf("wait returned %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("wait on a full bucket took %v, want no delay", elapsed)
+	}
+	if err := b.wait(context.Background(), 1); err != nil {
+		t.Fatalf("wait returned %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("wait after a large batch took %v, want at least 50ms", elapsed)
+	}
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	var active, maxActive int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			max := atomic.LoadInt32(&maxActive)
+			if n <= max || atomic.CompareAndSwapInt32(&maxActive, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithMaxConcurrentRequests(2))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.Track("13793", "Signed Up", &Event{}); err != nil {
+				t.Errorf("Track returned %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&maxActive); got > 2 {
+		t.Errorf("concurrent requests returned %+v, want at most %+v", got, 2)
+	}
+}
@@ -0,0 +1,90 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A Region is a data residency region. A project's data lives in the region
+// picked when the project was created.
+type Region int
+
+const (
+	US Region = iota
+	EU
+	IN
+)
+
+// regionHosts are the ingestion, query and export base URLs of each region.
+var regionHosts = map[Region][3]string{
+	US: {"https://api.mixpanel.com", "https://mixpanel.com/api", "https://data.mixpanel.com/api"},
+	EU: {"https://api-eu.mixpanel.com", "https://eu.mixpanel.com/api", "https://data-eu.mixpanel.com/api"},
+	IN: {"https://api-in.mixpanel.com", "https://in.mixpanel.com/api", "https://data-in.mixpanel.com/api"},
+}
+
+// WithRegion sends to the hosts of region: the ingestion API, the query API
+// and the export API. WithQueryURL and WithExportURL given after it, and a
+// non-empty apiURL given to New, win over it. Unknown regions are ignored.
+func WithRegion(region Region) Option {
+	return func(m *mixpanel) {
+		hosts, ok := regionHosts[region]
+		if !ok {
+			return
+		}
+		m.ApiURL, m.QueryURL, m.ExportURL = hosts[0], hosts[1], hosts[2]
+	}
+}
+
+// WithEURegion sends to the hosts of the EU data residency region, as
+// WithRegion(EU) does.
+func WithEURegion() Option {
+	return WithRegion(EU)
+}
+
+// WithINRegion sends to the hosts of the India data residency region, as
+// WithRegion(IN) does.
+func WithINRegion() Option {
+	return WithRegion(IN)
+}
+
+// ErrRegionMismatch is wrapped by the error ValidateRegion returns when the
+// endpoint does not know the project token.
+var ErrRegionMismatch = errors.New("mixpanel: project token is not valid for this endpoint; check that the API URL matches the project's data residency region")
+
+// regionCheckId is the profile ValidateRegion sends its no-op update to.
+const regionCheckId = "$mixpanel-go-region-check"
+
+// ValidateRegion checks that the endpoint the client sends to accepts the
+// project token. Each data residency region, such as the US
+// (https://api.mixpanel.com) or the EU (https://api-eu.mixpanel.com), only
+// knows the tokens of its own projects, and otherwise fails every request
+// without anything showing up on the project. Call ValidateRegion at startup
+// to catch the misconfiguration early.
+//
+// The check sends a no-op profile update, an $unset of no properties, so it
+// changes no data. If the endpoint rejects the token, the returned error
+// wraps ErrRegionMismatch and carries Mixpanel's message. Other failures,
+// such as a network error, are returned as is.
+func (m *mixpanel) ValidateRegion(ctx context.Context) error {
+	params := map[string]interface{}{
+		"$token":       m.Token,
+		"$distinct_id": regionCheckId,
+		"$ip":          "0",
+		"$ignore_time": true,
+		"$unset":       []string{},
+	}
+
+	err := m.send(ctx, "engage", params, false, nil)
+
+	var mpErr *MixpanelError
+	if errors.As(err, &mpErr) && mpErr.HttpStatus != 0 && mpErr.HttpStatus < 500 {
+		message := strings.ToLower(mpErr.Message)
+		if strings.Contains(message, "token") || strings.Contains(message, "project") {
+			return fmt.Errorf("%w: %s", ErrRegionMismatch, mpErr.Message)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,90 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateRegion(t *testing.T) {
+	response := `{"status":1,"error":null}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/engage" {
+			t.Errorf("path returned %+v, want %+v", r.URL.Path, "/engage")
+		}
+		w.Write([]byte(response))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+
+	if err := client.ValidateRegion(context.Background()); err != nil {
+		t.Errorf("ValidateRegion returned %v for an accepted token", err)
+	}
+
+	response = `{"status":0,"error":"Invalid token 'e3bc4100330c35722740fb8c6f5abddc'"}`
+	err := client.ValidateRegion(context.Background())
+	if !errors.Is(err, ErrRegionMismatch) {
+		t.Errorf("ValidateRegion returned %v, want %v", err, ErrRegionMismatch)
+	}
+}
+
+type hostRecorder struct {
+	hosts []string
+}
+
+func (r *hostRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.hosts = append(r.hosts, req.URL.Host)
+	return &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(strings.NewReader(`{"status":1,"error":null}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestRegion(t *testing.T) {
+	recorder := &hostRecorder{}
+	c := &http.Client{Transport: recorder}
+
+	client := NewFromClient(c, "e3bc4100330c35722740fb8c6f5abddc", "", "secret", "", WithRegion(EU))
+	client.Track("13793", "Signed Up", &Event{})
+	client.Update("13793", &Update{Operation: "$set", Properties: map[string]interface{}{"Plan": "pro"}})
+	client.Alias("13793", "13794")
+
+	want := []string{"api-eu.mixpanel.com", "api-eu.mixpanel.com", "api-eu.mixpanel.com"}
+	if !reflect.DeepEqual(recorder.hosts, want) {
+		t.Errorf("hosts returned %+v, want %+v", recorder.hosts, want)
+	}
+
+	m := NewWithRegion("e3bc4100330c35722740fb8c6f5abddc", "secret", IN).(*mixpanel)
+	if m.ApiURL != "https://api-in.mixpanel.com" || m.ExportURL != "https://data-in.mixpanel.com/api" {
+		t.Errorf("NewWithRegion returned URLs %+v and %+v", m.ApiURL, m.ExportURL)
+	}
+
+	m = New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", "https://proxy.internal", WithRegion(EU)).(*mixpanel)
+	if m.ApiURL != "https://proxy.internal" || m.QueryURL != "https://eu.mixpanel.com/api" {
+		t.Errorf("New returned URLs %+v and %+v with an explicit API URL", m.ApiURL, m.QueryURL)
+	}
+}
+
+func TestRegionShorthands(t *testing.T) {
+	tests := []struct {
+		opt  Option
+		want [3]string
+	}{
+		{WithEURegion(), regionHosts[EU]},
+		{WithINRegion(), regionHosts[IN]},
+	}
+
+	for _, tt := range tests {
+		m := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", "", tt.opt).(*mixpanel)
+		if got := [3]string{m.ApiURL, m.QueryURL, m.ExportURL}; got != tt.want {
+			t.Errorf("URLs returned %+v, want %+v", got, tt.want)
+		}
+	}
+}
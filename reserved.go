@@ -0,0 +1,93 @@
+package mixpanel
+
+import (
+	"fmt"
+	"time"
+)
+
+// A PropertyKind is the kind of value Mixpanel expects for a reserved
+// property.
+type PropertyKind int
+
+const (
+	// KindString is a Go string.
+	KindString PropertyKind = iota
+
+	// KindNumber is any Go integer or floating point type.
+	KindNumber
+
+	// KindTime is a time.Time or a string holding a formatted date.
+	KindTime
+)
+
+func (k PropertyKind) String() string {
+	switch k {
+	case KindString:
+		return "a string"
+	case KindNumber:
+		return "a number"
+	case KindTime:
+		return "a time.Time or a date string"
+	}
+	return "unknown"
+}
+
+// ReservedPropertyKinds lists the reserved properties whose value type is
+// checked, and the kind of value each must have. Sending a reserved property
+// with a different type, such as $email as an int, silently breaks the
+// Mixpanel features that rely on it. A wrongly typed reserved property fails
+// with a *ValidationError in strict mode and is logged otherwise.
+var ReservedPropertyKinds = map[string]PropertyKind{
+	"$email":        KindString,
+	"$phone":        KindString,
+	"$name":         KindString,
+	"$first_name":   KindString,
+	"$last_name":    KindString,
+	"$avatar":       KindString,
+	"$city":         KindString,
+	"$region":       KindString,
+	"$country_code": KindString,
+	"$timezone":     KindString,
+	"$insert_id":    KindString,
+	"$device_id":    KindString,
+	"$user_id":      KindString,
+	"$current_url":  KindString,
+	"$referrer":     KindString,
+	"$duration":     KindNumber,
+	"$created":      KindTime,
+}
+
+func (k PropertyKind) matches(value interface{}) bool {
+	switch value.(type) {
+	case string:
+		return k == KindString || k == KindTime
+	case time.Time, *time.Time:
+		return k == KindTime
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return k == KindNumber
+	}
+	return false
+}
+
+// checkReservedTypes checks props against ReservedPropertyKinds. eventName is
+// empty for a profile update.
+func (m *mixpanel) checkReservedTypes(eventName string, props map[string]interface{}) error {
+	for key, value := range props {
+		kind, ok := ReservedPropertyKinds[key]
+		if !ok || kind.matches(value) {
+			continue
+		}
+
+		err := &ValidationError{
+			Event:    eventName,
+			Property: key,
+			Reason:   fmt.Sprintf("must be %v, got %T", kind, value),
+		}
+		if m.strict {
+			return err
+		}
+		m.errorf("%v", err)
+	}
+
+	return nil
+}
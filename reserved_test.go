@@ -0,0 +1,61 @@
+package mixpanel
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReservedPropertyTypes(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithStrictMode())
+
+	err := client.Update("13793", &Update{
+		Operation: "$set",
+		Properties: map[string]interface{}{
+			"$email":   "user@email.com",
+			"$created": time.Now(),
+			"plan":     42,
+		},
+	})
+	if _, ok := err.(*ValidationError); ok {
+		t.Errorf("Update returned %v for correctly typed reserved properties", err)
+	}
+
+	LastRequest = nil
+	err = client.Update("13793", &Update{
+		Operation: "$set",
+		Properties: map[string]interface{}{
+			"$email": 42,
+		},
+	})
+
+	want := &ValidationError{Property: "$email", Reason: "must be a string, got int"}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("Update returned %+v, want %+v", err, want)
+	}
+	if LastRequest != nil {
+		t.Errorf("Update sent a request for an invalid update")
+	}
+}
+
+func TestReservedPropertyTypesEvent(t *testing.T) {
+	setup()
+	defer teardown()
+
+	logger := &testLogger{}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithLogger(logger))
+
+	client.Track("13793", "Watched Video", &Event{
+		Properties: map[string]interface{}{
+			"$duration": "90s",
+		},
+	})
+
+	want := []string{"mixpanel: invalid event \"Watched Video\": property \"$duration\" must be a number, got string"}
+	if !reflect.DeepEqual(logger.lines, want) {
+		t.Errorf("logged %+v, want %+v", logger.lines, want)
+	}
+}
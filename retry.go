@@ -0,0 +1,98 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// WithRetries makes the ingestion calls, such as Track, Update, Alias and
+// each chunk of the batch methods, retry failures for which IsRetryable is
+// true, network errors, rate limits (HTTP 429) and server errors (HTTP 5xx),
+// up to maxRetries times. Other errors, including every other 4xx, are
+// returned at once. Retries wait for the delay given by backoff, or by the
+// Retry-After header of the response when there is one, and stop early when
+// the context of the call is done.
+//
+// A request that timed out may have reached Mixpanel, so a retry can
+// duplicate it; set a $insert_id, for example with WithAutoInsertID, to have
+// Mixpanel drop duplicate events.
+//
+// ImportNDJSON, which streams its request body, is sent once.
+func WithRetries(maxRetries int, backoff Backoff) Option {
+	return func(m *mixpanel) {
+		m.maxRetries = maxRetries
+		m.retryBackoff = backoff
+	}
+}
+
+// WithRetryStatuses replaces the HTTP statuses retried by WithRetries, 429
+// and every 5xx by default, with statuses, for example to leave out 501.
+// Network errors are always retried, and cancelled calls never are; with no
+// statuses at all, only network errors are.
+func WithRetryStatuses(statuses ...int) Option {
+	return func(m *mixpanel) {
+		m.retryStatuses = statuses
+		m.retryStatusesSet = true
+	}
+}
+
+// shouldRetry reports whether a request that failed with err is retried.
+func (m *mixpanel) shouldRetry(err error) bool {
+	if !m.retryStatusesSet {
+		return IsRetryable(err)
+	}
+
+	return isRetryableWith(err, func(status int) bool {
+		for _, retried := range m.retryStatuses {
+			if status == retried {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// waitRetry waits before retry number attempt of a request that failed with
+// err. It returns the context error if ctx is done first.
+func (m *mixpanel) waitRetry(ctx context.Context, attempt int, err error) error {
+	delay := m.retryBackoff.Delay(attempt)
+
+	var mpErr *MixpanelError
+	if errors.As(err, &mpErr) && mpErr.RetryAfter > 0 {
+		delay = mpErr.RetryAfter
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryAfter parses the Retry-After header, given either in seconds or as an
+// HTTP date. It returns zero when there is none.
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,143 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetries(t *testing.T) {
+	var attempts int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithRetries(3, Backoff{Base: time.Millisecond}))
+
+	if err := client.Track("13793", "Signed Up", &Event{}); err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Track made %d attempts, want 3", attempts)
+	}
+}
+
+func TestRetriesImportBatch(t *testing.T) {
+	var attempts int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"code":200,"num_records_imported":1,"status":"OK"}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL,
+		WithRetries(1, Backoff{Base: time.Millisecond}))
+
+	if err := client.ImportBatch([]BatchEvent{{DistinctId: "13793", EventName: "Signed Up"}}); err != nil {
+		t.Fatalf("ImportBatch returned %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("ImportBatch made %d attempts, want 2", attempts)
+	}
+}
+
+func TestRetriesClientError(t *testing.T) {
+	var attempts int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"status":0,"error":"invalid data"}`))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithRetries(3, Backoff{Base: time.Millisecond}))
+
+	if err := client.Track("13793", "Signed Up", &Event{}); err == nil {
+		t.Errorf("Track returned no error")
+	}
+	if attempts != 1 {
+		t.Errorf("Track made %d attempts for a 400, want 1", attempts)
+	}
+}
+
+func TestRetriesRetryAfter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithRetries(3, Backoff{Base: time.Millisecond}))
+
+	// The Retry-After delay outlasts the context, so the call gives up
+	// instead of retrying after a millisecond.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := client.TrackCtx(ctx, "13793", "Signed Up", &Event{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("TrackCtx returned %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRetryStatuses(t *testing.T) {
+	var status, attempts int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithRetries(2, Backoff{Base: time.Millisecond}),
+		WithRetryStatuses(http.StatusTooManyRequests, http.StatusServiceUnavailable))
+
+	for _, tc := range []struct {
+		status, attempts int
+	}{
+		{http.StatusTooManyRequests, 3},
+		{http.StatusServiceUnavailable, 3},
+		{http.StatusInternalServerError, 1},
+		{http.StatusBadRequest, 1},
+	} {
+		status, attempts = tc.status, 0
+		client.Track("13793", "Signed Up", &Event{})
+		if attempts != tc.attempts {
+			t.Errorf("Track made %d attempts for HTTP %d, want %d", attempts, tc.status, tc.attempts)
+		}
+	}
+}
+
+func TestRetryStatusesNone(t *testing.T) {
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", "", WithRetryStatuses()).(*mixpanel)
+
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{&MixpanelError{Message: "connection refused"}, true},
+		{&MixpanelError{HttpStatus: http.StatusTooManyRequests}, false},
+		{&MixpanelError{HttpStatus: http.StatusServiceUnavailable}, false},
+		{&MixpanelError{Err: context.Canceled}, false},
+	} {
+		if got := client.shouldRetry(tc.err); got != tc.want {
+			t.Errorf("shouldRetry(%v) returned %v, want %v", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package mixpanel
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+// A Scrubber rewrites the properties of events and profile updates before
+// they are sent, for example to keep personal data from leaving the process
+// in clear. Scrub is called with the name and value of each property, and
+// returns the value to send instead, or false to drop the property.
+//
+// The properties set by the client itself, such as the token, the distinct
+// id, the time and the $insert_id, are never given to a Scrubber.
+type Scrubber interface {
+	Scrub(key string, value interface{}) (interface{}, bool)
+}
+
+// WithScrubber applies s to every property sent by Track, Update,
+// GroupUpdate, the batch methods and the import endpoint, including the
+// default and super properties, and to the IP address of events and
+// updates, sent as the "ip" and "$ip" properties. Values nested in lists or
+// objects are not scrubbed on their own: the property holding them is.
+func WithScrubber(s Scrubber) Option {
+	return func(m *mixpanel) {
+		m.scrubber = s
+	}
+}
+
+// Redacted is the value ScrubRules sends in place of a redacted property.
+const Redacted = "REDACTED"
+
+// ScrubRules is a Scrubber matching property names against patterns, in the
+// syntax of path.Match and ignoring case. A property matching a Drop pattern
+// is dropped, one matching a Redact pattern is sent as Redacted, and one
+// matching a Hash pattern is sent as the hex-encoded SHA-256 hash of its
+// value, keyed with Key as an HMAC when Key is set. String values are
+// hashed as they are, and other values as their JSON encoding. Properties
+// matching no pattern, and nil values, are sent unchanged.
+type ScrubRules struct {
+	Drop   []string
+	Redact []string
+	Hash   []string
+
+	// Key is the HMAC key of the hashes. Without it, hashes of values that
+	// are easy to guess, such as phone numbers, can be reversed by trying
+	// them all.
+	Key []byte
+}
+
+// PIIScrubber returns ScrubRules for the personal data commonly sent to
+// Mixpanel: emails and phone numbers are hashed with key, so that they can
+// still be counted and joined on, IP addresses are dropped, and tokens,
+// passwords, secrets and API keys are redacted.
+//
+// Dropping the IP address of an event or update also turns off its
+// geolocation, since Mixpanel would otherwise use the address of the
+// request.
+func PIIScrubber(key []byte) *ScrubRules {
+	return &ScrubRules{
+		Drop:   []string{"ip", "$ip", "*_ip", "ip_address"},
+		Redact: []string{"*token*", "*password*", "*secret*", "api_key", "*_api_key"},
+		Hash:   []string{"email", "$email", "*_email", "phone", "$phone", "*_phone"},
+		Key:    key,
+	}
+}
+
+// Scrub implements Scrubber.
+func (r *ScrubRules) Scrub(key string, value interface{}) (interface{}, bool) {
+	if value == nil {
+		return nil, true
+	}
+
+	key = strings.ToLower(key)
+	switch {
+	case matchScrubPattern(r.Drop, key):
+		return nil, false
+	case matchScrubPattern(r.Redact, key):
+		return Redacted, true
+	case matchScrubPattern(r.Hash, key):
+		return r.hash(value), true
+	}
+
+	return value, true
+}
+
+func (r *ScrubRules) hash(value interface{}) string {
+	data, ok := value.(string)
+	if !ok {
+		encoded, _ := json.Marshal(value)
+		data = string(encoded)
+	}
+
+	if len(r.Key) > 0 {
+		mac := hmac.New(sha256.New, r.Key)
+		mac.Write([]byte(data))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// matchScrubPattern reports whether key, in lower case, matches one of
+// patterns.
+func matchScrubPattern(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// unscrubbedProperties are the properties set by the client itself, which
+// are never given to the Scrubber.
+var unscrubbedProperties = map[string]bool{
+	"token":        true,
+	"$token":       true,
+	"distinct_id":  true,
+	"$distinct_id": true,
+	"time":         true,
+	"$time":        true,
+	"$insert_id":   true,
+}
+
+// scrubProperties returns props as rewritten by the Scrubber of
+// WithScrubber, if any. props itself is left unchanged.
+func (m *mixpanel) scrubProperties(props map[string]interface{}) map[string]interface{} {
+	if m.scrubber == nil || props == nil {
+		return props
+	}
+
+	scrubbed := make(map[string]interface{}, len(props))
+	for key, value := range props {
+		if value, ok := m.scrub(key, value); ok {
+			scrubbed[key] = value
+		}
+	}
+	return scrubbed
+}
+
+// scrub returns the value of the property key as rewritten by the Scrubber
+// of WithScrubber, and false if it is dropped.
+func (m *mixpanel) scrub(key string, value interface{}) (interface{}, bool) {
+	if m.scrubber == nil || unscrubbedProperties[key] {
+		return value, true
+	}
+	return m.scrubber.Scrub(key, value)
+}
@@ -0,0 +1,91 @@
+package mixpanel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestScrubRules(t *testing.T) {
+	rules := PIIScrubber(nil)
+	sum := sha256.Sum256([]byte("john@example.com"))
+	hashed := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		key   string
+		value interface{}
+		want  interface{}
+		kept  bool
+	}{
+		{"$email", "john@example.com", hashed, true},
+		{"Billing_Email", "john@example.com", hashed, true},
+		{"ip", "203.0.113.9", nil, false},
+		{"client_ip", "203.0.113.9", nil, false},
+		{"session_token", "abc", Redacted, true},
+		{"Password", "hunter2", Redacted, true},
+		{"$email", nil, nil, true},
+		{"Plan", "pro", "pro", true},
+	}
+
+	for _, tt := range tests {
+		got, kept := rules.Scrub(tt.key, tt.value)
+		if got != tt.want || kept != tt.kept {
+			t.Errorf("Scrub(%q) returned %+v, %v, want %+v, %v", tt.key, got, kept, tt.want, tt.kept)
+		}
+	}
+
+	keyed := PIIScrubber([]byte("key"))
+	if got, _ := keyed.Scrub("$email", "john@example.com"); got == hashed {
+		t.Errorf("Scrub with a key returned the unkeyed hash %+v", got)
+	}
+}
+
+func TestWithScrubberTrack(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithScrubber(&ScrubRules{Drop: []string{"ip"}, Redact: []string{"plan"}}))
+	client.Track("13793", "Signed Up", &Event{
+		IP:         "203.0.113.9",
+		Properties: map[string]interface{}{"Plan": "pro", "Referred By": "Friend"},
+	})
+
+	want := "{\"event\":\"Signed Up\",\"properties\":{\"Plan\":\"REDACTED\",\"Referred By\":\"Friend\",\"distinct_id\":\"13793\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("data returned %+v, want %+v", got, want)
+	}
+}
+
+func TestWithScrubberUpdate(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithScrubber(PIIScrubber(nil)))
+
+	props := map[string]interface{}{"$email": "john@example.com"}
+	client.Update("13793", &Update{
+		IP:         "203.0.113.9",
+		Operation:  OpSet,
+		Properties: props,
+	})
+
+	var got map[string]interface{}
+	json.Unmarshal([]byte(decodeData(LastRequest)), &got)
+
+	sum := sha256.Sum256([]byte("john@example.com"))
+	want := map[string]interface{}{
+		"$token":       "e3bc4100330c35722740fb8c6f5abddc",
+		"$distinct_id": "13793",
+		"$set":         map[string]interface{}{"$email": hex.EncodeToString(sum[:])},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("data returned %+v, want %+v", got, want)
+	}
+
+	if props["$email"] != "john@example.com" {
+		t.Errorf("Update changed the properties of the caller to %+v", props)
+	}
+}
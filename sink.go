@@ -0,0 +1,26 @@
+package mixpanel
+
+import "context"
+
+// A Sink receives the payloads of the ingestion calls, such as Track, Update
+// and Alias, in place of the HTTP transport, for example to write them to a
+// file or a message queue, or to inspect them in tests. endpoint is "track",
+// "import", "engage" or "groups", and payload is the JSON document Mixpanel
+// would have received, before base64 encoding. The error returned by Send is
+// returned by the call.
+//
+// A Sink gets the payloads only: HTTP concerns like automatic geolocation
+// from the request IP and headers given with RequestHeader do not apply.
+// Calls that are not plain ingestion calls, such as ImportNDJSON, the query
+// methods and Export, still use HTTP.
+type Sink interface {
+	Send(ctx context.Context, endpoint string, payload []byte) error
+}
+
+// WithSink sends the payloads of the ingestion calls to sink instead of
+// Mixpanel's HTTP API, which is the default.
+func WithSink(sink Sink) Option {
+	return func(m *mixpanel) {
+		m.sink = sink
+	}
+}
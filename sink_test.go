@@ -0,0 +1,54 @@
+package mixpanel
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type memorySink struct {
+	payloads []string
+}
+
+func (s *memorySink) Send(ctx context.Context, endpoint string, payload []byte) error {
+	s.payloads = append(s.payloads, endpoint+" "+string(payload))
+	return nil
+}
+
+func TestSink(t *testing.T) {
+	sink := &memorySink{}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", "", WithSink(sink))
+
+	if err := client.Track("13793", "Signed Up", &Event{}); err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+	if err := client.Update("13793", &Update{Operation: "$set", Properties: map[string]interface{}{"plan": "pro"}}); err != nil {
+		t.Fatalf("Update returned %v", err)
+	}
+
+	want := []string{
+		"track {\"event\":\"Signed Up\",\"properties\":{\"distinct_id\":\"13793\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}",
+		"engage {\"$distinct_id\":\"13793\",\"$set\":{\"plan\":\"pro\"},\"$token\":\"e3bc4100330c35722740fb8c6f5abddc\"}",
+	}
+	if !reflect.DeepEqual(sink.payloads, want) {
+		t.Errorf("payloads returned %+v, want %+v", sink.payloads, want)
+	}
+}
+
+func TestSinkImportBatch(t *testing.T) {
+	sink := &memorySink{}
+	now := time.Unix(1700000000, 0)
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", "", WithSink(sink), WithClock(fixedClock(now)))
+
+	if err := client.ImportBatch([]BatchEvent{{DistinctId: "13793", EventName: "Signed Up"}}); err != nil {
+		t.Fatalf("ImportBatch returned %v", err)
+	}
+
+	want := []string{
+		"import [{\"event\":\"Signed Up\",\"properties\":{\"distinct_id\":\"13793\",\"time\":1700000000000,\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}]",
+	}
+	if !reflect.DeepEqual(sink.payloads, want) {
+		t.Errorf("payloads returned %+v, want %+v", sink.payloads, want)
+	}
+}
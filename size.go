@@ -0,0 +1,96 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrEventTooLarge is matched by the error returned for an event or update
+// whose payload is larger than a single request may be, which no splitting
+// can send. See MaxPayloadBytes.
+var ErrEventTooLarge = errors.New("mixpanel: event too large for a request")
+
+const (
+	// MaxPayloadBytes is the largest JSON payload, before base64 encoding,
+	// the client sends to /track, /engage and /groups in a single request.
+	MaxPayloadBytes = 1 << 20
+
+	// MaxImportPayloadBytes is the largest JSON payload the client sends to
+	// /import in a single request.
+	MaxImportPayloadBytes = 10 << 20
+
+	// MaxURLLength is the longest URL the client sends. It bounds the
+	// payloads sent in the URL with WithQueryStringData.
+	MaxURLLength = 2048
+)
+
+// payloadLimit returns the largest JSON payload send may send to endpoint.
+func (m *mixpanel) payloadLimit(endpoint string) int {
+	limit := MaxPayloadBytes
+	if endpoint == "import" {
+		limit = MaxImportPayloadBytes
+	}
+
+	if m.queryData && !m.rawJSONBody {
+		// The data goes in the URL, base64-encoded, with the other
+		// parameters.
+		room := MaxURLLength - len(m.ApiURL+"/"+endpoint+"?data=&ip=1&verbose=1")
+		if urlLimit := room / 4 * 3; urlLimit < limit {
+			limit = urlLimit
+		}
+	}
+
+	return limit
+}
+
+// checkPayloadSize fails with an error matching ErrEventTooLarge if the
+// payload data is larger than limit.
+func checkPayloadSize(data []byte, limit int) error {
+	if len(data) <= limit {
+		return nil
+	}
+	return fmt.Errorf("%w: payload is %d bytes, more than the limit of %d", ErrEventTooLarge, len(data), limit)
+}
+
+// chunkRecords splits records into chunks of at most max records whose JSON
+// array fits in limit bytes, returned as the bounds of the chunks in
+// records. A record that does not fit on its own fails with a
+// *BatchItemError, whose Index is that of the record and which the caller
+// completes.
+func chunkRecords(records []map[string]interface{}, max, limit int) ([][2]int, error) {
+	var (
+		chunks [][2]int
+		start  int
+		size   = 2 // The brackets of the array.
+	)
+
+	for i, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkPayloadSize(data, limit-2); err != nil {
+			return nil, &BatchItemError{Index: i, Err: err}
+		}
+
+		// Records after the first are preceded by a comma.
+		recordSize := len(data)
+		if i > start {
+			recordSize++
+		}
+
+		if i-start == max || size+recordSize > limit {
+			chunks = append(chunks, [2]int{start, i})
+			start, size = i, 2
+			recordSize = len(data)
+		}
+		size += recordSize
+	}
+
+	if start < len(records) {
+		chunks = append(chunks, [2]int{start, len(records)})
+	}
+
+	return chunks, nil
+}
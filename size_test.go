@@ -0,0 +1,74 @@
+package mixpanel
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChunkRecords(t *testing.T) {
+	record := map[string]interface{}{"a": "xxxx"} // {"a":"xxxx"}, 12 bytes
+	records := []map[string]interface{}{record, record, record, record, record}
+
+	tests := []struct {
+		max, limit int
+		want       [][2]int
+	}{
+		{50, 1000, [][2]int{{0, 5}}},
+		{2, 1000, [][2]int{{0, 2}, {2, 4}, {4, 5}}},
+		// Two records take 2+12+1+12 = 27 bytes.
+		{50, 27, [][2]int{{0, 2}, {2, 4}, {4, 5}}},
+		{50, 26, [][2]int{{0, 1}, {1, 2}, {2, 3}, {3, 4}, {4, 5}}},
+	}
+
+	for _, tt := range tests {
+		got, err := chunkRecords(records, tt.max, tt.limit)
+		if err != nil {
+			t.Fatalf("chunkRecords returned %v", err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("chunkRecords(%d, %d) returned %+v, want %+v", tt.max, tt.limit, got, tt.want)
+		}
+	}
+
+	_, err := chunkRecords(records, 50, 13)
+	var itemErr *BatchItemError
+	if !errors.As(err, &itemErr) || itemErr.Index != 0 || !errors.Is(err, ErrEventTooLarge) {
+		t.Errorf("chunkRecords returned %v, want a *BatchItemError for record 0", err)
+	}
+}
+
+func TestEventTooLarge(t *testing.T) {
+	setup()
+	defer teardown()
+	LastRequest = nil
+
+	large := strings.Repeat("x", MaxPayloadBytes)
+	if err := client.Track("13793", "Signed Up", &Event{Properties: map[string]interface{}{"Blob": large}}); !errors.Is(err, ErrEventTooLarge) {
+		t.Errorf("Track returned %v, want %v", err, ErrEventTooLarge)
+	}
+
+	err := client.TrackBatch([]BatchEvent{
+		{DistinctId: "13793", EventName: "Signed Up"},
+		{DistinctId: "13794", EventName: "Signed Up", Event: Event{Properties: map[string]interface{}{"Blob": large}}},
+	})
+	var itemErr *BatchItemError
+	if !errors.As(err, &itemErr) || itemErr.Index != 1 || itemErr.DistinctId != "13794" || !errors.Is(err, ErrEventTooLarge) {
+		t.Errorf("TrackBatch returned %v, want a *BatchItemError for item 1", err)
+	}
+
+	if LastRequest != nil {
+		t.Errorf("a request was sent for an event too large")
+	}
+}
+
+func TestQueryStringPayloadLimit(t *testing.T) {
+	m := New("e3bc4100330c35722740fb8c6f5abddc", "", "", "https://api.mixpanel.com", WithQueryStringData()).(*mixpanel)
+
+	limit := m.payloadLimit("track")
+	url := m.ApiURL + "/track?data=" + m.to64(make([]byte, limit)) + "&ip=1&verbose=1"
+	if len(url) > MaxURLLength {
+		t.Errorf("URL for a payload of %d bytes is %d long, more than %d", limit, len(url), MaxURLLength)
+	}
+}
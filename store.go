@@ -0,0 +1,74 @@
+package mixpanel
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// A Store persists the operations queued by a Buffered client, given as
+// BufferOptions.Store. It can be backed by a file, see NewFileStore, or by a
+// database such as Redis or SQL. A Buffered client calls its store from one
+// goroutine at a time.
+type Store interface {
+	// Append adds ops to the end of the store.
+	Append(ops []Operation) error
+
+	// Load returns the operations in the store, in order.
+	Load() ([]Operation, error)
+
+	// Remove removes the first n operations from the store.
+	Remove(n int) error
+}
+
+// FileStore is a Store keeping operations in a file, one JSON-encoded
+// Operation per line, like BufferOptions.SpillPath.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore returns a FileStore keeping operations in the file at path,
+// which is created when needed.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Append appends ops to the file.
+func (s *FileStore) Append(ops []Operation) error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	return writeOperations(f, ops)
+}
+
+// Load reads the operations in the file. A missing file holds no operations.
+func (s *FileStore) Load() ([]Operation, error) {
+	return readOperations(s.path)
+}
+
+// Remove rewrites the file without its first n operations, through a
+// temporary file so that a crash leaves either the old or the new file.
+func (s *FileStore) Remove(n int) error {
+	ops, err := s.Load()
+	if err != nil {
+		return err
+	}
+	if n >= len(ops) {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	if err := writeOperations(f, ops[n:]); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path)
+}
@@ -0,0 +1,89 @@
+package mixpanel
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	s := NewFileStore(filepath.Join(t.TempDir(), "queue.ndjson"))
+
+	if ops, err := s.Load(); err != nil || len(ops) != 0 {
+		t.Fatalf("Load returned %+v, %v, want no operations", ops, err)
+	}
+
+	ops := []Operation{
+		AliasOperation("13793", "john"),
+		TrackOperation("13793", "Signed Up", &Event{}),
+		TrackOperation("13793", "Logged In", &Event{}),
+	}
+	if err := s.Append(ops[:1]); err != nil {
+		t.Fatalf("Append returned %v", err)
+	}
+	if err := s.Append(ops[1:]); err != nil {
+		t.Fatalf("Append returned %v", err)
+	}
+
+	if err := s.Remove(1); err != nil {
+		t.Fatalf("Remove returned %v", err)
+	}
+	got, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load returned %v", err)
+	}
+	if !reflect.DeepEqual(got, ops[1:]) {
+		t.Errorf("Load returned %+v, want %+v", got, ops[1:])
+	}
+
+	if err := s.Remove(2); err != nil {
+		t.Fatalf("Remove returned %v", err)
+	}
+	if got, _ := s.Load(); len(got) != 0 {
+		t.Errorf("Load returned %+v, want no operations", got)
+	}
+}
+
+func TestBufferedStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.ndjson")
+
+	// Operations queued before a crash are replayed by the next client.
+	b, err := NewBuffered(NewMock(), BufferOptions{Store: NewFileStore(path)})
+	if err != nil {
+		t.Fatalf("NewBuffered returned %v", err)
+	}
+	b.Track("13793", "1", &Event{})
+	b.Track("13793", "2", &Event{})
+
+	mock := NewMock()
+	b, err = NewBuffered(mock, BufferOptions{Store: NewFileStore(path)})
+	if err != nil {
+		t.Fatalf("NewBuffered returned %v", err)
+	}
+	if got := b.Len(); got != 2 {
+		t.Errorf("Len returned %+v, want %+v", got, 2)
+	}
+	b.Track("13793", "3", &Event{})
+
+	if err := b.Flush(); err != nil {
+		t.Fatalf("Flush returned %v", err)
+	}
+	if got, want := eventNames(mock.People["13793"]), []string{"1", "2", "3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Flush sent %+v, want %+v", got, want)
+	}
+	if ops, _ := NewFileStore(path).Load(); len(ops) != 0 {
+		t.Errorf("store holds %+v after Flush, want nothing", ops)
+	}
+
+	// A failed operation stays in the store.
+	b.Track("13793", "4", &Event{})
+	failed := &MixpanelError{HttpStatus: 503, Message: "unavailable"}
+	mock.FailNext(failed)
+	if err := b.Flush(); !errors.Is(err, failed) {
+		t.Errorf("Flush returned %v, want %v", err, failed)
+	}
+	if ops, _ := NewFileStore(path).Load(); len(ops) != 1 || ops[0].EventName != "4" {
+		t.Errorf("store holds %+v, want event 4", ops)
+	}
+}
@@ -0,0 +1,73 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// WithStrictImport sends the requests to /import, from Import, ImportBatch and
+// ImportNDJSON, in Mixpanel's strict mode: the events are validated, and a
+// request with invalid events fails with a *MixpanelError whose
+// FailedRecords say which events were rejected and why. The valid events of
+// the request are imported.
+func WithStrictImport() Option {
+	return func(m *mixpanel) {
+		m.strictImport = true
+	}
+}
+
+// An ImportRecordError describes an event rejected by /import in strict mode.
+type ImportRecordError struct {
+	// Index of the event in the request. BatchError.FailedRecords turns it
+	// into an index in the events given to the batch method.
+	Index int `json:"index"`
+
+	InsertId string `json:"$insert_id"`
+
+	// Field is the invalid field, such as "properties.time".
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// importQuery returns the query parameters of the requests to /import, with
+// a trailing "&", or "".
+func (m *mixpanel) importQuery() string {
+	if m.strictImport {
+		return "strict=1&"
+	}
+	return ""
+}
+
+// failedRecords returns the failed_records of body, the response of /import
+// in strict mode, if any.
+func failedRecords(body []byte) []ImportRecordError {
+	var resp struct {
+		FailedRecords []ImportRecordError `json:"failed_records"`
+	}
+	if json.Unmarshal(body, &resp) != nil {
+		return nil
+	}
+	return resp.FailedRecords
+}
+
+// FailedRecords returns the events rejected by /import in strict mode in the
+// failed chunks, with their Index counting from the start of the events
+// given to the batch method, through ChunkError.Indexes when it is set.
+func (err *BatchError) FailedRecords() []ImportRecordError {
+	var records []ImportRecordError
+	for _, c := range err.Chunks {
+		var mpErr *MixpanelError
+		if !errors.As(c.Err, &mpErr) {
+			continue
+		}
+		for _, r := range mpErr.FailedRecords {
+			if c.Indexes != nil && r.Index >= 0 && r.Index < len(c.Indexes) {
+				r.Index = c.Indexes[r.Index]
+			} else {
+				r.Index += c.Start
+			}
+			records = append(records, r)
+		}
+	}
+	return records
+}
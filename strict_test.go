@@ -0,0 +1,118 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const strictImportResponse = `{"code":400,"error":"some data points in the request failed validation","failed_records":[{"index":1,"$insert_id":"b","field":"properties.time","message":"'properties.time' is invalid: must not be missing"}],"num_records_imported":1,"status":"Bad Request"}`
+
+func TestStrictImportBatch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("strict"); got != "1" {
+			t.Errorf("strict returned %+v, want %+v", got, "1")
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(strictImportResponse))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL, WithStrictImport())
+
+	at := time.Now()
+	err := client.ImportBatch([]BatchEvent{
+		{DistinctId: "13793", EventName: "a", Event: Event{Timestamp: &at}},
+		{DistinctId: "13793", EventName: "b", Event: Event{Timestamp: &at}},
+	})
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("ImportBatch returned %v, want a *BatchError", err)
+	}
+	want := []ImportRecordError{{
+		Index:    1,
+		InsertId: "b",
+		Field:    "properties.time",
+		Message:  "'properties.time' is invalid: must not be missing",
+	}}
+	if got := batchErr.FailedRecords(); !reflect.DeepEqual(got, want) {
+		t.Errorf("FailedRecords returned %+v, want %+v", got, want)
+	}
+}
+
+func TestStrictImport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("strict"); got != "1" {
+			t.Errorf("strict returned %+v, want %+v", got, "1")
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(strictImportResponse))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL, WithStrictImport())
+
+	at := time.Now()
+	err := client.Import("13793", "Signed Up", &Event{Timestamp: &at})
+
+	var mpErr *MixpanelError
+	if !errors.As(err, &mpErr) || len(mpErr.FailedRecords) != 1 || mpErr.FailedRecords[0].Field != "properties.time" {
+		t.Errorf("Import returned %+v, want a failed record", err)
+	}
+}
+
+func TestFailedRecordsWrapped(t *testing.T) {
+	record := ImportRecordError{Index: 1, InsertId: "b", Field: "properties.time"}
+	batchErr := &BatchError{Chunks: []ChunkError{{
+		Start: 2,
+		End:   4,
+		Err:   fmt.Errorf("middleware: %w", &MixpanelError{FailedRecords: []ImportRecordError{record}}),
+	}}}
+
+	record.Index = 3
+	want := []ImportRecordError{record}
+	if got := batchErr.FailedRecords(); !reflect.DeepEqual(got, want) {
+		t.Errorf("FailedRecords returned %+v, want %+v", got, want)
+	}
+}
+
+func TestStrictImportBatchTokens(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var records []ExportedEvent
+		json.NewDecoder(r.Body).Decode(&records)
+		if records[0].Properties["token"] != "tenant-a" {
+			w.Write([]byte(`{"code":200,"num_records_imported":1,"status":"OK"}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(strictImportResponse))
+	}))
+	defer ts.Close()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "secret", ts.URL, WithStrictImport())
+
+	at := time.Now()
+	event := Event{Timestamp: &at}
+	err := client.ImportBatch([]BatchEvent{
+		{DistinctId: "13793", EventName: "a", Event: event, Token: "tenant-a"},
+		{DistinctId: "13794", EventName: "a", Event: event, Token: "tenant-b"},
+		{DistinctId: "13795", EventName: "b", Event: event, Token: "tenant-a"},
+	})
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Chunks) != 1 {
+		t.Fatalf("ImportBatch returned %v, want a *BatchError for one chunk", err)
+	}
+	if got, want := batchErr.Chunks[0].Indexes, []int{0, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Indexes returned %+v, want %+v", got, want)
+	}
+	if got := batchErr.FailedRecords(); len(got) != 1 || got[0].Index != 2 {
+		t.Errorf("FailedRecords returned %+v, want the event at index 2", got)
+	}
+}
@@ -0,0 +1,101 @@
+package mixpanel
+
+import "context"
+
+// A Tracer starts the spans WithTracer puts around the ingestion calls. It is
+// shaped so that an OpenTelemetry tracer can back it in a few lines, without
+// this package depending on OpenTelemetry:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, mixpanel.Span) {
+//		ctx, span := t.Tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//		return ctx, otelSpan{span}
+//	}
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// A Span is a span started by a Tracer. value is a string or an int.
+type Span interface {
+	SetAttribute(key string, value interface{})
+
+	// End ends the span, err being the error returned by the call, if any.
+	End(err error)
+}
+
+// A Meter adds n to the counter name, for WithMeter. attrs maps attribute
+// names to strings.
+type Meter interface {
+	Add(ctx context.Context, name string, n int64, attrs map[string]string)
+}
+
+// WithTracer starts a span with tracer around every ingestion call, such as
+// Track, Update, Import and each chunk of the batch calls. A span is named
+// after the endpoint, as in "mixpanel.track", and has these attributes:
+//
+//   - "mixpanel.endpoint": the endpoint, "track", "import", "engage" or
+//     "groups";
+//   - "mixpanel.event": the name of the event, for a single event;
+//   - "mixpanel.batch_size": the number of events or updates sent;
+//   - "mixpanel.retries": the number of retries;
+//   - "http.status_code": the HTTP status of the last response, if any;
+//   - "mixpanel.status": its verbose status, if any.
+//
+// Without WithTracer, no span is started.
+func WithTracer(tracer Tracer) Option {
+	return func(m *mixpanel) {
+		m.tracer = tracer
+	}
+}
+
+// WithMeter counts the events and updates sent by the ingestion calls with
+// meter, in the counters "mixpanel.events.sent" and "mixpanel.events.failed",
+// with the attribute "mixpanel.endpoint". Without WithMeter, nothing is
+// counted.
+func WithMeter(meter Meter) Option {
+	return func(m *mixpanel) {
+		m.meter = meter
+	}
+}
+
+// startSpan starts the span of a call sending events records of params to
+// endpoint. The span is nil without a tracer.
+func (m *mixpanel) startSpan(ctx context.Context, endpoint string, params interface{}, events int) (context.Context, Span) {
+	if m.tracer == nil {
+		return ctx, nil
+	}
+
+	ctx, span := m.tracer.Start(ctx, "mixpanel."+endpoint)
+	span.SetAttribute("mixpanel.endpoint", endpoint)
+	if record, ok := params.(map[string]interface{}); ok {
+		if name, ok := record["event"].(string); ok {
+			span.SetAttribute("mixpanel.event", name)
+		}
+	}
+	span.SetAttribute("mixpanel.batch_size", events)
+	return ctx, span
+}
+
+// endCall ends span, which may be nil, and counts the events of the call.
+// resp is the last response, if any.
+func (m *mixpanel) endCall(ctx context.Context, span Span, endpoint string, events, retries int, resp *Response, err error) {
+	if span != nil {
+		span.SetAttribute("mixpanel.retries", retries)
+		if resp != nil {
+			span.SetAttribute("http.status_code", resp.HttpStatus)
+			if resp.Status != "" {
+				span.SetAttribute("mixpanel.status", resp.Status)
+			}
+		}
+		span.End(err)
+	}
+
+	if m.meter != nil {
+		name := "mixpanel.events.sent"
+		if err != nil {
+			name = "mixpanel.events.failed"
+		}
+		m.meter.Add(ctx, name, int64(events), map[string]string{"mixpanel.endpoint": endpoint})
+	}
+}
@@ -0,0 +1,102 @@
+package mixpanel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type recordedSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	ended bool
+}
+
+func (s *recordedSpan) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *recordedSpan) End(err error) {
+	s.err = err
+	s.ended = true
+}
+
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &recordedSpan{name: name, attrs: map[string]interface{}{}}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+type recordingMeter struct {
+	counts map[string]int64
+}
+
+func (m *recordingMeter) Add(ctx context.Context, name string, n int64, attrs map[string]string) {
+	m.counts[name+" "+attrs["mixpanel.endpoint"]] += n
+}
+
+func TestTracer(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	tracer := &recordingTracer{}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithTracer(tracer), WithRetries(1, Backoff{Base: time.Millisecond}))
+
+	if err := client.Track("13793", "Signed Up", &Event{}); err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+
+	want := []*recordedSpan{{
+		name: "mixpanel.track",
+		attrs: map[string]interface{}{
+			"mixpanel.endpoint":   "track",
+			"mixpanel.event":      "Signed Up",
+			"mixpanel.batch_size": 1,
+			"mixpanel.retries":    1,
+			"http.status_code":    200,
+			"mixpanel.status":     "1",
+		},
+		ended: true,
+	}}
+	if !reflect.DeepEqual(tracer.spans, want) {
+		t.Errorf("spans returned %+v, want %+v", tracer.spans, want)
+	}
+}
+
+func TestMeter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/engage" {
+			w.Write([]byte(`{"status":0,"error":"invalid"}`))
+			return
+		}
+		w.Write([]byte(`{"status":1,"error":null}`))
+	}))
+	defer ts.Close()
+
+	meter := &recordingMeter{counts: map[string]int64{}}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithMeter(meter))
+
+	client.TrackBatch([]BatchEvent{{DistinctId: "13793", EventName: "Signed Up"}, {DistinctId: "13794", EventName: "Signed Up"}})
+	client.Update("13793", &Update{Operation: OpSet, Properties: map[string]interface{}{"Plan": "pro"}})
+
+	want := map[string]int64{"mixpanel.events.sent track": 2, "mixpanel.events.failed engage": 1}
+	if !reflect.DeepEqual(meter.counts, want) {
+		t.Errorf("counts returned %+v, want %+v", meter.counts, want)
+	}
+}
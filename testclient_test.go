@@ -0,0 +1,27 @@
+package mixpanel
+
+import "testing"
+
+func TestNewTestClient(t *testing.T) {
+	client, recorded := NewTestClient()
+
+	if err := client.Track("13793", "Signed Up", &Event{IP: "0"}); err != nil {
+		t.Fatalf("Track returned %v", err)
+	}
+
+	last := recorded.Last()
+	if last == nil {
+		t.Fatalf("no request was recorded")
+	}
+
+	want := "{\"event\":\"Signed Up\",\"properties\":{\"distinct_id\":\"13793\",\"ip\":\"0\",\"token\":\"test-token\"}}"
+	if last.Payload != want {
+		t.Errorf("Payload returned %+v, want %+v", last.Payload, want)
+	}
+	if got := last.Header.Get("Authorization"); got != "Basic dGVzdC1zZWNyZXQ6" {
+		t.Errorf("Authorization returned %+v, want %+v", got, "Basic dGVzdC1zZWNyZXQ6")
+	}
+	if len(recorded.All()) != 1 {
+		t.Errorf("recorded %d requests, want 1", len(recorded.All()))
+	}
+}
@@ -0,0 +1,42 @@
+package mixpanel
+
+import (
+	"context"
+	"time"
+)
+
+// WithEndpointTimeout bounds the calls to endpoint by d, for example a few
+// seconds for "track" and "engage" but minutes for "export". endpoint is one
+// of the ingestion endpoints "track", "import", "engage" and "groups",
+// "query" for the query API, including JQL, or "export" for Export. The
+// timeout applies to a whole call, retries included, on top of any deadline
+// of its context and of the http.Client. Endpoints without a timeout have
+// none beyond those and the one given to WithTimeout.
+func WithEndpointTimeout(endpoint string, d time.Duration) Option {
+	return func(m *mixpanel) {
+		if m.timeouts == nil {
+			m.timeouts = map[string]time.Duration{}
+		}
+		m.timeouts[endpoint] = d
+	}
+}
+
+// WithTimeout bounds the calls to every endpoint without a timeout of its own
+// from WithEndpointTimeout by d, in the same way.
+func WithTimeout(d time.Duration) Option {
+	return func(m *mixpanel) {
+		m.defaultTimeout = d
+	}
+}
+
+// withTimeout returns ctx bounded by the timeout of endpoint, if any.
+func (m *mixpanel) withTimeout(ctx context.Context, endpoint string) (context.Context, context.CancelFunc) {
+	d, ok := m.timeouts[endpoint]
+	if !ok {
+		d = m.defaultTimeout
+	}
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
@@ -0,0 +1,62 @@
+package mixpanel
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type deadlineSink struct {
+	deadlines map[string]time.Duration
+}
+
+func (s *deadlineSink) Send(ctx context.Context, endpoint string, payload []byte) error {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		s.deadlines[endpoint] = 0
+		return nil
+	}
+	s.deadlines[endpoint] = time.Until(deadline)
+	return nil
+}
+
+func TestEndpointTimeout(t *testing.T) {
+	sink := &deadlineSink{deadlines: map[string]time.Duration{}}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", "", WithSink(sink),
+		WithEndpointTimeout("track", 2*time.Second),
+		WithEndpointTimeout("engage", time.Minute))
+
+	client.Track("13793", "Signed Up", &Event{})
+	client.Update("13793", &Update{Operation: "$set", Properties: map[string]interface{}{"Plan": "pro"}})
+
+	if d := sink.deadlines["track"]; d <= 0 || d > 2*time.Second {
+		t.Errorf("track deadline returned %v, want at most 2s", d)
+	}
+	if d := sink.deadlines["engage"]; d <= 2*time.Second || d > time.Minute {
+		t.Errorf("engage deadline returned %v, want at most 1m", d)
+	}
+
+	m := client.(*mixpanel)
+	ctx, cancel := m.withTimeout(context.Background(), "export")
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("export has a deadline without a timeout")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	sink := &deadlineSink{deadlines: map[string]time.Duration{}}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", "", WithSink(sink),
+		WithTimeout(time.Minute),
+		WithEndpointTimeout("track", 2*time.Second))
+
+	client.Track("13793", "Signed Up", &Event{})
+	client.Update("13793", &Update{Operation: "$set", Properties: map[string]interface{}{"Plan": "pro"}})
+
+	if d := sink.deadlines["track"]; d <= 0 || d > 2*time.Second {
+		t.Errorf("track deadline returned %v, want at most 2s", d)
+	}
+	if d := sink.deadlines["engage"]; d <= 2*time.Second || d > time.Minute {
+		t.Errorf("engage deadline returned %v, want at most 1m", d)
+	}
+}
@@ -0,0 +1,42 @@
+package mixpanel
+
+import "time"
+
+// A Timer measures the duration of an event started with StartTimer.
+type Timer struct {
+	client     Mixpanel
+	distinctId string
+	eventName  string
+	start      time.Time
+	now        func() time.Time
+}
+
+// StartTimer starts timing eventName for distinctId. The event is not sent
+// until Stop is called on the returned Timer.
+func StartTimer(client Mixpanel, distinctId, eventName string) *Timer {
+	return startTimer(client, distinctId, eventName, time.Now)
+}
+
+func startTimer(client Mixpanel, distinctId, eventName string, now func() time.Time) *Timer {
+	return &Timer{
+		client:     client,
+		distinctId: distinctId,
+		eventName:  eventName,
+		start:      now(),
+		now:        now,
+	}
+}
+
+// Stop tracks the timed event with props and a $duration property holding the
+// seconds elapsed since StartTimer.
+func (t *Timer) Stop(props map[string]interface{}) error {
+	elapsed := t.now().Sub(t.start)
+
+	merged := make(map[string]interface{}, len(props)+1)
+	for key, value := range props {
+		merged[key] = value
+	}
+	merged["$duration"] = elapsed.Seconds()
+
+	return t.client.Track(t.distinctId, t.eventName, &Event{Properties: merged})
+}
@@ -0,0 +1,29 @@
+package mixpanel
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTimer(t *testing.T) {
+	mock := NewMock()
+
+	now := time.Date(2016, 3, 3, 15, 17, 53, 0, time.UTC)
+	timer := startTimer(mock, "13793", "Watched Video", func() time.Time { return now })
+
+	now = now.Add(90*time.Second + 500*time.Millisecond)
+	if err := timer.Stop(map[string]interface{}{"video": "intro"}); err != nil {
+		t.Fatalf("Stop returned %v", err)
+	}
+
+	events := mock.People["13793"].Events
+	if len(events) != 1 || events[0].Name != "Watched Video" {
+		t.Fatalf("Stop tracked %+v", events)
+	}
+
+	want := map[string]interface{}{"video": "intro", "$duration": 90.5}
+	if !reflect.DeepEqual(events[0].Properties, want) {
+		t.Errorf("Stop tracked properties %+v, want %+v", events[0].Properties, want)
+	}
+}
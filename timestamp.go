@@ -0,0 +1,49 @@
+package mixpanel
+
+import "time"
+
+// TimeGranularity is the unit a timestamp is encoded in when it is sent to
+// Mixpanel.
+type TimeGranularity int
+
+const (
+	// Seconds encodes timestamps as whole seconds since the Unix epoch.
+	Seconds TimeGranularity = iota
+
+	// Milliseconds encodes timestamps as milliseconds since the Unix epoch.
+	Milliseconds
+)
+
+// defaultGranularity is the unit each endpoint expects. The legacy /track and
+// /engage endpoints take seconds, while /import takes milliseconds.
+var defaultGranularity = map[string]TimeGranularity{
+	"track":  Seconds,
+	"engage": Seconds,
+	"import": Milliseconds,
+}
+
+// WithTimeGranularity overrides the unit timestamps are encoded in for the
+// given endpoint ("track", "import" or "engage"). Endpoints that are not
+// overridden use the unit Mixpanel expects for them.
+func WithTimeGranularity(endpoint string, g TimeGranularity) Option {
+	return func(m *mixpanel) {
+		if m.granularity == nil {
+			m.granularity = map[string]TimeGranularity{}
+		}
+		m.granularity[endpoint] = g
+	}
+}
+
+// timestamp encodes t in the unit expected by the given endpoint.
+func (m *mixpanel) timestamp(endpoint string, t time.Time) int64 {
+	g, ok := m.granularity[endpoint]
+	if !ok {
+		g = defaultGranularity[endpoint]
+	}
+
+	if g == Milliseconds {
+		return t.UnixNano() / int64(time.Millisecond)
+	}
+
+	return t.Unix()
+}
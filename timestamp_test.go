@@ -0,0 +1,113 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decodePayload(t *testing.T, r *http.Request) map[string]interface{} {
+	payload := map[string]interface{}{}
+	d := json.NewDecoder(strings.NewReader(decodeData(r)))
+	d.UseNumber()
+	if err := d.Decode(&payload); err != nil {
+		t.Fatalf("decoding payload: %v", err)
+	}
+	return payload
+}
+
+func eventTime(t *testing.T) json.Number {
+	props := decodePayload(t, LastRequest)["properties"].(map[string]interface{})
+	return props["time"].(json.Number)
+}
+
+func TestTimestampGranularityTrack(t *testing.T) {
+	setup()
+	defer teardown()
+
+	at := time.Now().Add(-time.Hour)
+	client.Track("13793", "Signed Up", &Event{Timestamp: &at})
+
+	if got, want := eventTime(t).String(), strconv.FormatInt(at.Unix(), 10); got != want {
+		t.Errorf("track time returned %+v, want %+v", got, want)
+	}
+}
+
+func TestTimestampGranularityImport(t *testing.T) {
+	setup()
+	defer teardown()
+
+	at := time.Date(2016, 3, 3, 15, 17, 53, 250*int(time.Millisecond), time.UTC)
+	client.Track("13793", "Signed Up", &Event{Timestamp: &at})
+
+	if got, want := eventTime(t).String(), "1457018273250"; got != want {
+		t.Errorf("import time returned %+v, want %+v", got, want)
+	}
+	if got, want := LastRequest.URL.Path, "/import"; got != want {
+		t.Errorf("path returned %+v, want %+v", got, want)
+	}
+}
+
+func TestTimestampGranularityEngage(t *testing.T) {
+	setup()
+	defer teardown()
+
+	at := time.Date(2016, 3, 3, 15, 17, 53, 250*int(time.Millisecond), time.UTC)
+	client.Update("13793", &Update{
+		Operation:  "$set",
+		Timestamp:  &at,
+		Properties: map[string]interface{}{"Plan": "pro"},
+	})
+
+	got := decodePayload(t, LastRequest)["$time"].(json.Number).String()
+	if want := "1457018273"; got != want {
+		t.Errorf("engage $time returned %+v, want %+v", got, want)
+	}
+}
+
+func TestWithTimeGranularity(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithTimeGranularity("track", Milliseconds))
+
+	now := time.Now().Add(-time.Hour)
+	client.Track("13793", "Signed Up", &Event{Timestamp: &now})
+
+	want := strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10)
+	if got := eventTime(t).String(); got != want {
+		t.Errorf("track time returned %+v, want %+v", got, want)
+	}
+}
+
+func TestTimestampGranularityThreshold(t *testing.T) {
+	setup()
+	defer teardown()
+
+	for _, tc := range []struct {
+		age  time.Duration
+		path string
+		ms   bool
+	}{
+		{importThreshold - time.Hour, "/track", false},
+		{importThreshold + time.Hour, "/import", true},
+	} {
+		at := time.Now().Add(-tc.age).Truncate(time.Second).Add(250 * time.Millisecond)
+		client.Track("13793", "Signed Up", &Event{Timestamp: &at})
+
+		want := strconv.FormatInt(at.Unix(), 10)
+		if tc.ms {
+			want = strconv.FormatInt(at.UnixNano()/int64(time.Millisecond), 10)
+		}
+		if got := eventTime(t).String(); got != want {
+			t.Errorf("time of an event %v old returned %+v, want %+v", tc.age, got, want)
+		}
+		if got := LastRequest.URL.Path; got != tc.path {
+			t.Errorf("path of an event %v old returned %+v, want %+v", tc.age, got, tc.path)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// A ValidationError is returned, before anything is sent, when an event or a
+// profile update breaks one of the client's validation rules.
+type ValidationError struct {
+	// Event is the name of the invalid event, or empty for a profile update.
+	Event    string
+	Property string
+	Reason   string
+
+	// Err is a sentinel error the failure matches with errors.Is, if any.
+	Err error
+}
+
+func (err *ValidationError) Error() string {
+	if err.Event == "" {
+		if err.Property == "" {
+			return fmt.Sprintf("mixpanel: invalid profile update: %s", err.Reason)
+		}
+		return fmt.Sprintf("mixpanel: invalid profile update: property %q %s", err.Property, err.Reason)
+	}
+	if err.Property == "" {
+		return fmt.Sprintf("mixpanel: invalid event %q: %s", err.Event, err.Reason)
+	}
+	return fmt.Sprintf("mixpanel: invalid event %q: property %q %s", err.Event, err.Property, err.Reason)
+}
+
+func (err *ValidationError) Unwrap() error {
+	return err.Err
+}
+
+// WithStrictMode makes validation rules that would otherwise drop or fix
+// offending data return a *ValidationError instead.
+func WithStrictMode() Option {
+	return func(m *mixpanel) {
+		m.strict = true
+	}
+}
+
+// WithPropertyAllowlist restricts the properties an event may carry to
+// allowed, enforcing a tracking plan at the edge. Other properties are
+// dropped, or rejected with a *ValidationError in strict mode. Events without
+// an allowlist are sent unchanged unless WithRejectUnlistedEvents is used.
+func WithPropertyAllowlist(event string, allowed []string) Option {
+	return func(m *mixpanel) {
+		if m.allowlists == nil {
+			m.allowlists = map[string]map[string]bool{}
+		}
+		set := map[string]bool{}
+		for _, key := range allowed {
+			set[key] = true
+		}
+		m.allowlists[event] = set
+	}
+}
+
+// WithRejectUnlistedEvents makes events without a property allowlist fail
+// with a *ValidationError.
+func WithRejectUnlistedEvents() Option {
+	return func(m *mixpanel) {
+		m.rejectUnlistedEvents = true
+	}
+}
+
+// MaxPropertyNameLength is the longest property name Mixpanel accepts, in
+// characters. Properties with longer names are silently dropped by Mixpanel.
+const MaxPropertyNameLength = 255
+
+// WithMaxPropertyNameLength overrides the property name length limit
+// enforced by the client, in characters, which defaults to
+// MaxPropertyNameLength.
+func WithMaxPropertyNameLength(n int) Option {
+	return func(m *mixpanel) {
+		m.maxNameLength = n
+	}
+}
+
+// MaxEventProperties is the largest number of properties Mixpanel accepts on
+// a single event. Events with more properties are rejected.
+const MaxEventProperties = 255
+
+// WithMaxEventProperties overrides the per-event property count limit
+// enforced by the client, which defaults to MaxEventProperties.
+func WithMaxEventProperties(n int) Option {
+	return func(m *mixpanel) {
+		m.maxProperties = n
+	}
+}
+
+// An EventValidator checks an event against a tracking plan or schema,
+// typically fetched from a remote source. It returns a *ValidationError for
+// an invalid event; any other error means the event could not be validated,
+// for example because the source is unavailable, and is handled according to
+// the ValidationFailureMode.
+type EventValidator func(ctx context.Context, eventName string, props map[string]interface{}) error
+
+// ValidationFailureMode decides what happens to an event that could not be
+// validated.
+type ValidationFailureMode int
+
+const (
+	// FailOpen sends the event anyway and logs the error. No event is lost
+	// when the validation source is down, but invalid events may slip
+	// through. This is the default, and suits production.
+	FailOpen ValidationFailureMode = iota
+
+	// FailClosed rejects the event with the error. Nothing unvalidated is
+	// sent, but events are lost while the validation source is down, which
+	// suits CI.
+	FailClosed
+)
+
+// WithEventValidator validates every event with v, after the client's own
+// rules.
+func WithEventValidator(v EventValidator) Option {
+	return func(m *mixpanel) {
+		m.validator = v
+	}
+}
+
+// WithValidationFailureMode sets what happens to events the validator given
+// to WithEventValidator could not validate. The default is FailOpen.
+func WithValidationFailureMode(mode ValidationFailureMode) Option {
+	return func(m *mixpanel) {
+		m.validationFailure = mode
+	}
+}
+
+// WithRequireProperties makes events without any custom properties, whether
+// their Properties are nil or an empty map, fail with a *ValidationError
+// matching ErrNoProperties. By default they are sent with the reserved
+// properties only. Properties added by WithContextPropertyExtractor count;
+// default properties do not.
+func WithRequireProperties() Option {
+	return func(m *mixpanel) {
+		m.requireProperties = true
+	}
+}
+
+// validateEvent checks the properties of an event against the configured
+// rules and returns the properties to send.
+func (m *mixpanel) validateEvent(ctx context.Context, eventName string, props map[string]interface{}) (map[string]interface{}, error) {
+	if m.requireProperties && len(props) == 0 {
+		return nil, &ValidationError{Event: eventName, Reason: "has no properties", Err: ErrNoProperties}
+	}
+	props, err := m.applyAllowlist(eventName, props)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.checkNameLengths(eventName, props); err != nil {
+		return nil, err
+	}
+
+	if err := m.checkPropertyCount(eventName, props); err != nil {
+		return nil, err
+	}
+
+	if err := m.checkReservedTypes(eventName, props); err != nil {
+		return nil, err
+	}
+
+	if err := m.runValidator(ctx, eventName, props); err != nil {
+		return nil, err
+	}
+
+	return props, nil
+}
+
+// runValidator runs the validator given to WithEventValidator, if any.
+func (m *mixpanel) runValidator(ctx context.Context, eventName string, props map[string]interface{}) error {
+	if m.validator == nil {
+		return nil
+	}
+
+	err := m.validator(ctx, eventName, props)
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return err
+	}
+
+	err = fmt.Errorf("mixpanel: could not validate event %q: %w", eventName, err)
+	if m.validationFailure == FailClosed {
+		return err
+	}
+	m.errorf("%v", err)
+
+	return nil
+}
+
+// validateProfile checks the properties of a profile update against the
+// configured rules.
+func (m *mixpanel) validateProfile(props map[string]interface{}) error {
+	return m.checkReservedTypes("", props)
+}
+
+// ipProperties are the property names that would override Event.IP.
+var ipProperties = []string{"ip", "$ip"}
+
+// resolveIP makes ip, the IP field of an event, win over ip properties, which
+// are dropped with a warning. In strict mode the collision fails with a
+// *ValidationError instead.
+func (m *mixpanel) resolveIP(eventName, ip string, props map[string]interface{}) (map[string]interface{}, error) {
+	if ip == "" {
+		return props, nil
+	}
+
+	var resolved map[string]interface{}
+	for _, key := range ipProperties {
+		if _, ok := props[key]; !ok {
+			continue
+		}
+
+		err := &ValidationError{
+			Event:    eventName,
+			Property: key,
+			Reason:   "conflicts with the IP of the event",
+		}
+		if m.strict {
+			return nil, err
+		}
+		m.errorf("%v", err)
+
+		if resolved == nil {
+			resolved = copyMap(props)
+		}
+		delete(resolved, key)
+	}
+
+	if resolved == nil {
+		return props, nil
+	}
+	return resolved, nil
+}
+
+// checkNameLengths flags property names over the length limit, which usually
+// come from a dynamic key such as concatenated ids. In strict mode they fail
+// with a *ValidationError; otherwise a warning is logged and the event is sent
+// as is.
+func (m *mixpanel) checkNameLengths(eventName string, props map[string]interface{}) error {
+	for key := range props {
+		if utf8.RuneCountInString(key) <= m.maxNameLength {
+			continue
+		}
+
+		err := &ValidationError{
+			Event:    eventName,
+			Property: key,
+			Reason:   fmt.Sprintf("is longer than %d characters", m.maxNameLength),
+		}
+		if m.strict {
+			return err
+		}
+		m.errorf("%v", err)
+	}
+
+	return nil
+}
+
+// checkPropertyCount flags events with more properties than the limit, which
+// usually means a map was exploded into properties by mistake. In strict mode
+// they fail with a *ValidationError; otherwise a warning is logged and the
+// event is sent as is.
+func (m *mixpanel) checkPropertyCount(eventName string, props map[string]interface{}) error {
+	if len(props) <= m.maxProperties {
+		return nil
+	}
+
+	err := &ValidationError{
+		Event:  eventName,
+		Reason: fmt.Sprintf("has %d properties, more than the limit of %d", len(props), m.maxProperties),
+	}
+	if m.strict {
+		return err
+	}
+	m.errorf("%v", err)
+
+	return nil
+}
+
+// The range of timestamps considered plausible by checkTimestamp.
+var (
+	minPlausibleTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxPlausibleTime = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// checkTimestamp flags timestamps outside of the years 2000 to 2100, which
+// usually come from a unit mix-up such as passing milliseconds or nanoseconds
+// to time.Unix. In strict mode they fail with a *ValidationError; otherwise a
+// warning is logged and the timestamp is sent as is. eventName is empty for a
+// profile update.
+func (m *mixpanel) checkTimestamp(eventName string, t time.Time) error {
+	if !t.Before(minPlausibleTime) && t.Before(maxPlausibleTime) {
+		return nil
+	}
+
+	err := &ValidationError{
+		Event:    eventName,
+		Property: "time",
+		Reason:   fmt.Sprintf("is %v, outside the years 2000 to 2100", t.UTC().Format(time.RFC3339)),
+	}
+	if m.strict {
+		return err
+	}
+	m.errorf("%v", err)
+
+	return nil
+}
+
+func (m *mixpanel) applyAllowlist(eventName string, props map[string]interface{}) (map[string]interface{}, error) {
+	allowed, ok := m.allowlists[eventName]
+	if !ok {
+		if m.rejectUnlistedEvents {
+			return nil, &ValidationError{Event: eventName, Reason: "has no property allowlist"}
+		}
+		return props, nil
+	}
+
+	filtered := make(map[string]interface{}, len(props))
+	for key, value := range props {
+		if allowed[key] {
+			filtered[key] = value
+			continue
+		}
+		if m.strict {
+			return nil, &ValidationError{Event: eventName, Property: key, Reason: "is not in the allowlist"}
+		}
+	}
+
+	return filtered, nil
+}
@@ -0,0 +1,328 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPropertyAllowlistPass(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithStrictMode(), WithPropertyAllowlist("Signed Up", []string{"Referred By"}))
+
+	client.Track("13793", "Signed Up", &Event{
+		Properties: map[string]interface{}{
+			"Referred By": "Friend",
+		},
+	})
+
+	want := "{\"event\":\"Signed Up\",\"properties\":{\"Referred By\":\"Friend\",\"distinct_id\":\"13793\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+
+	if !reflect.DeepEqual(decodeData(LastRequest), want) {
+		t.Errorf("LastRequest.URL returned %+v, want %+v",
+			decodeData(LastRequest), want)
+	}
+}
+
+func TestPropertyAllowlistDrop(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithPropertyAllowlist("Signed Up", []string{"Referred By"}))
+
+	client.Track("13793", "Signed Up", &Event{
+		Properties: map[string]interface{}{
+			"Referred By": "Friend",
+			"email":       "user@email.com",
+		},
+	})
+
+	want := "{\"event\":\"Signed Up\",\"properties\":{\"Referred By\":\"Friend\",\"distinct_id\":\"13793\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+
+	if !reflect.DeepEqual(decodeData(LastRequest), want) {
+		t.Errorf("LastRequest.URL returned %+v, want %+v",
+			decodeData(LastRequest), want)
+	}
+}
+
+func TestPropertyAllowlistError(t *testing.T) {
+	setup()
+	defer teardown()
+	LastRequest = nil
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithStrictMode(), WithPropertyAllowlist("Signed Up", []string{"Referred By"}))
+
+	err := client.Track("13793", "Signed Up", &Event{
+		Properties: map[string]interface{}{
+			"email": "user@email.com",
+		},
+	})
+
+	want := &ValidationError{Event: "Signed Up", Property: "email", Reason: "is not in the allowlist"}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("Track returned %+v, want %+v", err, want)
+	}
+	if LastRequest != nil {
+		t.Errorf("Track sent a request for an invalid event")
+	}
+}
+
+func TestRejectUnlistedEvents(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithPropertyAllowlist("Signed Up", []string{"Referred By"}), WithRejectUnlistedEvents())
+
+	err := client.Track("13793", "Logged In", &Event{})
+
+	want := &ValidationError{Event: "Logged In", Reason: "has no property allowlist"}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("Track returned %+v, want %+v", err, want)
+	}
+}
+
+func TestPropertyNameLengthStrict(t *testing.T) {
+	setup()
+	defer teardown()
+	LastRequest = nil
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithStrictMode())
+
+	name := strings.Repeat("user_13793_", 30)
+	err := client.Track("13793", "Signed Up", &Event{
+		Properties: map[string]interface{}{name: true},
+	})
+
+	want := &ValidationError{Event: "Signed Up", Property: name, Reason: "is longer than 255 characters"}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("Track returned %+v, want %+v", err, want)
+	}
+	if LastRequest != nil {
+		t.Errorf("Track sent a request for an invalid event")
+	}
+}
+
+func TestPropertyNameLengthWarns(t *testing.T) {
+	setup()
+	defer teardown()
+	LastRequest = nil
+
+	logger := &testLogger{}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithLogger(logger), WithMaxPropertyNameLength(8))
+
+	client.Track("13793", "Signed Up", &Event{
+		Properties: map[string]interface{}{"Referred By": "Friend"},
+	})
+
+	want := []string{"mixpanel: invalid event \"Signed Up\": property \"Referred By\" is longer than 8 characters"}
+	if !reflect.DeepEqual(logger.lines, want) {
+		t.Errorf("logged %+v, want %+v", logger.lines, want)
+	}
+	if LastRequest == nil {
+		t.Errorf("Track sent no request outside strict mode")
+	}
+}
+
+func TestPropertyNameLengthCountsCharacters(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithStrictMode(), WithMaxPropertyNameLength(8))
+
+	// "Éducation" is 9 characters but 10 bytes, and "Café_été" 8 characters.
+	err := client.Track("13793", "Signed Up", &Event{
+		Properties: map[string]interface{}{"Café_été": true},
+	})
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		t.Errorf("Track returned %+v for a name within the limit", err)
+	}
+
+	err = client.Track("13793", "Signed Up", &Event{
+		Properties: map[string]interface{}{"Éducation": true},
+	})
+	want := &ValidationError{Event: "Signed Up", Property: "Éducation", Reason: "is longer than 8 characters"}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("Track returned %+v, want %+v", err, want)
+	}
+}
+
+func TestTimestampRange(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithStrictMode())
+
+	plausible := time.Now().Add(-time.Hour)
+	err := client.Track("13793", "Signed Up", &Event{Timestamp: &plausible})
+	if _, ok := err.(*ValidationError); ok {
+		t.Errorf("Track returned %v for a plausible timestamp", err)
+	}
+
+	LastRequest = nil
+
+	// Milliseconds mistakenly passed as seconds.
+	implausible := time.Unix(plausible.UnixNano()/int64(time.Millisecond), 0)
+	err = client.Track("13793", "Signed Up", &Event{Timestamp: &implausible})
+
+	verr, ok := err.(*ValidationError)
+	if !ok || verr.Event != "Signed Up" || verr.Property != "time" {
+		t.Errorf("Track returned %+v, want a *ValidationError on time", err)
+	}
+	if LastRequest != nil {
+		t.Errorf("Track sent a request for an invalid event")
+	}
+}
+
+func TestPropertyCount(t *testing.T) {
+	setup()
+	defer teardown()
+	LastRequest = nil
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithStrictMode())
+
+	props := map[string]interface{}{}
+	for i := 0; i < 300; i++ {
+		props["item_"+strconv.Itoa(i)] = i
+	}
+	err := client.Track("13793", "Cart Updated", &Event{Properties: props})
+
+	want := &ValidationError{Event: "Cart Updated", Reason: "has 300 properties, more than the limit of 255"}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("Track returned %+v, want %+v", err, want)
+	}
+	if LastRequest != nil {
+		t.Errorf("Track sent a request for an invalid event")
+	}
+}
+
+func TestValidationFailureMode(t *testing.T) {
+	setup()
+	defer teardown()
+
+	unavailable := errors.New("tracking plan unavailable")
+	validator := func(ctx context.Context, eventName string, props map[string]interface{}) error {
+		return unavailable
+	}
+
+	LastRequest = nil
+	logger := &testLogger{}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithLogger(logger), WithEventValidator(validator))
+
+	client.Track("13793", "Signed Up", &Event{})
+	if LastRequest == nil {
+		t.Errorf("Track sent no request failing open")
+	}
+	want := []string{"mixpanel: could not validate event \"Signed Up\": tracking plan unavailable"}
+	if !reflect.DeepEqual(logger.lines, want) {
+		t.Errorf("logged %+v, want %+v", logger.lines, want)
+	}
+
+	LastRequest = nil
+	client = New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithEventValidator(validator), WithValidationFailureMode(FailClosed))
+
+	err := client.Track("13793", "Signed Up", &Event{})
+	if !errors.Is(err, unavailable) {
+		t.Errorf("Track returned %v, want %v", err, unavailable)
+	}
+	if LastRequest != nil {
+		t.Errorf("Track sent a request failing closed")
+	}
+}
+
+func TestEventValidatorRejects(t *testing.T) {
+	setup()
+	defer teardown()
+	LastRequest = nil
+
+	want := &ValidationError{Event: "Signed Up", Property: "plan", Reason: "is not in the tracking plan"}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL,
+		WithEventValidator(func(ctx context.Context, eventName string, props map[string]interface{}) error {
+			return want
+		}))
+
+	err := client.Track("13793", "Signed Up", &Event{})
+	if err != want {
+		t.Errorf("Track returned %+v, want %+v", err, want)
+	}
+	if LastRequest != nil {
+		t.Errorf("Track sent a request for an invalid event")
+	}
+}
+
+func TestRequireProperties(t *testing.T) {
+	setup()
+	defer teardown()
+
+	lenient := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL)
+	strict := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithRequireProperties())
+
+	want := &ValidationError{Event: "Signed Up", Reason: "has no properties", Err: ErrNoProperties}
+
+	for _, props := range []map[string]interface{}{nil, {}} {
+		LastRequest = nil
+		lenient.Track("13793", "Signed Up", &Event{Properties: props})
+		if LastRequest == nil {
+			t.Errorf("Track sent no request for properties %#v by default", props)
+		}
+
+		LastRequest = nil
+		err := strict.Track("13793", "Signed Up", &Event{Properties: props})
+		if !reflect.DeepEqual(err, want) {
+			t.Errorf("Track returned %+v for properties %#v, want %+v", err, props, want)
+		}
+		if LastRequest != nil {
+			t.Errorf("Track sent a request for properties %#v with WithRequireProperties", props)
+		}
+	}
+}
+
+func TestIPCollision(t *testing.T) {
+	setup()
+	defer teardown()
+
+	logger := &testLogger{}
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithLogger(logger))
+
+	client.Track("13793", "Signed Up", &Event{
+		IP:         "203.0.113.9",
+		Properties: map[string]interface{}{"$ip": "198.51.100.1"},
+	})
+
+	want := "{\"event\":\"Signed Up\",\"properties\":{\"distinct_id\":\"13793\",\"ip\":\"203.0.113.9\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+	if got := decodeData(LastRequest); got != want {
+		t.Errorf("LastRequest.URL returned %+v, want %+v", got, want)
+	}
+	if len(logger.lines) != 1 {
+		t.Errorf("logged %+v, want one warning", logger.lines)
+	}
+
+	LastRequest = nil
+	client = New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithStrictMode())
+	err := client.Track("13793", "Signed Up", &Event{
+		IP:         "203.0.113.9",
+		Properties: map[string]interface{}{"ip": "198.51.100.1"},
+	})
+
+	wantErr := &ValidationError{Event: "Signed Up", Property: "ip", Reason: "conflicts with the IP of the event"}
+	if !reflect.DeepEqual(err, wantErr) {
+		t.Errorf("Track returned %+v, want %+v", err, wantErr)
+	}
+	if LastRequest != nil {
+		t.Errorf("Track sent a request for an invalid event")
+	}
+}
@@ -0,0 +1,43 @@
+package mixpanel
+
+import "context"
+
+type webContextKey struct{}
+
+type webContext struct {
+	currentURL string
+	referrer   string
+}
+
+// WithWebContext returns a copy of ctx carrying the URL of the current page
+// and its referrer, typically taken from the request in a web handler, for
+// WebContextProperties to turn into the reserved $current_url and $referrer
+// properties of events tracked deeper in the stack. Empty values are left
+// out.
+func WithWebContext(ctx context.Context, currentURL, referrer string) context.Context {
+	return context.WithValue(ctx, webContextKey{}, webContext{currentURL: currentURL, referrer: referrer})
+}
+
+// WebContextProperties returns the $current_url and $referrer properties
+// stored in ctx by WithWebContext. Pass it to WithContextPropertyExtractor, or
+// call it from your own extractor, to have TrackCtx populate them:
+//
+//	client := mixpanel.New(token, "", "", "", mixpanel.WithContextPropertyExtractor(mixpanel.WebContextProperties))
+//
+// As with any extracted property, values set on the event itself win.
+func WebContextProperties(ctx context.Context) map[string]interface{} {
+	web, ok := ctx.Value(webContextKey{}).(webContext)
+	if !ok {
+		return nil
+	}
+
+	props := map[string]interface{}{}
+	if web.currentURL != "" {
+		props["$current_url"] = web.currentURL
+	}
+	if web.referrer != "" {
+		props["$referrer"] = web.referrer
+	}
+
+	return props
+}
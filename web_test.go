@@ -0,0 +1,28 @@
+package mixpanel
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestWebContextProperties(t *testing.T) {
+	setup()
+	defer teardown()
+
+	client := New("e3bc4100330c35722740fb8c6f5abddc", "", "", ts.URL, WithContextPropertyExtractor(WebContextProperties))
+
+	ctx := WithWebContext(context.Background(), "https://example.com/pricing", "https://www.google.com/")
+	client.TrackCtx(ctx, "13793", "Viewed Pricing", &Event{})
+
+	want := "{\"event\":\"Viewed Pricing\",\"properties\":{\"$current_url\":\"https://example.com/pricing\",\"$referrer\":\"https://www.google.com/\",\"distinct_id\":\"13793\",\"token\":\"e3bc4100330c35722740fb8c6f5abddc\"}}"
+
+	if !reflect.DeepEqual(decodeData(LastRequest), want) {
+		t.Errorf("LastRequest.URL returned %+v, want %+v",
+			decodeData(LastRequest), want)
+	}
+
+	if props := WebContextProperties(context.Background()); props != nil {
+		t.Errorf("WebContextProperties returned %+v without web context", props)
+	}
+}